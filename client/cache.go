@@ -0,0 +1,475 @@
+package client
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// the most bytes of a response body read into memory to be cached when
+// the cache does not set its own limit
+const defaultMaxCachedBody = 8 << 20
+
+// Reports how a response was served by the client's cache.
+type CacheStatus string
+
+const (
+	CacheMiss        CacheStatus = "MISS"        // the response came from the server and may have been cached
+	CacheHit         CacheStatus = "HIT"         // the response was served from the cache without a request
+	CacheRevalidated CacheStatus = "REVALIDATED" // the server confirmed the cached response is still valid
+)
+
+// A Cache stores GET responses by their request url. Implementations must
+// be safe for concurrent use and should treat stored responses as read
+// only.
+type Cache interface {
+	// Get returns the response stored for the key, if any.
+	Get(key string) (*CachedResponse, bool)
+
+	// Set stores a response under the key, replacing any existing one.
+	Set(key string, resp *CachedResponse)
+
+	// Delete removes the response stored for the key.
+	Delete(key string)
+}
+
+// A response stored in a Cache.
+type CachedResponse struct {
+	// http status text and code. Example "200 OK" and 200
+	Status     string
+	StatusCode int
+
+	// the response headers and fully read body
+	Header http.Header
+	Body   []byte
+
+	// the request header values named by the response's Vary header. A
+	// cached response is only used for requests with the same values.
+	Vary http.Header
+
+	// when the request was sent and the response was received, used to
+	// work out the response's age
+	RequestTime  time.Time
+	ResponseTime time.Time
+}
+
+// Size
+// returns the approximate number of bytes the response takes up.
+func (cr *CachedResponse) Size() int64 {
+	size := int64(len(cr.Body) + len(cr.Status))
+	for k, v := range cr.Header {
+		size += int64(len(k))
+		for _, value := range v {
+			size += int64(len(value))
+		}
+	}
+	return size
+}
+
+// WithCache
+// caches GET responses in the given cache, replacing any cache built from
+// the client's configs. A nil cache disables caching.
+func WithCache(cache Cache) Option {
+	return func(c *Client) {
+		c.cache = nil
+		if cache != nil {
+			c.cache = &responseCache{store: cache, maxBody: defaultMaxCachedBody, now: time.Now}
+		}
+	}
+}
+
+// An in-memory Cache that evicts the least recently used responses once
+// it holds too many responses or too many bytes.
+type LRU struct {
+	mu sync.Mutex
+
+	maxEntries int
+	maxBytes   int64
+	size       int64
+
+	order *list.List // most recently used at the front
+	items map[string]*list.Element
+}
+
+type lruItem struct {
+	key  string
+	resp *CachedResponse
+	size int64
+}
+
+// NewLRU
+// creates an in-memory LRU cache. A zero limit means that limit is not
+// enforced.
+func NewLRU(maxEntries int, maxBytes int64) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get
+// implements the Cache interface.
+func (l *LRU) Get(key string) (*CachedResponse, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return elem.Value.(*lruItem).resp, true
+}
+
+// Set
+// implements the Cache interface. Responses bigger than the cache's byte
+// limit are not stored.
+func (l *LRU) Set(key string, resp *CachedResponse) {
+	size := resp.Size()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.remove(key)
+	if l.maxBytes > 0 && size > l.maxBytes {
+		return
+	}
+
+	l.items[key] = l.order.PushFront(&lruItem{key: key, resp: resp, size: size})
+	l.size += size
+
+	for (l.maxEntries > 0 && l.order.Len() > l.maxEntries) || (l.maxBytes > 0 && l.size > l.maxBytes) {
+		l.remove(l.order.Back().Value.(*lruItem).key)
+	}
+}
+
+// Delete
+// implements the Cache interface.
+func (l *LRU) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.remove(key)
+}
+
+// Len
+// returns the number of cached responses.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU) remove(key string) {
+	if elem, ok := l.items[key]; ok {
+		l.order.Remove(elem)
+		delete(l.items, key)
+		l.size -= elem.Value.(*lruItem).size
+	}
+}
+
+/********** helper functions **********/
+
+// Serves GET requests from a Cache following the caching rules of
+// RFC 9111 for a shared cache, as a client may make requests on behalf of
+// many users.
+type responseCache struct {
+	store   Cache
+	maxBody int64
+	now     func() time.Time
+}
+
+// newResponseCache
+// creates an LRU backed response cache from the client's cache configs.
+// A nil cache is returned when caching is not configured.
+func newResponseCache(conf config.Cache) *responseCache {
+	if conf.MaxEntries <= 0 && conf.MaxBytes <= 0 {
+		return nil
+	}
+
+	maxBody := int64(defaultMaxCachedBody)
+	if conf.MaxBytes > 0 && conf.MaxBytes < maxBody {
+		maxBody = conf.MaxBytes
+	}
+	return &responseCache{store: NewLRU(conf.MaxEntries, conf.MaxBytes), maxBody: maxBody, now: time.Now}
+}
+
+// do
+// answers the request from the cache when a fresh response is stored,
+// revalidates stale responses with the server and stores new cacheable
+// responses. Requests that are not cacheable are passed through.
+func (rc *responseCache) do(req *http.Request, next func(*http.Request) (*Response, error)) (*Response, error) {
+	key := req.URL.String()
+	reqCC := cacheControl(req.Header.Values("Cache-Control"))
+
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || reqCC.has("no-store") {
+		resp, err := next(req)
+
+		// successful unsafe requests invalidate what we have cached
+		if err == nil && !isSafe(req.Method) && resp.StatusCode < 400 {
+			rc.store.Delete(key)
+		}
+		return resp, err
+	}
+
+	cached, ok := rc.store.Get(key)
+	if ok && !cached.varies(req) {
+		if rc.fresh(cached) && !reqCC.has("no-cache") {
+			return cached.response(req, CacheHit, rc.now()), nil
+		}
+
+		// ask the server if the stale response is still valid
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	} else {
+		ok = false
+	}
+
+	requestTime := rc.now()
+	resp, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+	responseTime := rc.now()
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		resp.Close() // nolint:errcheck
+		updated := cached.revalidate(resp.Header, requestTime, responseTime)
+		rc.store.Set(key, updated)
+		return updated.response(req, CacheRevalidated, responseTime), nil
+	}
+
+	resp.CacheStatus = CacheMiss
+	rc.save(key, req, resp, requestTime, responseTime)
+	return resp, nil
+}
+
+// save
+// stores the response when it is cacheable. Responses meant for a single
+// user, i.e. private responses and responses to authorized requests that
+// are not explicitly public, are never stored. The response body is read
+// into memory and replaced with a reader over the read bytes.
+func (rc *responseCache) save(key string, req *http.Request, resp *Response, requestTime, responseTime time.Time) {
+	cc := cacheControl(resp.Header.Values("Cache-Control"))
+	vary := headerList(resp.Header.Values("Vary"))
+
+	switch {
+	case !cacheableStatus(resp.StatusCode), cc.has("no-store"), cc.has("private"), contains(vary, "*"):
+		return
+	case req.Header.Get("Authorization") != "" && !cc.has("public") && !cc.has("s-maxage"):
+		return
+	case !cc.has("max-age") && !cc.has("s-maxage") && resp.Header.Get("Expires") == "" &&
+		resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "":
+		// no way to tell if it is fresh or to revalidate it
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.body, rc.maxBody+1))
+	if err != nil || int64(len(body)) > rc.maxBody {
+		// too big or broken, hand back what was read and the rest of the body
+		resp.body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.body), Closer: resp.body}
+		return
+	}
+	resp.body.Close() // nolint:errcheck
+	resp.body = io.NopCloser(bytes.NewReader(body))
+
+	varied := make(http.Header, len(vary))
+	for _, field := range vary {
+		varied[http.CanonicalHeaderKey(field)] = req.Header.Values(field)
+	}
+
+	rc.store.Set(key, &CachedResponse{
+		Status:       resp.Status,
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		Vary:         varied,
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	})
+}
+
+// fresh
+// checks if the cached response can be used without revalidating it.
+func (rc *responseCache) fresh(cr *CachedResponse) bool {
+	if cacheControl(cr.Header.Values("Cache-Control")).has("no-cache") {
+		return false
+	}
+	return cr.age(rc.now()) < cr.lifetime()
+}
+
+// lifetime
+// returns how long the response is fresh for from its s-maxage or max-age
+// directive, or its Expires header.
+func (cr *CachedResponse) lifetime() time.Duration {
+	cc := cacheControl(cr.Header.Values("Cache-Control"))
+	maxAge, ok := cc["s-maxage"]
+	if !ok {
+		maxAge, ok = cc["max-age"]
+	}
+	if ok {
+		seconds, err := strconv.ParseInt(maxAge, 10, 64)
+		if err != nil {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	expires, err := http.ParseTime(cr.Header.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	return expires.Sub(cr.date())
+}
+
+// age
+// estimates how old the response is now.
+func (cr *CachedResponse) age(now time.Time) time.Duration {
+	apparent := cr.ResponseTime.Sub(cr.date())
+	if apparent < 0 {
+		apparent = 0
+	}
+
+	if seconds, err := strconv.ParseInt(cr.Header.Get("Age"), 10, 64); err == nil {
+		if header := time.Duration(seconds) * time.Second; header > apparent {
+			apparent = header
+		}
+	}
+
+	return apparent + cr.ResponseTime.Sub(cr.RequestTime) + now.Sub(cr.ResponseTime)
+}
+
+// date
+// returns the response's Date header or when it was received.
+func (cr *CachedResponse) date() time.Time {
+	if date, err := http.ParseTime(cr.Header.Get("Date")); err == nil {
+		return date
+	}
+	return cr.ResponseTime
+}
+
+// varies
+// checks if the request has different values than the cached response for
+// any of the headers named by the response's Vary header.
+func (cr *CachedResponse) varies(req *http.Request) bool {
+	for field, values := range cr.Vary {
+		if strings.Join(req.Header.Values(field), ",") != strings.Join(values, ",") {
+			return true
+		}
+	}
+	return false
+}
+
+// revalidate
+// returns a copy of the cached response updated with the headers of a
+// "304 Not Modified" response.
+func (cr *CachedResponse) revalidate(header http.Header, requestTime, responseTime time.Time) *CachedResponse {
+	updated := *cr
+	updated.Header = cr.Header.Clone()
+	for k, v := range header {
+		switch k {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		updated.Header[k] = v
+	}
+	updated.Header.Del("Age")
+	updated.RequestTime, updated.ResponseTime = requestTime, responseTime
+	return &updated
+}
+
+// response
+// builds a client response from the cached response.
+func (cr *CachedResponse) response(req *http.Request, status CacheStatus, now time.Time) *Response {
+	header := cr.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(cr.age(now)/time.Second), 10))
+
+	return &Response{
+		Status:      cr.Status,
+		StatusCode:  cr.StatusCode,
+		Header:      header,
+		CacheStatus: status,
+		body:        io.NopCloser(bytes.NewReader(cr.Body)),
+		Request:     req,
+	}
+}
+
+// directives of a Cache-Control header, lower cased
+type directives map[string]string
+
+func (d directives) has(directive string) bool {
+	_, ok := d[directive]
+	return ok
+}
+
+// cacheControl
+// parses Cache-Control header values into their directives.
+func cacheControl(values []string) directives {
+	d := make(directives)
+	for _, directive := range headerList(values) {
+		key, value, _ := strings.Cut(directive, "=")
+		d[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return d
+}
+
+// headerList
+// splits comma separated header values into their trimmed elements.
+func headerList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			if element = strings.TrimSpace(element); element != "" {
+				list = append(list, element)
+			}
+		}
+	}
+	return list
+}
+
+// cacheableStatus
+// checks if responses with the status code can be cached by default.
+func cacheableStatus(code int) bool {
+	switch code {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusNotFound,
+		http.StatusMethodNotAllowed, http.StatusGone, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// joins a reader with the closer of the body it was built from
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
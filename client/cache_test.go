@@ -0,0 +1,306 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+func TestClient_Cache(t *testing.T) {
+	var calls int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/last-modified":
+			modified := "Sat, 01 Oct 2022 12:00:00 GMT"
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Header().Set("Last-Modified", modified)
+			if r.Header.Get("If-Modified-Since") == modified {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			fmt.Fprint(w, r.Header.Get("Accept-Language"))
+			return
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		}
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer svr.Close()
+
+	type args struct {
+		path      string
+		languages []string
+		post      bool
+	}
+	type resp struct {
+		Statuses []CacheStatus
+		Bodies   []string
+		Calls    int32
+	}
+	tests := []struct {
+		name string
+		args args
+		resp resp
+	}{
+		{
+			name: "fresh response",
+			args: args{path: "/max-age"},
+			resp: resp{Statuses: []CacheStatus{CacheMiss, CacheHit}, Bodies: []string{"/max-age", "/max-age"}, Calls: 1},
+		},
+		{
+			name: "etag revalidation",
+			args: args{path: "/etag"},
+			resp: resp{Statuses: []CacheStatus{CacheMiss, CacheRevalidated}, Bodies: []string{"/etag", "/etag"}, Calls: 2},
+		},
+		{
+			name: "last modified revalidation",
+			args: args{path: "/last-modified"},
+			resp: resp{Statuses: []CacheStatus{CacheMiss, CacheRevalidated}, Bodies: []string{"/last-modified", "/last-modified"}, Calls: 2},
+		},
+		{
+			name: "vary on request header",
+			args: args{path: "/vary", languages: []string{"en", "fr"}},
+			resp: resp{Statuses: []CacheStatus{CacheMiss, CacheMiss}, Bodies: []string{"en", "fr"}, Calls: 2},
+		},
+		{
+			name: "vary with same request header",
+			args: args{path: "/vary", languages: []string{"en", "en"}},
+			resp: resp{Statuses: []CacheStatus{CacheMiss, CacheHit}, Bodies: []string{"en", "en"}, Calls: 1},
+		},
+		{
+			name: "no store",
+			args: args{path: "/no-store"},
+			resp: resp{Statuses: []CacheStatus{CacheMiss, CacheMiss}, Bodies: []string{"/no-store", "/no-store"}, Calls: 2},
+		},
+		{
+			name: "private",
+			args: args{path: "/private"},
+			resp: resp{Statuses: []CacheStatus{CacheMiss, CacheMiss}, Bodies: []string{"/private", "/private"}, Calls: 2},
+		},
+		{
+			name: "unsafe request invalidates",
+			args: args{path: "/max-age", post: true},
+			resp: resp{Statuses: []CacheStatus{CacheMiss, CacheMiss}, Bodies: []string{"/max-age", "/max-age"}, Calls: 3},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			client, err := New(config.Client{URL: svr.URL, Cache: config.Cache{MaxEntries: 10}})
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+
+			var got resp
+			for i := 0; i < 2; i++ {
+				if i == 1 && test.args.post {
+					if _, err := client.Post(test.args.path, nil, nil); err != nil {
+						t.Fatalf("Client.Post() error = %s", err)
+					}
+				}
+
+				var opts []RequestOption
+				if test.args.languages != nil {
+					opts = append(opts, WithHeader("Accept-Language", test.args.languages[i]))
+				}
+				r, err := client.Get(test.args.path, nil, opts...)
+				if err != nil {
+					t.Fatalf("Client.Get() error = %s", err)
+				}
+				got.Statuses = append(got.Statuses, r.CacheStatus)
+				got.Bodies = append(got.Bodies, r.GetBodyString())
+			}
+			got.Calls = atomic.LoadInt32(&calls)
+
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Client.Get() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClient_CacheAuthorization(t *testing.T) {
+	var calls int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/s-maxage":
+			w.Header().Set("Cache-Control", "s-maxage=60")
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer svr.Close()
+
+	type resp struct {
+		Statuses []CacheStatus
+		Bodies   []string
+		Calls    int32
+	}
+	tests := []struct {
+		name string
+		path string
+		resp resp
+	}{
+		{
+			name: "not shared between users",
+			path: "/orders",
+			resp: resp{Statuses: []CacheStatus{CacheMiss, CacheMiss}, Bodies: []string{"Bearer ada", "Bearer bob"}, Calls: 2},
+		},
+		{
+			name: "public",
+			path: "/public",
+			resp: resp{Statuses: []CacheStatus{CacheMiss, CacheHit}, Bodies: []string{"Bearer ada", "Bearer ada"}, Calls: 1},
+		},
+		{
+			name: "s-maxage",
+			path: "/s-maxage",
+			resp: resp{Statuses: []CacheStatus{CacheMiss, CacheHit}, Bodies: []string{"Bearer ada", "Bearer ada"}, Calls: 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			client, err := New(config.Client{URL: svr.URL, Cache: config.Cache{MaxEntries: 10}})
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+
+			var got resp
+			for _, token := range []string{"Bearer ada", "Bearer bob"} {
+				r, err := client.Get(test.path, nil, WithHeader("Authorization", token))
+				if err != nil {
+					t.Fatalf("Client.Get() error = %s", err)
+				}
+				got.Statuses = append(got.Statuses, r.CacheStatus)
+				got.Bodies = append(got.Bodies, r.GetBodyString())
+			}
+			got.Calls = atomic.LoadInt32(&calls)
+
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Client.Get() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClient_CacheExpires(t *testing.T) {
+	var calls int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, "reference data")
+	}))
+	defer svr.Close()
+
+	now := time.Now()
+	client, err := New(config.Client{URL: svr.URL}, WithCache(NewLRU(10, 0)))
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+	client.cache.now = func() time.Time { return now }
+
+	var got []CacheStatus
+	for _, elapsed := range []time.Duration{0, 30 * time.Second, 61 * time.Second} {
+		now = now.Add(elapsed)
+		r, err := client.Get("/", nil)
+		if err != nil {
+			t.Fatalf("Client.Get() error = %s", err)
+		}
+		got = append(got, r.CacheStatus)
+	}
+
+	want := []CacheStatus{CacheMiss, CacheHit, CacheMiss}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Client.Get() mismatch (-want +got):\n%s", diff)
+	}
+	if calls != 2 {
+		t.Errorf("server calls = %d, want 2", calls)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	entry := func(body string) *CachedResponse {
+		return &CachedResponse{Body: []byte(body)}
+	}
+
+	type args struct {
+		maxEntries int
+		maxBytes   int64
+		keys       []string
+		bodies     []string
+		get        string // key read after the first set, making it recently used
+	}
+	tests := []struct {
+		name string
+		args args
+		resp []string // keys left in the cache
+	}{
+		{
+			name: "evicts by entries",
+			args: args{maxEntries: 2, keys: []string{"a", "b", "c"}, bodies: []string{"1", "2", "3"}},
+			resp: []string{"b", "c"},
+		},
+		{
+			name: "evicts least recently used",
+			args: args{maxEntries: 2, keys: []string{"a", "b", "c"}, bodies: []string{"1", "2", "3"}, get: "a"},
+			resp: []string{"a", "c"},
+		},
+		{
+			name: "evicts by bytes",
+			args: args{maxBytes: 10, keys: []string{"a", "b", "c"}, bodies: []string{"1234", "5678", "9012"}},
+			resp: []string{"b", "c"},
+		},
+		{
+			name: "too big to store",
+			args: args{maxBytes: 3, keys: []string{"a"}, bodies: []string{"1234"}},
+			resp: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lru := NewLRU(test.args.maxEntries, test.args.maxBytes)
+			for i, key := range test.args.keys {
+				lru.Set(key, entry(test.args.bodies[i]))
+				if i == 1 && test.args.get != "" {
+					lru.Get(test.args.get)
+				}
+			}
+
+			var got []string
+			for _, key := range test.args.keys {
+				if _, ok := lru.Get(key); ok {
+					got = append(got, key)
+				}
+			}
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("LRU mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
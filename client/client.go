@@ -7,6 +7,10 @@ The package client is limited to the below request methods:
 
 All functions that require a context to be passed should be given one from
 the service handler request to correctly handle cancellations.
+
+A Client is safe for concurrent use by multiple goroutines. Its base URL
+and default headers are never modified once the client is created; every
+request builds its own URL and header set from copies of them.
 */
 package client
 
@@ -18,100 +22,227 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/jobaldw/shared/v2/config"
+	"github.com/jobaldw/shared/v2/logging"
+	"github.com/jobaldw/shared/v2/metrics"
+	"github.com/jobaldw/shared/v2/tracing"
 )
 
 const packageKey = "client" // package logging key
 
+// the longest a response body holds on to its request's in-flight slot or
+// hedging context when the client has no timeout and the body is never
+// read to the end or closed
+const defaultHold = time.Minute
+
 // A simple client that will also handle http requests. Uses the
 // "net/http "and "net/url" packages.
 type Client struct {
-	health string
-	client *http.Client
+	health  healthCheck
+	client  *http.Client
+	limiter *limiter
+	cache   *responseCache
+	metrics *clientMetrics
+
+	// the name metrics and spans are labeled with, the registry metrics
+	// are recorded on and the tracer spans are started with, nil when
+	// they are off
+	name     string
+	registry *metrics.Registry
+	tracer   trace.Tracer
+
+	// fallback urls and backup request policy, nil when not configured
+	endpoints *endpoints
+	hedger    *hedger
 
-	// key-value pairs in an HTTP header
-	Headers http.Header
+	// default key-value pairs sent in every request's HTTP header
+	headers http.Header
 
-	// a parsed URL (technically a URI)
-	URL *url.URL
+	// the parsed base URL (technically a URI) every request path is
+	// joined to
+	url *url.URL
 }
 
+// Option
+// customizes a client when it is created with New().
+type Option func(*Client)
+
+// WithTransport
+// sends the client's requests through the given round tripper instead of
+// the default http transport, e.g. to record or replay them in tests.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.client.Transport = transport
+	}
+}
+
+// RequestOption
+// modifies a single outgoing request before it is sent. Options never
+// change the client they are passed to.
+type RequestOption func(*http.Request)
+
 // New
-// creates a new client from the shared config.Client() struct.
-func New(conf config.Client) (*Client, error) {
+// creates a new client from the shared config.Client() struct. Options
+// are applied after the configs, overriding them.
+func New(conf config.Client, opts ...Option) (*Client, error) {
 	url, err := url.Parse(conf.URL)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %s, could not create client", packageKey, err)
+		return nil, fmt.Errorf("%s: %w, could not create client", packageKey, err)
 	}
 
-	return &Client{
-		URL:     url,
-		health:  conf.Health,
-		Headers: conf.Headers,
+	health, err := newHealthCheck(conf.Health, conf.HealthCheck)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w, could not create client", packageKey, err)
+	}
+
+	var rotation *endpoints
+	if len(conf.URLs) > 0 {
+		if rotation, err = newEndpoints(url, conf); err != nil {
+			return nil, fmt.Errorf("%s: %w, could not create client", packageKey, err)
+		}
+	}
+
+	var faults *FaultInjector
+	if len(conf.Faults.Rules) > 0 {
+		if faults, err = NewFaultInjector(conf.Faults, nil); err != nil {
+			return nil, fmt.Errorf("%s: %w, could not create client", packageKey, err)
+		}
+	}
+
+	c := &Client{
+		url:     url,
+		health:  health,
+		headers: canonicalHeaders(conf.Headers),
+		limiter: newLimiter(conf.RateLimit),
+		cache:   newResponseCache(conf.Cache),
+		hedger:  newHedger(conf.Hedging),
 		client: &http.Client{
 			Timeout: time.Duration(conf.Timeout) * time.Second,
 		},
-	}, nil
+		endpoints: rotation,
+		name:      url.Host,
+		registry:  metrics.Default,
+		tracer:    tracing.Tracer(nil),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.metrics, err = newClientMetrics(c.registry, c.name); err != nil {
+		return nil, fmt.Errorf("%s: %w, could not create client", packageKey, err)
+	}
+
+	// faults are injected in front of any custom transport
+	if faults != nil {
+		if c.client.Transport != nil {
+			faults.next = c.client.Transport
+		}
+		c.client.Transport = faults
+	}
+	return c, nil
+}
+
+// URL
+// returns a copy of the client's base URL.
+func (c *Client) URL() *url.URL {
+	u := *c.url
+	if c.url.User != nil {
+		user := *c.url.User
+		u.User = &user
+	}
+	return &u
+}
+
+// Headers
+// returns a copy of the client's default headers.
+func (c *Client) Headers() http.Header {
+	return c.headers.Clone()
+}
+
+// WithHeader
+// sets a header key-value pair on a single request, replacing any default
+// value the client has for that key.
+func WithHeader(key, value string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set(key, value)
+	}
+}
+
+// WithHeaders
+// merges the given headers into a single request, replacing any default
+// values the client has for the same keys.
+func WithHeaders(headers http.Header) RequestOption {
+	return func(r *http.Request) {
+		for k, v := range headers {
+			r.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
 }
 
 // IsReady
 // uses the clients health endpoint to determine if its up and running.
+// An error is only returned when the health endpoint could not be
+// reached, use Health() for the details of a failed check.
 func (c *Client) IsReady(ctx context.Context) (bool, error) {
-	resp, err := c.GetWithContext(ctx, c.health, nil)
-	if err != nil {
-		return false, fmt.Errorf("%s: %s", packageKey, err)
+	result := c.Health(ctx)
+	if result.StatusCode == 0 && result.Err != nil {
+		return false, result.Err
 	}
-	return resp.IsSuccessful(), nil
+	return result.IsUp(), nil
 }
 
 // Get
 // makes a GET method request to the client with a background context.
-func (c *Client) Get(path string, params map[string][]string) (*Response, error) {
-	return c.GetWithContext(context.Background(), path, params)
+func (c *Client) Get(path string, params map[string][]string, opts ...RequestOption) (*Response, error) {
+	return c.GetWithContext(context.Background(), path, params, opts...)
 }
 
 // GetWithContext
 // makes a GET method request to the client with any passed in context.
-func (c *Client) GetWithContext(ctx context.Context, path string, params map[string][]string) (*Response, error) {
-	return c.do(ctx, http.MethodGet, path, params, nil)
+func (c *Client) GetWithContext(ctx context.Context, path string, params map[string][]string, opts ...RequestOption) (*Response, error) {
+	return c.do(ctx, http.MethodGet, path, params, nil, opts...)
 }
 
 // Post
 // makes a POST method request to the client with a background context.
-func (c *Client) Post(path string, params map[string][]string, body interface{}) (*Response, error) {
-	return c.PostWithContext(context.Background(), path, params, body)
+// An io.Reader body is streamed as is, any other body is sent as JSON.
+func (c *Client) Post(path string, params map[string][]string, body interface{}, opts ...RequestOption) (*Response, error) {
+	return c.PostWithContext(context.Background(), path, params, body, opts...)
 }
 
 // PostWithContext
 // makes a POST method request to the client with any passed in context.
-func (c *Client) PostWithContext(ctx context.Context, path string, params map[string][]string, body interface{}) (*Response, error) {
-	return c.do(ctx, http.MethodPost, path, params, body)
+func (c *Client) PostWithContext(ctx context.Context, path string, params map[string][]string, body interface{}, opts ...RequestOption) (*Response, error) {
+	return c.do(ctx, http.MethodPost, path, params, body, opts...)
 }
 
 // Put
 // makes a PUT method request to the client with a background context.
-func (c *Client) Put(path string, params map[string][]string, body interface{}) (*Response, error) {
-	return c.PutWithContext(context.Background(), path, params, body)
+// An io.Reader body is streamed as is, any other body is sent as JSON.
+func (c *Client) Put(path string, params map[string][]string, body interface{}, opts ...RequestOption) (*Response, error) {
+	return c.PutWithContext(context.Background(), path, params, body, opts...)
 }
 
 // PutWithContext
 // makes a PUT method request to the client with any passed in context.
-func (c *Client) PutWithContext(ctx context.Context, path string, params map[string][]string, body interface{}) (*Response, error) {
-	return c.do(ctx, http.MethodPut, path, params, body)
+func (c *Client) PutWithContext(ctx context.Context, path string, params map[string][]string, body interface{}, opts ...RequestOption) (*Response, error) {
+	return c.do(ctx, http.MethodPut, path, params, body, opts...)
 }
 
 // Delete
 // makes a DELETE method request to the client with a background context.
-func (c *Client) Delete(path string, params map[string][]string) (*Response, error) {
-	return c.DeleteWithContext(context.Background(), path, params)
+func (c *Client) Delete(path string, params map[string][]string, opts ...RequestOption) (*Response, error) {
+	return c.DeleteWithContext(context.Background(), path, params, opts...)
 }
 
 // DeleteWithContext
 // makes a DELETE method request to the client with any passed in context.
-func (c *Client) DeleteWithContext(ctx context.Context, path string, params map[string][]string) (*Response, error) {
-	return c.do(ctx, http.MethodDelete, path, params, nil)
+func (c *Client) DeleteWithContext(ctx context.Context, path string, params map[string][]string, opts ...RequestOption) (*Response, error) {
+	return c.do(ctx, http.MethodDelete, path, params, nil, opts...)
 }
 
 /********** helper functions **********/
@@ -119,37 +250,208 @@ func (c *Client) DeleteWithContext(ctx context.Context, path string, params map[
 // do
 // builds and makes the client request using the "net/https" package with
 // NewRequestWithContext().
-func (c *Client) do(ctx context.Context, method, path string, params url.Values, payload interface{}) (*Response, error) {
-	// build the request body
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, payload interface{}, opts ...RequestOption) (*Response, error) {
+	// build the request body, streaming readers as they are and encoding
+	// anything else as JSON
 	var body io.Reader
-	if payload != nil {
+	switch p := payload.(type) {
+	case nil:
+	case io.Reader:
+		body = p
+	default:
 		b, err := json.Marshal(&payload)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %s", packageKey, err)
+			return nil, fmt.Errorf("%s: %w", packageKey, err)
 		}
 		body = bytes.NewBuffer(b)
 	}
 
-	// build the request url
-	c.URL.Path = path
-	uri := c.URL.ResolveReference(c.URL)
+	return c.send(ctx, method, c.resolve(path, params), body, opts...)
+}
+
+// send
+// makes the request to an already resolved url. The request headers are
+// built from a copy of the client's headers, and carry the trace context
+// of the client span the request is made in.
+func (c *Client) send(ctx context.Context, method string, uri *url.URL, body io.Reader, opts ...RequestOption) (*Response, error) {
+	ctx, span := c.startSpan(ctx, method, uri)
+	defer span.End()
+
 	req, err := http.NewRequestWithContext(ctx, method, uri.String(), body)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %s, could not build request", packageKey, err)
+		endSpan(span, nil, err)
+		return nil, fmt.Errorf("%s: %w, could not build request", packageKey, err)
+	}
+	req.Header = c.headers.Clone()
+	for _, opt := range opts {
+		opt(req)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	// serve GET requests from the cache when possible, except downloads
+	var resp *Response
+	if c.cache != nil && ctx.Value(uncachedKey) == nil {
+		resp, err = c.cache.do(req, c.dispatch)
+	} else {
+		resp, err = c.dispatch(req)
+	}
+	endSpan(span, resp, err)
+	return resp, err
+}
+
+// roundTrip
+// waits for the rate limiter and makes the request. The request holds its
+// in-flight slot until its response body is read to the end or closed, or
+// the client's hold time passes, and frees it at once when the response
+// has no body. Health probes are never limited.
+func (c *Client) roundTrip(req *http.Request) (*Response, error) {
+	limiter := c.limiter
+	if req.Context().Value(unlimitedKey) != nil {
+		limiter = nil
+	}
+
+	// wait for the rate limiter before doing the request
+	release := func() {}
+	if limiter != nil {
+		var err error
+		if release, err = limiter.wait(req.Context()); err != nil {
+			if req.Body != nil {
+				req.Body.Close() // nolint:errcheck
+			}
+			return nil, fmt.Errorf("%s: %w", packageKey, err)
+		}
 	}
-	req.Header = c.Headers
-	req.URL.RawQuery = params.Encode()
 
 	// do the request
+	start := time.Now()
 	resp, err := c.client.Do(req)
+
+	log := logging.For(req.Context(), packageKey)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %s, could not make request", packageKey, err)
+		release()
+		c.metrics.request(req.Method, 0, time.Since(start))
+		log.Warn().Err(err).
+			Str(logging.MethodKey, req.Method).
+			Str(logging.URLKey, logURL(req.URL)).
+			Dur(logging.LatencyKey, time.Since(start)).
+			Msg("request failed")
+		return nil, fmt.Errorf("%s: %w, could not make request", packageKey, err)
+	}
+	log.Debug().
+		Str(logging.MethodKey, req.Method).
+		Str(logging.URLKey, logURL(req.URL)).
+		Int(logging.StatusKey, resp.StatusCode).
+		Dur(logging.LatencyKey, time.Since(start)).
+		Msg("request made")
+	c.metrics.request(req.Method, resp.StatusCode, time.Since(start))
+
+	if limiter != nil {
+		limiter.observe(resp)
+	}
+	if bodiless(resp) {
+		// nothing is left to read, so the request is already finished
+		if resp.Body != nil {
+			resp.Body.Close() // nolint:errcheck
+		}
+		resp.Body = http.NoBody
+		release()
+	} else if limiter != nil {
+		resp.Body = releaseBody{ReadCloser: resp.Body, release: holdFor(c.hold(), release)}
 	}
 
 	return &Response{
 		Status:     resp.Status,
 		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
 		body:       resp.Body,
 		Request:    resp.Request,
 	}, nil
 }
+
+// hold
+// returns how long a response body may hold on to its request's in-flight
+// slot or hedging context without being read to the end or closed: the
+// client's timeout, after which the body cannot be read anyway, or
+// defaultHold when it has none.
+func (c *Client) hold() time.Duration {
+	if c.client.Timeout > 0 {
+		return c.client.Timeout
+	}
+	return defaultHold
+}
+
+// holdFor
+// returns a func calling done when it is called or once the duration has
+// passed, whichever comes first. done must be safe to call twice.
+func holdFor(d time.Duration, done func()) func() {
+	timer := time.AfterFunc(d, done)
+	return func() {
+		timer.Stop()
+		done()
+	}
+}
+
+// bodiless
+// reports whether a response has no body to read, e.g. "204 No Content"
+// or the response to a HEAD request.
+func bodiless(resp *http.Response) bool {
+	switch {
+	case resp.Body == nil, resp.Body == http.NoBody, resp.ContentLength == 0:
+		return true
+	case resp.StatusCode == http.StatusNoContent, resp.StatusCode == http.StatusNotModified:
+		return true
+	}
+	return resp.Request != nil && resp.Request.Method == http.MethodHead
+}
+
+// resolve
+// joins the request path onto a copy of the client's base url, keeping
+// any base path prefix (e.g. "/api/v2"), and merges the query params with
+// any the base url already has.
+func (c *Client) resolve(path string, params url.Values) *url.URL {
+	uri := c.URL()
+	uri.Path = joinPath(c.url.Path, path)
+	uri.RawPath = ""
+
+	query := uri.Query()
+	for k, v := range params {
+		query[k] = append([]string(nil), v...)
+	}
+	uri.RawQuery = query.Encode()
+	return uri
+}
+
+// logURL
+// returns the url without its credentials and query, which may hold
+// secrets, for logging.
+func logURL(u *url.URL) string {
+	l := *u
+	l.User, l.RawQuery, l.ForceQuery = nil, "", false
+	return l.String()
+}
+
+// canonicalHeaders
+// copies the configured headers using canonical keys so per-request
+// headers replace them instead of being sent alongside them.
+func canonicalHeaders(headers map[string][]string) http.Header {
+	h := make(http.Header, len(headers))
+	for k, v := range headers {
+		for _, value := range v {
+			h.Add(k, value)
+		}
+	}
+	return h
+}
+
+// joinPath
+// joins a base path and a request path with exactly one slash between
+// them. A trailing slash on the request path is preserved.
+func joinPath(base, path string) string {
+	switch {
+	case path == "":
+		return base
+	case base == "" || base == "/":
+		return "/" + strings.TrimLeft(path, "/")
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(path, "/")
+}
@@ -1,18 +1,27 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 
 	"github.com/jobaldw/shared/v2/config"
-	"github.com/jobaldw/shared/v2/internal/test/mock"
+	"github.com/jobaldw/shared/v2/logging"
+	"github.com/jobaldw/shared/v2/testing/httpmock"
 )
 
 func TestClient_IsReady(t *testing.T) {
-	svr := mock.Server()
+	svr := mockServer()
 	defer svr.Close()
 
 	opts := cmp.Options{}
@@ -60,12 +69,12 @@ func TestClient_IsReady(t *testing.T) {
 }
 
 func TestClient_Post(t *testing.T) {
-	svr := mock.Server()
+	svr := mockServer()
 	defer svr.Close()
 
 	opts := cmp.Options{
 		cmpopts.IgnoreFields(Client{}, "client"),
-		cmpopts.IgnoreFields(Response{}, "body", "Request"),
+		cmpopts.IgnoreFields(Response{}, "body", "Header", "Request"),
 	}
 
 	type client config.Client
@@ -122,12 +131,12 @@ func TestClient_Post(t *testing.T) {
 }
 
 func TestClient_Put(t *testing.T) {
-	svr := mock.Server()
+	svr := mockServer()
 	defer svr.Close()
 
 	opts := cmp.Options{
 		cmpopts.IgnoreFields(Client{}, "client"),
-		cmpopts.IgnoreFields(Response{}, "body", "Request"),
+		cmpopts.IgnoreFields(Response{}, "body", "Header", "Request"),
 	}
 
 	type client config.Client
@@ -184,12 +193,12 @@ func TestClient_Put(t *testing.T) {
 }
 
 func TestClient_Delete(t *testing.T) {
-	svr := mock.Server()
+	svr := mockServer()
 	defer svr.Close()
 
 	opts := cmp.Options{
 		cmpopts.IgnoreFields(Client{}, "client"),
-		cmpopts.IgnoreFields(Response{}, "body", "Request"),
+		cmpopts.IgnoreFields(Response{}, "body", "Header", "Request"),
 	}
 
 	type client config.Client
@@ -242,3 +251,183 @@ func TestClient_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_Concurrent(t *testing.T) {
+	// echo back the path, query and headers the server received
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{ // nolint:errcheck
+			"path":    r.URL.Path,
+			"query":   r.URL.Query().Get("n"),
+			"default": r.Header.Get("X-Default"),
+			"request": r.Header.Get("X-Request"),
+		})
+	}))
+	defer svr.Close()
+
+	client, err := New(config.Client{
+		URL:     svr.URL + "/api/v2",
+		Headers: map[string][]string{"X-Default": {"shared"}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	const workers, requests = 20, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*requests)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < requests; i++ {
+				n := fmt.Sprintf("%d-%d", w, i)
+				resp, err := client.Get("/items/"+n, map[string][]string{"n": {n}}, WithHeader("X-Request", n))
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				var got map[string]string
+				if err := json.Unmarshal(resp.GetBodyBytes(), &got); err != nil {
+					errs <- err
+					return
+				}
+
+				want := map[string]string{"path": "/api/v2/items/" + n, "query": n, "default": "shared", "request": n}
+				if diff := cmp.Diff(want, got); diff != "" {
+					errs <- fmt.Errorf("request %s mismatch (-want +got):\n%s", n, diff)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if diff := cmp.Diff(http.Header{"X-Default": {"shared"}}, client.Headers()); diff != "" {
+		t.Errorf("Client.Headers() mismatch (-want +got):\n%s", diff)
+	}
+	if got := client.URL().String(); got != svr.URL+"/api/v2" {
+		t.Errorf("Client.URL() = %s, want %s", got, svr.URL+"/api/v2")
+	}
+}
+
+func TestClient_WrapsErrors(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer svr.Close()
+
+	tests := []struct {
+		name string
+		conf config.Client
+		resp error
+	}{
+		{name: "request timed out", conf: config.Client{URL: svr.URL}, resp: context.DeadlineExceeded},
+		{name: "in-flight slot timed out", conf: config.Client{URL: svr.URL, RateLimit: config.RateLimit{MaxConcurrent: 1}}, resp: context.DeadlineExceeded},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := New(test.conf)
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+
+			// a request holding the only in-flight slot, if there is one
+			go client.Get("/", nil) // nolint:errcheck
+			time.Sleep(20 * time.Millisecond)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if _, err := client.GetWithContext(ctx, "/", nil); !errors.Is(err, test.resp) {
+				t.Errorf("Client.GetWithContext() error = %v, want it to wrap %s", err, test.resp)
+			}
+		})
+	}
+}
+
+func TestClient_Logging(t *testing.T) {
+	svr := mockServer()
+	defer svr.Close()
+
+	var buf bytes.Buffer
+	logger, err := logging.New(config.Application{LogLevel: "debug"}, logging.WithWriter(&buf))
+	if err != nil {
+		t.Fatalf("logging.New() error = %s", err)
+	}
+	ctx := logging.With(logging.NewContext(context.Background(), logger), logging.RequestIDKey, "abc-123")
+
+	client, err := New(config.Client{URL: svr.URL})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+	resp, err := client.GetWithContext(ctx, "/health", map[string][]string{"token": {"secret"}})
+	if err != nil {
+		t.Fatalf("Client.Get() error = %s", err)
+	}
+	resp.Close() // nolint:errcheck
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %s, log %q", err, buf.String())
+	}
+	delete(got, "time")
+	delete(got, "latency")
+
+	want := map[string]interface{}{
+		"level":      "debug",
+		"package":    "client",
+		"request_id": "abc-123",
+		"method":     "GET",
+		"url":        svr.URL + "/health",
+		"status":     float64(200),
+		"message":    "request made",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Client.Get() log mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_joinPath(t *testing.T) {
+	type args struct {
+		base string
+		path string
+	}
+	tests := []struct {
+		name string
+		args args
+		resp string
+	}{
+		{name: "no base", args: args{base: "", path: "/health"}, resp: "/health"},
+		{name: "root base", args: args{base: "/", path: "health"}, resp: "/health"},
+		{name: "base prefix", args: args{base: "/api/v2", path: "/health"}, resp: "/api/v2/health"},
+		{name: "base trailing slash", args: args{base: "/api/v2/", path: "/health"}, resp: "/api/v2/health"},
+		{name: "path trailing slash", args: args{base: "/api", path: "users/"}, resp: "/api/users/"},
+		{name: "empty path", args: args{base: "/api", path: ""}, resp: "/api"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := joinPath(test.args.base, test.args.path)
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("joinPath() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// mockServer
+// starts a mock server with the routes shared by the client tests.
+func mockServer() *httpmock.Server {
+	svr := httpmock.NewServer()
+	svr.On(http.MethodGet, "/health").Respond(http.StatusOK).JSON(http.StatusText(http.StatusOK))
+	svr.On(http.MethodPost, "/save").WithJSONBody("test payload").Respond(http.StatusCreated).JSON(http.StatusText(http.StatusCreated))
+	svr.On(http.MethodPut, "/update").WithJSONBody("test payload").Respond(http.StatusOK).JSON(http.StatusText(http.StatusOK))
+	svr.On(http.MethodDelete, "/delete").Respond(http.StatusNoContent)
+	return svr
+}
@@ -0,0 +1,455 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// url selection policies
+const (
+	Priority   = "priority"    // always prefer the base url, then the fallback urls in order
+	RoundRobin = "round_robin" // rotate through every url that is up
+)
+
+const (
+	defaultCooldown   = 30 * time.Second // time a failed url is left out of rotation
+	latencyWindow     = 128              // number of recent latencies kept for hedging
+	minLatencySamples = 20               // latencies needed before the hedging percentile is used
+)
+
+// A base url requests can be sent to.
+type endpoint struct {
+	url *url.URL
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// The base and fallback urls of a client.
+type endpoints struct {
+	primary    *url.URL
+	list       []*endpoint
+	roundRobin bool
+	cooldown   time.Duration
+	next       uint32
+
+	now func() time.Time
+}
+
+// newEndpoints
+// creates the client's url rotation from its base url and configured
+// fallback urls.
+func newEndpoints(primary *url.URL, conf config.Client) (*endpoints, error) {
+	e := &endpoints{
+		primary:  primary,
+		list:     []*endpoint{{url: primary}},
+		cooldown: defaultCooldown,
+		now:      time.Now,
+	}
+
+	switch conf.Selection {
+	case "", Priority:
+	case RoundRobin:
+		e.roundRobin = true
+	default:
+		return nil, fmt.Errorf("unknown url selection %q", conf.Selection)
+	}
+
+	if conf.Cooldown > 0 {
+		e.cooldown = time.Duration(conf.Cooldown) * time.Second
+	}
+
+	for _, raw := range conf.URLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		e.list = append(e.list, &endpoint{url: u})
+	}
+	return e, nil
+}
+
+// order
+// returns the urls in the order they should be tried. Urls that are up
+// come first, in priority or round robin order, followed by the urls that
+// are down, soonest to come back first, as a last resort.
+func (e *endpoints) order() []*endpoint {
+	start := 0
+	if e.roundRobin {
+		start = int((atomic.AddUint32(&e.next, 1) - 1) % uint32(len(e.list)))
+	}
+
+	now := e.now()
+	up, down := make([]*endpoint, 0, len(e.list)), []*endpoint{}
+	for i := range e.list {
+		ep := e.list[(start+i)%len(e.list)]
+		if ep.isUp(now) {
+			up = append(up, ep)
+		} else {
+			down = append(down, ep)
+		}
+	}
+
+	sort.SliceStable(down, func(i, j int) bool {
+		return down[i].until().Before(down[j].until())
+	})
+	return append(up, down...)
+}
+
+// rebase
+// moves a url resolved against the base url onto another endpoint,
+// keeping the request path and query.
+func (e *endpoints) rebase(uri *url.URL, ep *endpoint) *url.URL {
+	if ep.url == e.primary || uri.Scheme != e.primary.Scheme || uri.Host != e.primary.Host {
+		return uri
+	}
+
+	u := *ep.url
+	u.Path = joinPath(ep.url.Path, strings.TrimPrefix(uri.Path, strings.TrimRight(e.primary.Path, "/")))
+	u.RawPath, u.RawQuery, u.Fragment = "", uri.RawQuery, ""
+	return &u
+}
+
+func (ep *endpoint) isUp(now time.Time) bool {
+	return !now.Before(ep.until())
+}
+
+func (ep *endpoint) until() time.Time {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	return ep.downUntil
+}
+
+// fail
+// takes the endpoint out of rotation for the cooldown.
+func (ep *endpoint) fail(now time.Time, cooldown time.Duration) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.downUntil = now.Add(cooldown)
+}
+
+// recover
+// puts the endpoint back into rotation.
+func (ep *endpoint) recover() {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.downUntil = time.Time{}
+}
+
+// Decides when backup requests are sent from recent request latencies.
+type hedger struct {
+	percentile float64
+	delay      time.Duration
+	max        int
+
+	mu      sync.Mutex
+	samples []time.Duration
+	pos     int
+}
+
+// newHedger
+// creates a hedger from the client's hedging configs. A nil hedger is
+// returned when hedging is not configured.
+func newHedger(conf config.Hedging) *hedger {
+	if conf.Percentile <= 0 && conf.Delay <= 0 {
+		return nil
+	}
+
+	h := &hedger{
+		percentile: math.Min(conf.Percentile, 100),
+		delay:      time.Duration(conf.Delay) * time.Millisecond,
+		max:        conf.MaxRequests,
+	}
+	if h.max <= 0 {
+		h.max = 1
+	}
+	return h
+}
+
+// record
+// adds the latency of a successful request to the recent latencies.
+func (h *hedger) record(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.samples) < latencyWindow {
+		h.samples = append(h.samples, latency)
+		return
+	}
+	h.samples[h.pos] = latency
+	h.pos = (h.pos + 1) % latencyWindow
+}
+
+// after
+// returns how long to wait for a response before sending a backup
+// request. Zero means no backup request is sent.
+func (h *hedger) after() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.percentile <= 0 || len(h.samples) < minLatencySamples {
+		return h.delay
+	}
+
+	sorted := append([]time.Duration(nil), h.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(math.Ceil(h.percentile/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// The outcome of one request sent while failing over or hedging.
+type attempt struct {
+	ep      *endpoint
+	resp    *Response
+	err     error
+	latency time.Duration
+
+	// the attempt's launch order and the cancel func of its context
+	index  int
+	cancel context.CancelFunc
+}
+
+// dispatch
+// sends the request to the client's urls. Idempotent requests fail over
+// to the next url when one is down and are hedged when slow; the first
+// successful response wins and the other requests are canceled.
+func (c *Client) dispatch(req *http.Request) (*Response, error) {
+	if c.endpoints == nil && c.hedger == nil {
+		return c.roundTrip(req)
+	}
+
+	order := []*endpoint{{url: c.url}}
+	if c.endpoints != nil {
+		order = c.endpoints.order()
+	}
+
+	// requests that cannot be safely repeated only go to the best url
+	parent := req.Context()
+	if !retryable(req) {
+		ep := order[0]
+		resp, err := c.roundTrip(c.target(req, parent, ep))
+		c.observe(ep, parent, resp, err, 0)
+		return resp, err
+	}
+
+	var (
+		results  = make(chan attempt, len(order)+c.hedges())
+		cancels  []context.CancelFunc
+		inflight int
+		hedged   int
+		last     attempt
+	)
+	launch := func() {
+		index := len(cancels)
+		ep := order[index%len(order)]
+		ctx, cancel := context.WithCancel(parent)
+		cancels = append(cancels, cancel)
+		inflight++
+
+		r := c.target(req, ctx, ep)
+		go func() {
+			start := time.Now()
+			resp, err := c.roundTrip(r)
+			results <- attempt{ep: ep, resp: resp, err: err, latency: time.Since(start), index: index, cancel: cancel}
+		}()
+	}
+
+	// backup requests are sent every time the hedging delay passes
+	var hedge <-chan time.Time
+	delay := c.hedgeDelay()
+	nextHedge := func() {
+		hedge = nil
+		if delay > 0 && hedged < c.hedges() {
+			hedge = time.After(delay)
+		}
+	}
+
+	launch()
+	nextHedge()
+	for inflight > 0 {
+		select {
+		case <-hedge:
+			hedged++
+			c.metrics.retry(retryHedge)
+			launch()
+			nextHedge()
+
+		case a := <-results:
+			inflight--
+			c.observe(a.ep, parent, a.resp, a.err, a.latency)
+
+			if !failed(a.resp, a.err) {
+				// cancel the losing requests and keep the winner's context
+				// alive until its body is read to the end or closed, or
+				// the client's hold time has passed
+				for i, cancel := range cancels {
+					if i != a.index {
+						cancel()
+					}
+				}
+				go discard(results, inflight)
+				return c.withCancel(a), nil
+			}
+
+			discardAttempt(last)
+			last = a
+
+			// fail over when nothing else is in flight
+			if inflight == 0 && len(cancels) < len(order) && parent.Err() == nil {
+				c.metrics.retry(retryFailover)
+				launch()
+			}
+		}
+	}
+	return c.withCancel(last), last.err
+}
+
+/********** helper functions **********/
+
+// target
+// copies the request for an endpoint with its own context.
+func (c *Client) target(req *http.Request, ctx context.Context, ep *endpoint) *http.Request { // nolint:revive
+	r := req.Clone(ctx)
+	if c.endpoints != nil {
+		r.URL = c.endpoints.rebase(req.URL, ep)
+		r.Host = r.URL.Host
+	}
+	if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		if body, err := req.GetBody(); err == nil {
+			r.Body = body
+		}
+	}
+	return r
+}
+
+// observe
+// takes a failed endpoint out of rotation or puts a working one back in
+// and records the latency of successful requests for hedging. Requests
+// canceled by the caller say nothing about the endpoint.
+func (c *Client) observe(ep *endpoint, parent context.Context, resp *Response, err error, latency time.Duration) {
+	if parent.Err() != nil || c.endpoints == nil && c.hedger == nil {
+		return
+	}
+
+	if failed(resp, err) {
+		if c.endpoints != nil {
+			ep.fail(c.endpoints.now(), c.endpoints.cooldown)
+		}
+		return
+	}
+
+	if c.endpoints != nil {
+		ep.recover()
+	}
+	if c.hedger != nil && latency > 0 {
+		c.hedger.record(latency)
+	}
+}
+
+func (c *Client) hedges() int {
+	if c.hedger == nil {
+		return 0
+	}
+	return c.hedger.max
+}
+
+func (c *Client) hedgeDelay() time.Duration {
+	if c.hedger == nil {
+		return 0
+	}
+	return c.hedger.after()
+}
+
+// retryable
+// checks if a request can be sent more than once: its method must be
+// idempotent and its body, if any, must be able to be read again.
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// failed
+// checks if a request failed in a way that means its endpoint is down.
+func failed(resp *Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// withCancel
+// ties the attempt's context to its response body so it is canceled once
+// the body is read to the end or closed, or the client's hold time has
+// passed. The context of a response without a body is canceled at once.
+func (c *Client) withCancel(a attempt) *Response {
+	if a.resp == nil || a.resp.body == http.NoBody {
+		if a.cancel != nil {
+			a.cancel()
+		}
+		return a.resp
+	}
+	a.resp.body = cancelBody{ReadCloser: a.resp.body, cancel: holdFor(c.hold(), a.cancel)}
+	return a.resp
+}
+
+// discard
+// closes the responses of the losing requests as they come in.
+func discard(results <-chan attempt, n int) {
+	for i := 0; i < n; i++ {
+		discardAttempt(<-results)
+	}
+}
+
+func discardAttempt(a attempt) {
+	if a.resp != nil {
+		a.resp.Close() // nolint:errcheck
+	}
+	if a.cancel != nil {
+		a.cancel()
+	}
+}
+
+// a response body that cancels its request's context once it is read to
+// the end or closed, or the client's hold time has passed, whichever comes
+// first
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.cancel()
+	}
+	return n, err
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// countingServer
+// responds with its name and status code and counts its requests.
+func countingServer(name string, code int, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.WriteHeader(code)
+		fmt.Fprintf(w, "%s %s", name, r.URL.Path)
+	}))
+}
+
+func TestClient_Failover(t *testing.T) {
+	type args struct {
+		primary   int
+		selection string
+		post      bool
+		requests  int
+	}
+	type resp struct {
+		Bodies         []string
+		PrimaryCalls   int32
+		SecondaryCalls int32
+	}
+	tests := []struct {
+		name string
+		args args
+		resp resp
+	}{
+		{
+			name: "primary up",
+			args: args{primary: http.StatusOK, requests: 2},
+			resp: resp{Bodies: []string{"primary /v1/items", "primary /v1/items"}, PrimaryCalls: 2},
+		},
+		{
+			name: "primary down is taken out of rotation",
+			args: args{primary: http.StatusServiceUnavailable, requests: 2},
+			resp: resp{Bodies: []string{"secondary /v2/items", "secondary /v2/items"}, PrimaryCalls: 1, SecondaryCalls: 2},
+		},
+		{
+			name: "round robin",
+			args: args{primary: http.StatusOK, selection: RoundRobin, requests: 4},
+			resp: resp{Bodies: []string{"primary /v1/items", "secondary /v2/items", "primary /v1/items", "secondary /v2/items"}, PrimaryCalls: 2, SecondaryCalls: 2},
+		},
+		{
+			name: "non idempotent requests do not fail over",
+			args: args{primary: http.StatusServiceUnavailable, post: true, requests: 2},
+			resp: resp{Bodies: []string{"primary /v1/items", "secondary /v2/items"}, PrimaryCalls: 1, SecondaryCalls: 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got resp
+			primary := countingServer("primary", test.args.primary, &got.PrimaryCalls)
+			defer primary.Close()
+			secondary := countingServer("secondary", http.StatusOK, &got.SecondaryCalls)
+			defer secondary.Close()
+
+			client, err := New(config.Client{
+				URL:       primary.URL + "/v1",
+				URLs:      []string{secondary.URL + "/v2"},
+				Selection: test.args.selection,
+			})
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+
+			for i := 0; i < test.args.requests; i++ {
+				var r *Response
+				if test.args.post {
+					r, err = client.Post("/items", nil, "payload")
+				} else {
+					r, err = client.Get("/items", nil)
+				}
+				if err != nil {
+					t.Fatalf("request error = %s", err)
+				}
+				got.Bodies = append(got.Bodies, r.GetBodyString())
+				r.Close() // nolint:errcheck
+			}
+
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Client failover mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClient_FailoverUnreachable(t *testing.T) {
+	var calls int32
+	secondary := countingServer("secondary", http.StatusOK, &calls)
+	defer secondary.Close()
+
+	// a closed server refuses connections
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	client, err := New(config.Client{URL: primary.URL, URLs: []string{secondary.URL}})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	r, err := client.Put("/items", nil, "payload")
+	if err != nil {
+		t.Fatalf("Client.Put() error = %s", err)
+	}
+	if got := r.GetBodyString(); got != "secondary /items" {
+		t.Errorf("Client.Put() body = %q, want %q", got, "secondary /items")
+	}
+}
+
+func TestClient_Hedging(t *testing.T) {
+	canceled := make(chan struct{}, 1)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+			fmt.Fprint(w, "slow")
+		case <-r.Context().Done():
+			canceled <- struct{}{}
+		}
+	}))
+	defer slow.Close()
+
+	var calls int32
+	fast := countingServer("fast", http.StatusOK, &calls)
+	defer fast.Close()
+
+	client, err := New(config.Client{
+		URL:     slow.URL,
+		URLs:    []string{fast.URL},
+		Hedging: config.Hedging{Delay: 50},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	start := time.Now()
+	r, err := client.Get("/items", nil)
+	if err != nil {
+		t.Fatalf("Client.Get() error = %s", err)
+	}
+	defer r.Close()
+
+	if got := r.GetBodyString(); got != "fast /items" {
+		t.Errorf("Client.Get() body = %q, want %q", got, "fast /items")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Client.Get() took %s, want the backup request to win", elapsed)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("Client.Get() did not cancel the slow request")
+	}
+}
+
+func TestClient_HedgingReadBody(t *testing.T) {
+	var calls int32
+	svr := countingServer("primary", http.StatusOK, &calls)
+	defer svr.Close()
+
+	client, err := New(config.Client{URL: svr.URL, Hedging: config.Hedging{Delay: 500}})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	r, err := client.Get("/items", nil)
+	if err != nil {
+		t.Fatalf("Client.Get() error = %s", err)
+	}
+	if err := r.Request.Context().Err(); err != nil {
+		t.Fatalf("Client.Get() context error = %s before the body was read", err)
+	}
+
+	// reading the body to the end releases the winner's context without
+	// closing it
+	if got := string(r.GetBodyBytes()); got != "primary /items" {
+		t.Errorf("Response.GetBodyBytes() = %q, want %q", got, "primary /items")
+	}
+	if err := r.Request.Context().Err(); err != context.Canceled {
+		t.Errorf("Client.Get() context error = %v after the body was read, want %s", err, context.Canceled)
+	}
+}
+
+func TestClient_HedgingNoContent(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer svr.Close()
+
+	client, err := New(config.Client{URL: svr.URL, Hedging: config.Hedging{Delay: 500}})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	// a response without a body releases the winner's context at once
+	r, err := client.Delete("/items", nil)
+	if err != nil {
+		t.Fatalf("Client.Delete() error = %s", err)
+	}
+	if err := r.Request.Context().Err(); err != context.Canceled {
+		t.Errorf("Client.Delete() context error = %v, want %s", err, context.Canceled)
+	}
+}
+
+func Test_hedger_after(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    config.Hedging
+		samples int
+		resp    time.Duration
+	}{
+		{name: "delay until enough samples", conf: config.Hedging{Percentile: 90, Delay: 100}, samples: 5, resp: 100 * time.Millisecond},
+		{name: "percentile", conf: config.Hedging{Percentile: 90, Delay: 100}, samples: 100, resp: 90 * time.Millisecond},
+		{name: "delay only", conf: config.Hedging{Delay: 30}, samples: 100, resp: 30 * time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := newHedger(test.conf)
+			for i := 1; i <= test.samples; i++ {
+				h.record(time.Duration(i) * time.Millisecond)
+			}
+			if diff := cmp.Diff(test.resp, h.after()); diff != "" {
+				t.Errorf("hedger.after() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// kinds of injected faults
+const (
+	FaultReset    = "reset"    // the connection is reset before a response is received
+	FaultTimeout  = "timeout"  // no response is received until the request times out or is canceled
+	FaultTruncate = "truncate" // the response body ends after half of it is read
+)
+
+// the request or response failed because of a fault injected on purpose
+var ErrInjectedFault = errors.New("injected fault")
+
+// A FaultInjector is an http.RoundTripper that fails requests on purpose
+// to test how an application copes with a failing dependency. Requests it
+// does not fail are sent through the next round tripper.
+//
+// Clients created with a config.Client that has fault rules inject them
+// automatically, the injector only has to be used directly to wrap other
+// round trippers, e.g. in tests.
+type FaultInjector struct {
+	next  http.RoundTripper
+	rules []faultRule
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// A fault rule with its configs checked.
+type faultRule struct {
+	method      string
+	path        string
+	probability float64
+	latency     time.Duration
+	fault       string
+	status      int
+}
+
+// NewFaultInjector
+// creates a fault injector from the faults configs that sends the
+// requests it does not fail through next, or http.DefaultTransport when
+// next is nil.
+func NewFaultInjector(conf config.Faults, next http.RoundTripper) (*FaultInjector, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	seed := conf.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	f := &FaultInjector{next: next, rand: rand.New(rand.NewSource(seed))} // nolint:gosec
+	for i, rule := range conf.Rules {
+		switch rule.Fault {
+		case "", FaultReset, FaultTimeout, FaultTruncate:
+		default:
+			return nil, fmt.Errorf("unknown fault %q in rule %d", rule.Fault, i)
+		}
+		probability := 1.0
+		if rule.Probability != nil {
+			probability = *rule.Probability
+		}
+		if probability < 0 || probability > 1 {
+			return nil, fmt.Errorf("fault probability %v in rule %d is not between 0 and 1", probability, i)
+		}
+		if _, err := path.Match(rule.Path, ""); err != nil {
+			return nil, fmt.Errorf("%w, invalid fault path %q in rule %d", err, rule.Path, i)
+		}
+
+		f.rules = append(f.rules, faultRule{
+			method:      strings.ToUpper(rule.Method),
+			path:        rule.Path,
+			probability: probability,
+			latency:     time.Duration(rule.Latency) * time.Millisecond,
+			fault:       rule.Fault,
+			status:      rule.Status,
+		})
+	}
+	return f, nil
+}
+
+// RoundTrip
+// implements the http.RoundTripper interface.
+func (f *FaultInjector) RoundTrip(req *http.Request) (*http.Response, error) {
+	rule, ok := f.pick(req)
+	if !ok {
+		return f.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	if rule.latency > 0 {
+		timer := time.NewTimer(rule.latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			closeBody(req)
+			return nil, ctx.Err()
+		}
+	}
+
+	switch {
+	case rule.fault == FaultReset:
+		closeBody(req)
+		return nil, &faultError{msg: "connection reset by peer", err: syscall.ECONNRESET}
+
+	case rule.fault == FaultTimeout:
+		closeBody(req)
+		<-ctx.Done()
+		return nil, &faultError{msg: "timed out waiting for a response", err: ctx.Err(), timeout: true}
+
+	case rule.status != 0:
+		closeBody(req)
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", rule.status, http.StatusText(rule.status)),
+			StatusCode: rule.status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+
+	resp, err := f.next.RoundTrip(req)
+	if err != nil || rule.fault != FaultTruncate {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close() // nolint:errcheck
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(io.MultiReader(
+		bytes.NewReader(body[:len(body)/2]),
+		errReader{&faultError{msg: "response body truncated", err: io.ErrUnexpectedEOF}},
+	))
+	return resp, nil
+}
+
+/********** helper functions **********/
+
+// pick
+// returns the first rule matching the request that fires.
+func (f *FaultInjector) pick(req *http.Request) (faultRule, bool) {
+	for _, rule := range f.rules {
+		if rule.method != "" && rule.method != req.Method {
+			continue
+		}
+		if rule.path != "" {
+			if ok, _ := path.Match(rule.path, req.URL.Path); !ok {
+				continue
+			}
+		}
+		if f.roll() < rule.probability {
+			return rule, true
+		}
+	}
+	return faultRule{}, false
+}
+
+func (f *FaultInjector) roll() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rand.Float64()
+}
+
+// closeBody
+// closes the body of a request that will never be sent, as a round
+// tripper must.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close() // nolint:errcheck
+	}
+}
+
+// An injected failure. It matches ErrInjectedFault with errors.Is() and
+// unwraps to the error a real failure would have caused.
+type faultError struct {
+	msg     string
+	err     error
+	timeout bool
+}
+
+func (e *faultError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInjectedFault, e.msg)
+}
+
+func (e *faultError) Unwrap() error { return e.err }
+
+func (e *faultError) Is(target error) bool { return target == ErrInjectedFault }
+
+// Timeout
+// reports whether the fault was a timeout, like a net.Error.
+func (e *faultError) Timeout() bool { return e.timeout }
+
+// Temporary
+// implements the net.Error interface.
+func (e *faultError) Temporary() bool { return e.timeout }
+
+// a reader that always fails
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
@@ -0,0 +1,206 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+func TestClient_Faults(t *testing.T) {
+	var calls int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		io.WriteString(w, "0123456789") // nolint:errcheck
+	}))
+	defer svr.Close()
+
+	type resp struct {
+		StatusCode int
+		Body       string
+		Err        string
+		Calls      int32
+	}
+	tests := []struct {
+		name string
+		rule config.FaultRule
+		resp resp
+	}{
+		{
+			name: "reset",
+			rule: config.FaultRule{Fault: FaultReset},
+			resp: resp{Err: "injected fault: connection reset by peer, could not make request"},
+		},
+		{
+			name: "timeout",
+			rule: config.FaultRule{Fault: FaultTimeout},
+			resp: resp{Err: "injected fault: timed out waiting for a response, could not make request"},
+		},
+		{
+			name: "status",
+			rule: config.FaultRule{Status: http.StatusServiceUnavailable},
+			resp: resp{StatusCode: 503},
+		},
+		{
+			name: "truncate",
+			rule: config.FaultRule{Fault: FaultTruncate},
+			resp: resp{StatusCode: 200, Body: "01234", Err: "injected fault: response body truncated", Calls: 1},
+		},
+		{
+			name: "other method",
+			rule: config.FaultRule{Method: http.MethodPost, Fault: FaultReset},
+			resp: resp{StatusCode: 200, Body: "0123456789", Calls: 1},
+		},
+		{
+			name: "matching path",
+			rule: config.FaultRule{Method: http.MethodGet, Path: "/users/*", Status: http.StatusBadGateway},
+			resp: resp{StatusCode: 502},
+		},
+		{
+			name: "other path",
+			rule: config.FaultRule{Path: "/groups/*", Status: http.StatusBadGateway},
+			resp: resp{StatusCode: 200, Body: "0123456789", Calls: 1},
+		},
+		{
+			name: "always",
+			rule: config.FaultRule{Probability: probability(1), Status: http.StatusBadGateway},
+			resp: resp{StatusCode: 502},
+		},
+		{
+			name: "turned off",
+			rule: config.FaultRule{Probability: probability(0), Fault: FaultReset},
+			resp: resp{StatusCode: 200, Body: "0123456789", Calls: 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			client, err := New(config.Client{URL: svr.URL, Faults: config.Faults{Rules: []config.FaultRule{test.rule}}})
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			var got resp
+			r, err := client.GetWithContext(ctx, "/users/1", nil)
+			if err == nil {
+				got.StatusCode = r.StatusCode
+				var body []byte
+				body, err = io.ReadAll(r.GetBody())
+				got.Body = string(body)
+				r.Close() // nolint:errcheck
+			}
+			if err != nil {
+				got.Err = err.Error()
+				if i := strings.Index(got.Err, ErrInjectedFault.Error()); i >= 0 {
+					got.Err = got.Err[i:]
+				}
+			}
+			got.Calls = atomic.LoadInt32(&calls)
+
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Client.Get() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFaultInjector_Latency(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer svr.Close()
+
+	client, err := New(config.Client{URL: svr.URL, Faults: config.Faults{Rules: []config.FaultRule{{Latency: 50}}}})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	start := time.Now()
+	r, err := client.Get("/", nil)
+	if err != nil {
+		t.Fatalf("Client.Get() error = %s", err)
+	}
+	r.Close() // nolint:errcheck
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Client.Get() took %s, want at least the 50ms latency", elapsed)
+	}
+}
+
+func TestFaultInjector_Seed(t *testing.T) {
+	ok := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	conf := config.Faults{Seed: 42, Rules: []config.FaultRule{{Probability: probability(0.5), Fault: FaultReset}}}
+
+	run := func() []bool {
+		f, err := NewFaultInjector(conf, ok)
+		if err != nil {
+			t.Fatalf("NewFaultInjector() error = %s", err)
+		}
+
+		var failed []bool
+		for i := 0; i < 50; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			_, err := f.RoundTrip(req)
+			if err != nil && (!errors.Is(err, ErrInjectedFault) || !errors.Is(err, syscall.ECONNRESET)) {
+				t.Fatalf("FaultInjector.RoundTrip() error = %v, want an injected reset", err)
+			}
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+
+	first, second := run(), run()
+	if diff := cmp.Diff(first, second); diff != "" {
+		t.Errorf("faults with the same seed mismatch (-first +second):\n%s", diff)
+	}
+
+	n := 0
+	for _, failed := range first {
+		if failed {
+			n++
+		}
+	}
+	if n == 0 || n == len(first) {
+		t.Errorf("%d of %d requests failed, want roughly half", n, len(first))
+	}
+}
+
+func TestNewFaultInjector_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.FaultRule
+	}{
+		{name: "unknown fault", rule: config.FaultRule{Fault: "explode"}},
+		{name: "probability", rule: config.FaultRule{Probability: probability(1.5)}},
+		{name: "negative probability", rule: config.FaultRule{Probability: probability(-0.5)}},
+		{name: "path", rule: config.FaultRule{Path: "/users/["}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(config.Client{URL: "http://localhost", Faults: config.Faults{Rules: []config.FaultRule{test.rule}}})
+			if err == nil {
+				t.Error("New() error = nil, want an invalid fault rule error")
+			}
+		})
+	}
+}
+
+func probability(p float64) *float64 { return &p }
+
+// an http.RoundTripper made from a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+const (
+	defaultHealthTimeout = 5 * time.Second // health check time limit when none is configured
+	maxHealthBody        = 64 << 10        // most bytes of a health check body read for matching
+	maxBodyExcerpt       = 256             // most bytes of a health check body kept in its result
+)
+
+var (
+	ErrUnexpectedStatus = errors.New("unexpected health check status") // the health check status code is not an expected one
+	ErrUnexpectedBody   = errors.New("unexpected health check body")   // the health check body did not match the expected body
+	ErrUnexpectedField  = errors.New("unexpected health check field")  // a health check JSON field did not have its expected value
+)
+
+// Reports whether a client passed its health check.
+type HealthStatus string
+
+const (
+	HealthUp   HealthStatus = "UP"   // the health check passed
+	HealthDown HealthStatus = "DOWN" // the health check failed or could not be made
+)
+
+// The outcome of a single health check.
+type HealthResult struct {
+	// whether the health check passed
+	Status HealthStatus `json:"status"`
+
+	// how long the health check took
+	Latency time.Duration `json:"latency"`
+
+	// http status code of the health check response. Zero when no response
+	// was received.
+	StatusCode int `json:"status_code,omitempty"`
+
+	// the start of the health check response body
+	Body string `json:"body,omitempty"`
+
+	// why the health check failed, if it did
+	Err error `json:"-"`
+}
+
+// IsUp
+// checks if the health check passed.
+func (h HealthResult) IsUp() bool {
+	return h.Status == HealthUp
+}
+
+// Health
+// probes the client's health endpoint and checks the response against the
+// configured expected status, body and JSON fields. The probe is bound by
+// the health check timeout as well as the passed in context. It is never
+// answered from the response cache nor held back by the rate limiter, so
+// it always reaches the dependency.
+func (c *Client) Health(ctx context.Context) HealthResult {
+	ctx, cancel := context.WithTimeout(ctx, c.health.timeout)
+	defer cancel()
+
+	start := time.Now()
+	result := HealthResult{Status: HealthDown}
+
+	ctx = context.WithValue(ctx, unlimitedKey, true)
+	resp, err := c.GetWithContext(ctx, c.health.path, nil, WithHeader("Cache-Control", "no-store"))
+	if err != nil {
+		result.Latency, result.Err = time.Since(start), err
+		return result
+	}
+	defer resp.Close() // nolint:errcheck
+
+	body, err := io.ReadAll(io.LimitReader(resp.GetBody(), maxHealthBody))
+	result.Latency, result.StatusCode = time.Since(start), resp.StatusCode
+	result.Body = excerpt(body)
+	if err != nil {
+		result.Err = fmt.Errorf("%s: %w, could not read health check body", packageKey, err)
+		return result
+	}
+
+	if err := c.health.check(resp.StatusCode, body); err != nil {
+		result.Err = fmt.Errorf("%s: %w", packageKey, err)
+		return result
+	}
+
+	result.Status = HealthUp
+	return result
+}
+
+/********** helper functions **********/
+
+// The compiled health check configs of a client.
+type healthCheck struct {
+	path     string
+	timeout  time.Duration
+	statuses []int
+	body     *regexp.Regexp
+	fields   map[string]string
+}
+
+// newHealthCheck
+// compiles the health check configs.
+func newHealthCheck(path string, conf config.HealthCheck) (healthCheck, error) {
+	hc := healthCheck{
+		path:     path,
+		timeout:  defaultHealthTimeout,
+		statuses: conf.ExpectedStatus,
+		fields:   conf.ExpectedFields,
+	}
+	if conf.Timeout > 0 {
+		hc.timeout = time.Duration(conf.Timeout) * time.Second
+	}
+
+	if conf.ExpectedBody != "" {
+		body, err := regexp.Compile(conf.ExpectedBody)
+		if err != nil {
+			return hc, fmt.Errorf("invalid expected health check body, %w", err)
+		}
+		hc.body = body
+	}
+	return hc, nil
+}
+
+// check
+// matches a health check response against the expected status, body and
+// JSON fields.
+func (hc healthCheck) check(code int, body []byte) error {
+	if !hc.expectedStatus(code) {
+		return fmt.Errorf("%w %d", ErrUnexpectedStatus, code)
+	}
+
+	if hc.body != nil && !hc.body.Match(body) {
+		return fmt.Errorf("%w, does not match %q", ErrUnexpectedBody, hc.body)
+	}
+
+	// check fields in a stable order so the reported failure is too
+	paths := make([]string, 0, len(hc.fields))
+	for path := range hc.fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		raw, err := lookup(body, path)
+		if err != nil {
+			return fmt.Errorf("%w %q, %s", ErrUnexpectedField, path, err)
+		}
+		if got := fieldValue(raw); got != hc.fields[path] {
+			return fmt.Errorf("%w %q, got %q want %q", ErrUnexpectedField, path, got, hc.fields[path])
+		}
+	}
+	return nil
+}
+
+func (hc healthCheck) expectedStatus(code int) bool {
+	if len(hc.statuses) == 0 {
+		return code >= http.StatusOK && code < http.StatusMultipleChoices
+	}
+	for _, status := range hc.statuses {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldValue
+// returns a JSON string's value or the raw JSON of any other value.
+func fieldValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// excerpt
+// returns the start of a body, cut off at a valid UTF-8 boundary.
+func excerpt(body []byte) string {
+	if len(body) <= maxBodyExcerpt {
+		return string(body)
+	}
+	return strings.ToValidUTF8(string(body[:maxBodyExcerpt]), "") + "..."
+}
@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+func TestClient_Health(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/up":
+			fmt.Fprint(w, `{"status":"UP","details":{"db":"UP","connections":3}}`)
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"status":"DOWN"}`)
+		case "/degraded":
+			fmt.Fprint(w, `{"status":"UP","details":{"db":"DOWN"}}`)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/long":
+			fmt.Fprint(w, strings.Repeat("a", 1000))
+		}
+	}))
+	defer svr.Close()
+
+	type resp struct {
+		Status     HealthStatus
+		StatusCode int
+		Body       string
+		HasErr     bool
+	}
+	tests := []struct {
+		name string
+		conf config.Client
+		resp resp
+	}{
+		{
+			name: "healthy",
+			conf: config.Client{Health: "/up"},
+			resp: resp{Status: HealthUp, StatusCode: 200, Body: `{"status":"UP","details":{"db":"UP","connections":3}}`},
+		},
+		{
+			name: "unhealthy status",
+			conf: config.Client{Health: "/down"},
+			resp: resp{Status: HealthDown, StatusCode: 503, Body: `{"status":"DOWN"}`, HasErr: true},
+		},
+		{
+			name: "expected status",
+			conf: config.Client{Health: "/down", HealthCheck: config.HealthCheck{ExpectedStatus: []int{503}}},
+			resp: resp{Status: HealthUp, StatusCode: 503, Body: `{"status":"DOWN"}`},
+		},
+		{
+			name: "expected body",
+			conf: config.Client{Health: "/up", HealthCheck: config.HealthCheck{ExpectedBody: `"db":"UP"`}},
+			resp: resp{Status: HealthUp, StatusCode: 200, Body: `{"status":"UP","details":{"db":"UP","connections":3}}`},
+		},
+		{
+			name: "unexpected body",
+			conf: config.Client{Health: "/degraded", HealthCheck: config.HealthCheck{ExpectedBody: `"db":"UP"`}},
+			resp: resp{Status: HealthDown, StatusCode: 200, Body: `{"status":"UP","details":{"db":"DOWN"}}`, HasErr: true},
+		},
+		{
+			name: "expected fields",
+			conf: config.Client{Health: "/up", HealthCheck: config.HealthCheck{ExpectedFields: map[string]string{"status": "UP", "details.connections": "3"}}},
+			resp: resp{Status: HealthUp, StatusCode: 200, Body: `{"status":"UP","details":{"db":"UP","connections":3}}`},
+		},
+		{
+			name: "unexpected field",
+			conf: config.Client{Health: "/degraded", HealthCheck: config.HealthCheck{ExpectedFields: map[string]string{"status": "UP", "details.db": "UP"}}},
+			resp: resp{Status: HealthDown, StatusCode: 200, Body: `{"status":"UP","details":{"db":"DOWN"}}`, HasErr: true},
+		},
+		{
+			name: "timeout",
+			conf: config.Client{Health: "/slow", HealthCheck: config.HealthCheck{Timeout: 1}},
+			resp: resp{Status: HealthUp, StatusCode: 200},
+		},
+		{
+			name: "body excerpt",
+			conf: config.Client{Health: "/long"},
+			resp: resp{Status: HealthUp, StatusCode: 200, Body: strings.Repeat("a", maxBodyExcerpt) + "..."},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.conf.URL = svr.URL
+			client, err := New(test.conf)
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+
+			result := client.Health(context.Background())
+			got := resp{Status: result.Status, StatusCode: result.StatusCode, Body: result.Body, HasErr: result.Err != nil}
+
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Client.Health() mismatch (-want +got):\n%s", diff)
+			}
+			if result.Latency <= 0 {
+				t.Errorf("Client.Health() latency = %s, want > 0", result.Latency)
+			}
+		})
+	}
+}
+
+func TestClient_HealthTimeout(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer svr.Close()
+
+	client, err := New(config.Client{URL: svr.URL, Health: "/", HealthCheck: config.HealthCheck{Timeout: 1}})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	result := client.Health(context.Background())
+	if result.IsUp() || result.Err == nil {
+		t.Errorf("Client.Health() = %s, %v, want DOWN with an error", result.Status, result.Err)
+	}
+	if result.Latency > 1500*time.Millisecond {
+		t.Errorf("Client.Health() latency = %s, want the check to time out after 1s", result.Latency)
+	}
+
+	isReady, err := client.IsReady(context.Background())
+	if isReady || err == nil {
+		t.Errorf("Client.IsReady() = %t, %v, want false with an error", isReady, err)
+	}
+}
+
+func TestNew_InvalidExpectedBody(t *testing.T) {
+	_, err := New(config.Client{URL: "http://localhost", HealthCheck: config.HealthCheck{ExpectedBody: "("}})
+	if err == nil {
+		t.Error("New() error = nil, want invalid expected body error")
+	}
+}
+
+func TestClient_HealthBypassesCacheAndLimiter(t *testing.T) {
+	var hits int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/health" && atomic.AddInt32(&hits, 1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer svr.Close()
+
+	client, err := New(config.Client{
+		URL:         svr.URL,
+		Health:      "/health",
+		HealthCheck: config.HealthCheck{Timeout: 1},
+		Cache:       config.Cache{MaxEntries: 10},
+		RateLimit:   config.RateLimit{RequestsPerSecond: 0.01, Burst: 1},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	// takes the only token so a limited probe would time out
+	resp, err := client.Get("/other", nil)
+	if err != nil {
+		t.Fatalf("Client.Get() error = %s", err)
+	}
+	resp.Close()
+
+	var got []HealthStatus
+	for i := 0; i < 2; i++ {
+		got = append(got, client.Health(context.Background()).Status)
+	}
+	if diff := cmp.Diff([]HealthStatus{HealthUp, HealthDown}, got); diff != "" {
+		t.Errorf("Client.Health() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/jobaldw/shared/v2/metrics"
+)
+
+// client metric names
+const (
+	requestsMetric = "http_client_requests_total"
+	durationMetric = "http_client_request_duration_seconds"
+	retriesMetric  = "http_client_retries_total"
+)
+
+// retry reasons
+const (
+	retryFailover = "failover"
+	retryHedge    = "hedge"
+)
+
+// the status recorded for requests that got no response
+const statusError = "error"
+
+// WithName
+// sets the name the client's metrics are labeled with, e.g. the name of
+// the dependency it calls. It defaults to the host of the client's url.
+func WithName(name string) Option {
+	return func(c *Client) {
+		c.name = name
+	}
+}
+
+// WithMetrics
+// records the client's metrics on the given registry instead of the
+// default one. A nil registry turns the client's metrics off.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(c *Client) {
+		c.registry = reg
+	}
+}
+
+// The metrics recorded for every request a client makes.
+type clientMetrics struct {
+	name     string
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+	retries  *metrics.CounterVec
+}
+
+/********** helper functions **********/
+
+// newClientMetrics
+// registers the client metrics on the registry, returning nil when there
+// is no registry.
+func newClientMetrics(reg *metrics.Registry, name string) (*clientMetrics, error) {
+	if reg == nil {
+		return nil, nil
+	}
+
+	m := &clientMetrics{name: name}
+	var err error
+	if m.requests, err = reg.Counter(requestsMetric, "Number of HTTP requests made, by dependency.", "client", "method", "status"); err != nil {
+		return nil, err
+	}
+	if m.duration, err = reg.Histogram(durationMetric, "Latency of HTTP requests made, in seconds.", metrics.DefBuckets, "client", "method"); err != nil {
+		return nil, err
+	}
+	if m.retries, err = reg.Counter(retriesMetric, "Number of HTTP requests repeated by failover or hedging.", "client", "reason"); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// request
+// records a request made, with a status of "error" when there is no
+// response.
+func (m *clientMetrics) request(method string, status int, latency time.Duration) {
+	if m == nil {
+		return
+	}
+	code := statusError
+	if status > 0 {
+		code = strconv.Itoa(status)
+	}
+	m.requests.With(m.name, method, code).Inc()
+	m.duration.With(m.name, method).Observe(latency.Seconds())
+}
+
+// retry
+// records a request repeated for the given reason.
+func (m *clientMetrics) retry(reason string) {
+	if m == nil {
+		return
+	}
+	m.retries.With(m.name, reason).Inc()
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/config"
+	"github.com/jobaldw/shared/v2/metrics"
+)
+
+func TestClient_Metrics(t *testing.T) {
+	var calls int32
+	secondary := countingServer("secondary", http.StatusOK, &calls)
+	defer secondary.Close()
+
+	// a closed server refuses connections
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+		resp []string
+	}{
+		{
+			name: "named",
+			opts: []Option{WithName("users")},
+			resp: []string{
+				`http_client_request_duration_seconds_count{client="users",method="GET"} 3`,
+				`http_client_requests_total{client="users",method="GET",status="200"} 2`,
+				`http_client_requests_total{client="users",method="GET",status="error"} 1`,
+				`http_client_retries_total{client="users",reason="failover"} 1`,
+			},
+		},
+		{
+			name: "host by default",
+			resp: []string{
+				`http_client_request_duration_seconds_count{client="` + strings.TrimPrefix(primary.URL, "http://") + `",method="GET"} 3`,
+				`http_client_requests_total{client="` + strings.TrimPrefix(primary.URL, "http://") + `",method="GET",status="200"} 2`,
+				`http_client_requests_total{client="` + strings.TrimPrefix(primary.URL, "http://") + `",method="GET",status="error"} 1`,
+				`http_client_retries_total{client="` + strings.TrimPrefix(primary.URL, "http://") + `",reason="failover"} 1`,
+			},
+		},
+		{
+			name: "disabled",
+			opts: []Option{WithMetrics(nil)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reg := metrics.NewRegistry()
+			opts := append([]Option{WithMetrics(reg)}, test.opts...)
+
+			client, err := New(config.Client{URL: primary.URL, URLs: []string{secondary.URL}}, opts...)
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+
+			// the primary fails once and is then taken out of rotation
+			for i := 0; i < 2; i++ {
+				r, err := client.Get("/items", nil)
+				if err != nil {
+					t.Fatalf("Client.Get() error = %s", err)
+				}
+				r.Close() // nolint:errcheck
+			}
+
+			var out strings.Builder
+			reg.Write(&out) // nolint:errcheck
+
+			var got []string
+			for _, line := range strings.Split(out.String(), "\n") {
+				if strings.HasPrefix(line, "http_client_") && !strings.Contains(line, "_bucket") && !strings.Contains(line, "_sum") {
+					got = append(got, line)
+				}
+			}
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Client metrics mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrForeignNextLink = errors.New("next page link points to a different host") // a Link header tried to send the client elsewhere
+	ErrNoItem          = errors.New("no current item, call Next() first")        // Decode() was called without a current item
+	ErrRepeatedPage    = errors.New("next page is the page just fetched")        // a server sent the same next link or cursor again
+)
+
+// A single page returned while paginating.
+type Page struct {
+	// the url the page was requested from
+	URL *url.URL
+
+	// the page's response headers
+	Header http.Header
+
+	// the raw JSON body of the page
+	Body json.RawMessage
+}
+
+// A Pager is a pagination strategy. It decides where the first page is
+// requested from, which items a page holds and where the next page is.
+type Pager interface {
+	// First returns the url of the first page given the url built from the
+	// paginated path and params.
+	First(uri *url.URL) *url.URL
+
+	// Items returns the items held in a page.
+	Items(page *Page) ([]json.RawMessage, error)
+
+	// Next returns the url of the page after the given one or nil when
+	// there are no more pages.
+	Next(page *Page, items []json.RawMessage) (*url.URL, error)
+}
+
+// An Iterator yields items across every page of a paginated collection.
+//
+//	it := c.Paginate("/users", nil, client.LinkPager{})
+//	for it.Next(ctx) {
+//		var user User
+//		if err := it.Decode(&user); err != nil {
+//			// handle error
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		// handle error
+//	}
+type Iterator struct {
+	client *Client
+	pager  Pager
+	opts   []RequestOption
+
+	maxPages int
+	pages    int
+
+	next  *url.URL
+	items []json.RawMessage
+	item  json.RawMessage
+	err   error
+}
+
+// Paginate
+// creates an iterator over a paginated collection. No request is made
+// until Next() is called.
+func (c *Client) Paginate(path string, params map[string][]string, pager Pager, opts ...RequestOption) *Iterator {
+	return &Iterator{
+		client: c,
+		pager:  pager,
+		opts:   opts,
+		next:   pager.First(c.resolve(path, params)),
+	}
+}
+
+// MaxPages
+// caps the number of pages the iterator will request. Zero means no cap.
+func (it *Iterator) MaxPages(max int) *Iterator {
+	it.maxPages = max
+	return it
+}
+
+// Next
+// advances to the next item, requesting the next page when the current
+// one runs out. It returns false when there are no more items or an
+// error occurred, which is reported by Err().
+func (it *Iterator) Next(ctx context.Context) bool {
+	for len(it.items) == 0 {
+		if it.err != nil || it.next == nil || (it.maxPages > 0 && it.pages >= it.maxPages) {
+			it.item = nil
+			return false
+		}
+		it.err = it.fetch(ctx)
+	}
+
+	it.item, it.items = it.items[0], it.items[1:]
+	return true
+}
+
+// Item
+// returns the raw JSON of the current item.
+func (it *Iterator) Item() json.RawMessage {
+	return it.item
+}
+
+// Decode
+// unmarshals the current item into v.
+func (it *Iterator) Decode(v interface{}) error {
+	if it.item == nil {
+		return fmt.Errorf("%s: %w", packageKey, ErrNoItem)
+	}
+	return json.Unmarshal(it.item, v)
+}
+
+// Err
+// returns the first error that stopped the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Pages
+// returns the number of pages requested so far.
+func (it *Iterator) Pages() int {
+	return it.pages
+}
+
+/********** built-in pagers **********/
+
+// LinkPager follows the "next" url of the Link response header
+// (RFC 8288), e.g. `Link: <https://api.com/users?page=2>; rel="next"`.
+type LinkPager struct {
+	// dot separated path to the items array in the body. Empty means the
+	// body is the items array.
+	ItemsField string
+}
+
+// First
+// implements the Pager interface.
+func (p LinkPager) First(uri *url.URL) *url.URL {
+	return uri
+}
+
+// Items
+// implements the Pager interface.
+func (p LinkPager) Items(page *Page) ([]json.RawMessage, error) {
+	return items(page.Body, p.ItemsField)
+}
+
+// Next
+// implements the Pager interface. The next link must be on the same host
+// as the page so the client's headers are never sent elsewhere, and must
+// not be the page itself so the iteration never loops.
+func (p LinkPager) Next(page *Page, _ []json.RawMessage) (*url.URL, error) {
+	link := nextLink(page.Header.Values("Link"))
+	if link == "" {
+		return nil, nil
+	}
+
+	next, err := page.URL.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	if next.Scheme != page.URL.Scheme || next.Host != page.URL.Host {
+		return nil, ErrForeignNextLink
+	}
+	if next.String() == page.URL.String() {
+		return nil, ErrRepeatedPage
+	}
+	return next, nil
+}
+
+// CursorPager reads a cursor from each page's body and sends it as a
+// query param to get the next page. An empty or missing cursor ends the
+// iteration.
+type CursorPager struct {
+	// dot separated path to the items array in the body. Empty means the
+	// body is the items array.
+	ItemsField string
+
+	// dot separated path to the next cursor in the body, e.g.
+	// "meta.next_cursor".
+	CursorField string
+
+	// query param the cursor is sent as. Defaults to "cursor".
+	CursorParam string
+}
+
+// First
+// implements the Pager interface.
+func (p CursorPager) First(uri *url.URL) *url.URL {
+	return uri
+}
+
+// Items
+// implements the Pager interface.
+func (p CursorPager) Items(page *Page) ([]json.RawMessage, error) {
+	return items(page.Body, p.ItemsField)
+}
+
+// Next
+// implements the Pager interface. The cursor must differ from the one
+// the page was requested with so the iteration never loops.
+func (p CursorPager) Next(page *Page, _ []json.RawMessage) (*url.URL, error) {
+	raw, err := lookup(page.Body, p.CursorField)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+
+	// numbers are kept as they are written, so big ids are not rounded
+	var cursor interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&cursor); err != nil {
+		return nil, err
+	}
+
+	value := ""
+	switch v := cursor.(type) {
+	case string:
+		value = v
+	case json.Number:
+		value = v.String()
+	case nil:
+	default:
+		return nil, fmt.Errorf("cursor field %q is not a string or number", p.CursorField)
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	param := p.CursorParam
+	if param == "" {
+		param = "cursor"
+	}
+	if page.URL.Query().Get(param) == value {
+		return nil, ErrRepeatedPage
+	}
+	return withParam(page.URL, param, value), nil
+}
+
+// OffsetPager pages through a collection using offset and limit query
+// params. A page with fewer items than the limit ends the iteration.
+type OffsetPager struct {
+	// dot separated path to the items array in the body. Empty means the
+	// body is the items array.
+	ItemsField string
+
+	// query param names. Default to "offset" and "limit".
+	OffsetParam string
+	LimitParam  string
+
+	// number of items requested per page. Zero leaves the limit to the
+	// server and only an empty page ends the iteration.
+	Limit int
+}
+
+// First
+// implements the Pager interface. An offset already in the params is
+// used as the starting offset.
+func (p OffsetPager) First(uri *url.URL) *url.URL {
+	offset, limit := p.params()
+	if uri.Query().Get(offset) == "" {
+		uri = withParam(uri, offset, "0")
+	}
+	if p.Limit > 0 {
+		uri = withParam(uri, limit, strconv.Itoa(p.Limit))
+	}
+	return uri
+}
+
+// Items
+// implements the Pager interface.
+func (p OffsetPager) Items(page *Page) ([]json.RawMessage, error) {
+	return items(page.Body, p.ItemsField)
+}
+
+// Next
+// implements the Pager interface.
+func (p OffsetPager) Next(page *Page, items []json.RawMessage) (*url.URL, error) {
+	if len(items) == 0 || (p.Limit > 0 && len(items) < p.Limit) {
+		return nil, nil
+	}
+
+	offset, _ := p.params()
+	current, err := strconv.Atoi(page.URL.Query().Get(offset))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s param, %w", offset, err)
+	}
+	return withParam(page.URL, offset, strconv.Itoa(current+len(items))), nil
+}
+
+func (p OffsetPager) params() (offset, limit string) {
+	offset, limit = p.OffsetParam, p.LimitParam
+	if offset == "" {
+		offset = "offset"
+	}
+	if limit == "" {
+		limit = "limit"
+	}
+	return offset, limit
+}
+
+/********** helper functions **********/
+
+// fetch
+// requests the next page and queues up its items.
+func (it *Iterator) fetch(ctx context.Context) error {
+	resp, err := it.client.send(ctx, http.MethodGet, it.next, nil, it.opts...)
+	if err != nil {
+		return err
+	}
+	body := resp.GetBodyBytes()
+	resp.body.Close() // nolint:errcheck
+
+	if !resp.IsSuccessful() {
+		return fmt.Errorf("%s: page %d returned %s", packageKey, it.pages+1, resp.Status)
+	}
+	it.pages++
+
+	page := &Page{URL: it.next, Header: resp.Header, Body: body}
+	if it.items, err = it.pager.Items(page); err != nil {
+		return fmt.Errorf("%s: %w, could not read page %d items", packageKey, err, it.pages)
+	}
+	if it.next, err = it.pager.Next(page, it.items); err != nil {
+		return fmt.Errorf("%s: %w, could not find page %d", packageKey, err, it.pages+1)
+	}
+	return nil
+}
+
+// items
+// decodes the items array found at the dot separated field path.
+func items(body json.RawMessage, field string) ([]json.RawMessage, error) {
+	raw, err := lookup(body, field)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// lookup
+// walks a JSON body by a dot separated field path. A nil value is
+// returned when a field does not exist.
+func lookup(body json.RawMessage, field string) (json.RawMessage, error) {
+	if field == "" {
+		return body, nil
+	}
+
+	raw := body
+	for _, key := range strings.Split(field, ".") {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, err
+		}
+		if raw = object[key]; raw == nil {
+			return nil, nil
+		}
+	}
+	return raw, nil
+}
+
+// nextLink
+// finds the url with a "next" relation in Link header values.
+func nextLink(values []string) string {
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range parts[1:] {
+				key, rel, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(rel, `"`)) {
+					if strings.EqualFold(r, "next") {
+						return strings.Trim(target, "<>")
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// withParam
+// returns a copy of the url with the query param set to value.
+func withParam(uri *url.URL, key, value string) *url.URL {
+	u := *uri
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+	return &u
+}
@@ -0,0 +1,210 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// pagedServer
+// serves the numbers 1 through 7 three at a time using link, cursor and
+// offset pagination.
+func pagedServer() *httptest.Server {
+	const total, size = 7, 3
+	numbers := func(start int) []int {
+		var page []int
+		for i := start; i < start+size && i < total; i++ {
+			page = append(page, i+1)
+		}
+		return page
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/link", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if start := (page + 1) * size; start < total {
+			w.Header().Set("Link", fmt.Sprintf(`</api/link?page=%d>; rel="next", </api/link?page=0>; rel="first"`, page+1))
+		}
+		json.NewEncoder(w).Encode(numbers(page * size)) // nolint:errcheck
+	})
+	mux.HandleFunc("/api/cursor", func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("after"))
+		next := ""
+		if start+size < total {
+			next = strconv.Itoa(start + size)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{ // nolint:errcheck
+			"data": numbers(start),
+			"meta": map[string]string{"next": next},
+		})
+	})
+	mux.HandleFunc("/api/offset", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": numbers(offset)}) // nolint:errcheck
+	})
+	mux.HandleFunc("/api/foreign", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<https://elsewhere.com/api/foreign?page=2>; rel="next"`)
+		json.NewEncoder(w).Encode([]int{1}) // nolint:errcheck
+	})
+	mux.HandleFunc("/api/repeated-link", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</api/repeated-link>; rel="next"`)
+		json.NewEncoder(w).Encode([]int{1}) // nolint:errcheck
+	})
+	mux.HandleFunc("/api/repeated-cursor", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": []int{1}, "next": "abc"}) // nolint:errcheck
+	})
+	mux.HandleFunc("/api/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestClient_Paginate(t *testing.T) {
+	svr := pagedServer()
+	defer svr.Close()
+
+	type args struct {
+		path     string
+		pager    Pager
+		maxPages int
+	}
+	type resp struct {
+		Items  []int
+		Pages  int
+		HasErr bool
+	}
+	tests := []struct {
+		name string
+		args args
+		resp resp
+	}{
+		{
+			name: "link header",
+			args: args{path: "/link", pager: LinkPager{}},
+			resp: resp{Items: []int{1, 2, 3, 4, 5, 6, 7}, Pages: 3},
+		},
+		{
+			name: "cursor",
+			args: args{path: "/cursor", pager: CursorPager{ItemsField: "data", CursorField: "meta.next", CursorParam: "after"}},
+			resp: resp{Items: []int{1, 2, 3, 4, 5, 6, 7}, Pages: 3},
+		},
+		{
+			name: "offset and limit",
+			args: args{path: "/offset", pager: OffsetPager{ItemsField: "results", Limit: 3}},
+			resp: resp{Items: []int{1, 2, 3, 4, 5, 6, 7}, Pages: 3},
+		},
+		{
+			name: "max pages",
+			args: args{path: "/link", pager: LinkPager{}, maxPages: 2},
+			resp: resp{Items: []int{1, 2, 3, 4, 5, 6}, Pages: 2},
+		},
+		{
+			name: "foreign next link",
+			args: args{path: "/foreign", pager: LinkPager{}},
+			resp: resp{Items: []int{1}, Pages: 1, HasErr: true},
+		},
+		{
+			name: "repeated next link",
+			args: args{path: "/repeated-link", pager: LinkPager{}},
+			resp: resp{Items: []int{1}, Pages: 1, HasErr: true},
+		},
+		{
+			name: "repeated cursor",
+			args: args{path: "/repeated-cursor", pager: CursorPager{ItemsField: "data", CursorField: "next"}},
+			resp: resp{Items: []int{1, 1}, Pages: 2, HasErr: true},
+		},
+		{
+			name: "unsuccessful page",
+			args: args{path: "/broken", pager: LinkPager{}},
+			resp: resp{Items: nil, Pages: 0, HasErr: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := New(config.Client{URL: svr.URL + "/api"})
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+
+			var got resp
+			it := client.Paginate(test.args.path, nil, test.args.pager).MaxPages(test.args.maxPages)
+			for it.Next(context.Background()) {
+				var n int
+				if err := it.Decode(&n); err != nil {
+					t.Fatalf("Iterator.Decode() error = %s", err)
+				}
+				got.Items = append(got.Items, n)
+			}
+			got.Pages, got.HasErr = it.Pages(), it.Err() != nil
+
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Client.Paginate() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCursorPager_Next(t *testing.T) {
+	pager := CursorPager{CursorField: "next"}
+	tests := []struct {
+		name string
+		args string
+		resp string
+	}{
+		{name: "string", args: `{"next":"abc"}`, resp: "/items?cursor=abc"},
+		{name: "number", args: `{"next":42}`, resp: "/items?cursor=42"},
+		{name: "number past 2^53", args: `{"next":9007199254740993}`, resp: "/items?cursor=9007199254740993"},
+		{name: "empty", args: `{"next":""}`, resp: ""},
+		{name: "null", args: `{"next":null}`, resp: ""},
+		{name: "missing", args: `{}`, resp: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page := &Page{URL: &url.URL{Path: "/items"}, Body: json.RawMessage(test.args)}
+			next, err := pager.Next(page, nil)
+			if err != nil {
+				t.Fatalf("CursorPager.Next() error = %s", err)
+			}
+
+			got := ""
+			if next != nil {
+				got = next.String()
+			}
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("CursorPager.Next() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_nextLink(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		resp string
+	}{
+		{name: "no header", args: nil, resp: ""},
+		{name: "next only", args: []string{`<https://a.com/x?page=2>; rel="next"`}, resp: "https://a.com/x?page=2"},
+		{name: "several links", args: []string{`<https://a.com/x?page=1>; rel="prev", <https://a.com/x?page=3>; rel="next"`}, resp: "https://a.com/x?page=3"},
+		{name: "several header values", args: []string{`</x?page=1>; rel="first"`, `</x?page=2>; rel=next`}, resp: "/x?page=2"},
+		{name: "multiple relations", args: []string{`</x?page=2>; title="n"; rel="last next"`}, resp: "/x?page=2"},
+		{name: "no next", args: []string{`</x?page=1>; rel="prev"`}, resp: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := nextLink(test.args)
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("nextLink() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
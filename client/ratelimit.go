@@ -0,0 +1,212 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// the back off used when a "429 Too Many Requests" response does not
+// say how long to wait with a Retry-After header
+const defaultRetryAfter = time.Second
+
+type contextKey int
+
+const (
+	// marks the context of requests the limiter lets through, e.g. health
+	// probes
+	unlimitedKey contextKey = iota
+
+	// marks the context of requests that bypass the response cache, e.g.
+	// downloads
+	uncachedKey
+)
+
+// A token bucket and in-flight request limiter shared by every request a
+// client makes.
+type limiter struct {
+	mu sync.Mutex
+
+	// configured steady rate and bucket size
+	baseRate float64
+	burst    float64
+
+	// current rate, lowered while the dependency is throttling us
+	rate   float64
+	tokens float64
+	last   time.Time
+
+	// no tokens are handed out before retryAt and the lowered rate is
+	// kept until recoverAt
+	retryAt   time.Time
+	recoverAt time.Time
+
+	// semaphore of in-flight requests, nil when unlimited
+	inflight chan struct{}
+
+	now func() time.Time
+}
+
+// newLimiter
+// creates a limiter from the client's rate limit configs. A nil limiter is
+// returned when no limits are configured.
+func newLimiter(conf config.RateLimit) *limiter {
+	if conf.RequestsPerSecond <= 0 && conf.MaxConcurrent <= 0 {
+		return nil
+	}
+
+	l := &limiter{now: time.Now}
+	if conf.RequestsPerSecond > 0 {
+		l.baseRate, l.rate = conf.RequestsPerSecond, conf.RequestsPerSecond
+		l.burst = math.Max(float64(conf.Burst), 1)
+		l.tokens = l.burst
+		l.last = l.now()
+	}
+	if conf.MaxConcurrent > 0 {
+		l.inflight = make(chan struct{}, conf.MaxConcurrent)
+	}
+	return l
+}
+
+// wait
+// blocks until the request is allowed to be made or the context is done.
+// The returned release func must be called once the request is finished,
+// i.e. once its response body is read to the end or closed or the
+// client's hold time has passed. Calling it again does nothing.
+func (l *limiter) wait(ctx context.Context) (release func(), err error) {
+	if err := l.take(ctx); err != nil {
+		return nil, err
+	}
+
+	if l.inflight == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.inflight <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-l.inflight }) }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w, gave up waiting for an in-flight request slot", ctx.Err())
+	}
+}
+
+// observe
+// lowers the request rate when the dependency responds with
+// "429 Too Many Requests", honoring its Retry-After header.
+func (l *limiter) observe(resp *http.Response) {
+	if resp.StatusCode != http.StatusTooManyRequests || l.baseRate == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	delay := retryAfter(resp.Header.Get("Retry-After"), now)
+	if retryAt := now.Add(delay); retryAt.After(l.retryAt) {
+		l.retryAt = retryAt
+	}
+
+	// halve the rate every time we are told to slow down and keep it low
+	// for as long again as we were asked to wait
+	l.rate = math.Max(l.rate/2, l.baseRate/64)
+	l.recoverAt = l.retryAt.Add(delay)
+	l.tokens = 0
+	l.last = l.retryAt
+}
+
+/********** helper functions **********/
+
+// take
+// removes a token from the bucket, waiting for one to be added when the
+// bucket is empty.
+func (l *limiter) take(ctx context.Context) error {
+	if l.baseRate == 0 {
+		return nil
+	}
+
+	for {
+		l.mu.Lock()
+		now := l.now()
+		if !l.recoverAt.IsZero() && !now.Before(l.recoverAt) {
+			l.rate, l.recoverAt = l.baseRate, time.Time{}
+		}
+
+		// refill tokens for the time that has passed
+		if now.After(l.last) {
+			l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+			l.last = now
+		}
+
+		var delay time.Duration
+		switch {
+		case now.Before(l.retryAt):
+			delay = l.retryAt.Sub(now)
+		case l.tokens >= 1:
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		default:
+			delay = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w, gave up waiting for the rate limit", ctx.Err())
+		}
+	}
+}
+
+// retryAfter
+// parses a Retry-After header given in either seconds or as an HTTP date.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return defaultRetryAfter
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay
+		}
+		return 0
+	}
+	return defaultRetryAfter
+}
+
+// a response body that frees its request's in-flight slot once it is
+// read to the end or closed, or the client's hold time has passed,
+// whichever comes first
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b releaseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.release()
+	}
+	return n, err
+}
+
+func (b releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
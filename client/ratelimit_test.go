@@ -0,0 +1,317 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+func Test_limiter_wait(t *testing.T) {
+	type args struct {
+		conf     config.RateLimit
+		requests int
+		timeout  time.Duration
+	}
+	type resp struct {
+		MinElapsed time.Duration
+		HasErr     bool
+	}
+	tests := []struct {
+		name string
+		args args
+		resp resp
+	}{
+		{
+			name: "within burst",
+			args: args{conf: config.RateLimit{RequestsPerSecond: 10, Burst: 3}, requests: 3, timeout: time.Second},
+			resp: resp{MinElapsed: 0, HasErr: false},
+		},
+		{
+			name: "waits for tokens",
+			args: args{conf: config.RateLimit{RequestsPerSecond: 20, Burst: 1}, requests: 3, timeout: time.Second},
+			resp: resp{MinElapsed: 80 * time.Millisecond, HasErr: false},
+		},
+		{
+			name: "context canceled while waiting",
+			args: args{conf: config.RateLimit{RequestsPerSecond: 0.5}, requests: 2, timeout: 20 * time.Millisecond},
+			resp: resp{MinElapsed: 0, HasErr: true},
+		},
+		{
+			name: "in-flight limit reached",
+			args: args{conf: config.RateLimit{MaxConcurrent: 2}, requests: 3, timeout: 20 * time.Millisecond},
+			resp: resp{MinElapsed: 0, HasErr: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLimiter(test.args.conf)
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.args.timeout)
+			defer cancel()
+
+			var got resp
+			start := time.Now()
+			for i := 0; i < test.args.requests; i++ {
+				// requests are never released so in-flight slots stay taken
+				if _, err := l.wait(ctx); err != nil {
+					got.HasErr = true
+					break
+				}
+			}
+
+			if elapsed := time.Since(start); elapsed < test.resp.MinElapsed {
+				t.Errorf("limiter.wait() took %s, want at least %s", elapsed, test.resp.MinElapsed)
+			}
+			if diff := cmp.Diff(test.resp.HasErr, got.HasErr); diff != "" {
+				t.Errorf("limiter.wait() error mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_limiter_observe(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	l := newLimiter(config.RateLimit{RequestsPerSecond: 8, Burst: 4})
+	l.now = func() time.Time { return now }
+
+	l.observe(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}})
+	if l.rate != 8 {
+		t.Errorf("limiter.observe() rate = %v after 200, want 8", l.rate)
+	}
+
+	l.observe(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"2"}}})
+	type state struct {
+		Rate      float64
+		Tokens    float64
+		RetryAt   time.Time
+		RecoverAt time.Time
+	}
+	want := state{Rate: 4, Tokens: 0, RetryAt: now.Add(2 * time.Second), RecoverAt: now.Add(4 * time.Second)}
+	got := state{Rate: l.rate, Tokens: l.tokens, RetryAt: l.retryAt, RecoverAt: l.recoverAt}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("limiter.observe() mismatch (-want +got):\n%s", diff)
+	}
+
+	// the configured rate comes back once the recovery window has passed
+	now = now.Add(5 * time.Second)
+	if err := l.take(context.Background()); err != nil {
+		t.Fatalf("limiter.take() error = %s", err)
+	}
+	if l.rate != 8 {
+		t.Errorf("limiter.take() rate = %v after recovery, want 8", l.rate)
+	}
+}
+
+func Test_retryAfter(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		args string
+		resp time.Duration
+	}{
+		{name: "missing", args: "", resp: defaultRetryAfter},
+		{name: "seconds", args: "3", resp: 3 * time.Second},
+		{name: "http date", args: now.Add(10 * time.Second).Format(http.TimeFormat), resp: 10 * time.Second},
+		{name: "date in the past", args: now.Add(-time.Minute).Format(http.TimeFormat), resp: 0},
+		{name: "invalid", args: "soon", resp: defaultRetryAfter},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := retryAfter(test.args, now)
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("retryAfter() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClient_RateLimitRetryAfter(t *testing.T) {
+	var calls int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer svr.Close()
+
+	client, err := New(config.Client{
+		URL:       svr.URL,
+		RateLimit: config.RateLimit{RequestsPerSecond: 100, Burst: 10},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	resp, err := client.Get("/", nil)
+	if err != nil {
+		t.Fatalf("Client.Get() error = %s", err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Client.Get() status = %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+
+	// the next request has to wait out the Retry-After even though the
+	// bucket had tokens left
+	start := time.Now()
+	if _, err := client.Get("/", nil); err != nil {
+		t.Fatalf("Client.Get() error = %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("Client.Get() took %s after a 429, want at least 1s", elapsed)
+	}
+}
+
+func TestClient_MaxConcurrentStreams(t *testing.T) {
+	done := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// stream the headers and hold the body open
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer svr.Close()
+	defer close(done)
+
+	client, err := New(config.Client{URL: svr.URL, RateLimit: config.RateLimit{MaxConcurrent: 2}})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	get := func(timeout time.Duration) (*Response, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		t.Cleanup(cancel)
+		return client.GetWithContext(ctx, "/", nil)
+	}
+
+	var streams []*Response
+	for i := 0; i < 2; i++ {
+		resp, err := get(time.Second)
+		if err != nil {
+			t.Fatalf("Client.GetWithContext() error = %s", err)
+		}
+		streams = append(streams, resp)
+	}
+
+	// both slots are held while the bodies are open
+	if _, err := get(50 * time.Millisecond); err == nil {
+		t.Fatalf("Client.GetWithContext() error = nil, want the in-flight limit to be reached")
+	}
+
+	// closing a body frees its slot, and closing it again frees nothing
+	streams[0].Close() // nolint:errcheck
+	streams[0].Close() // nolint:errcheck
+	resp, err := get(time.Second)
+	if err != nil {
+		t.Fatalf("Client.GetWithContext() error = %s after a body was closed", err)
+	}
+	defer resp.Close() // nolint:errcheck
+	if _, err := get(50 * time.Millisecond); err == nil {
+		t.Errorf("Client.GetWithContext() error = nil, want a closed body to free a single slot")
+	}
+	streams[1].Close() // nolint:errcheck
+}
+
+func TestClient_MaxConcurrentReadBodies(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok")) // nolint:errcheck
+	}))
+	defer svr.Close()
+
+	client, err := New(config.Client{URL: svr.URL, RateLimit: config.RateLimit{MaxConcurrent: 2}})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	// bodies read to the end free their slots without being closed
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		resp, err := client.GetWithContext(ctx, "/", nil)
+		if err != nil {
+			cancel()
+			t.Fatalf("Client.GetWithContext() error = %s on request %d", err, i+1)
+		}
+		if got := string(resp.GetBodyBytes()); got != "ok" {
+			t.Errorf("Response.GetBodyBytes() = %q, want %q", got, "ok")
+		}
+		cancel()
+	}
+}
+
+func TestClient_MaxConcurrentUnclosed(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte("ok")) // nolint:errcheck
+	}))
+	defer svr.Close()
+
+	tests := []struct {
+		name    string
+		timeout int
+		call    func(c *Client) (*Response, error)
+		resp    time.Duration
+	}{
+		{
+			name: "no content",
+			call: func(c *Client) (*Response, error) { return c.Delete("/", nil) },
+			resp: 0,
+		},
+		{
+			name:    "unread body",
+			timeout: 1,
+			call:    func(c *Client) (*Response, error) { return c.Get("/", nil) },
+			resp:    time.Second,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := New(config.Client{URL: svr.URL, Timeout: test.timeout, RateLimit: config.RateLimit{MaxConcurrent: 1}})
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+
+			// responses are never read or closed, so only bodiless ones free
+			// their slot at once and the others after the client's timeout
+			done := make(chan error)
+			start := time.Now()
+			go func() {
+				for i := 0; i < 3; i++ {
+					if _, err := test.call(client); err != nil {
+						done <- err
+						return
+					}
+				}
+				done <- nil
+			}()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Client request error = %s", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Client request did not get an in-flight slot")
+			}
+			if elapsed := time.Since(start); elapsed < 2*test.resp || (test.resp == 0 && elapsed > time.Second) {
+				t.Errorf("3 unclosed requests took %s, want %s for each slot to be freed", elapsed, test.resp)
+			}
+		})
+	}
+}
@@ -15,6 +15,13 @@ type Response struct {
 	// http status code. Example 200
 	StatusCode int
 
+	// the response headers
+	Header http.Header
+
+	// how the response was served by the client's cache. Empty when the
+	// client has no cache or the request could not be cached.
+	CacheStatus CacheStatus
+
 	// the request that was received by a server or to be sent by a
 	// client
 	Request *http.Request
@@ -40,6 +47,13 @@ func (r *Response) GetBodyString() string {
 	return string(bodyBytes)
 }
 
+// Close
+// closes the response body. It should be called once the body is no
+// longer needed, especially when streaming it with GetBody().
+func (r *Response) Close() error {
+	return r.body.Close()
+}
+
 // IsSuccessful
 // checks if the response status code is 200 level.
 func (r *Response) IsSuccessful() bool {
@@ -6,11 +6,10 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/jobaldw/shared/v2/config"
-	"github.com/jobaldw/shared/v2/internal/test/mock"
 )
 
 func TestClient_GetBody(t *testing.T) {
-	svr := mock.Server()
+	svr := mockServer()
 	defer svr.Close()
 
 	type client config.Client
@@ -59,7 +58,7 @@ func TestClient_GetBody(t *testing.T) {
 }
 
 func TestClient_GetBodyBytes(t *testing.T) {
-	svr := mock.Server()
+	svr := mockServer()
 	defer svr.Close()
 
 	type client config.Client
@@ -108,7 +107,7 @@ func TestClient_GetBodyBytes(t *testing.T) {
 }
 
 func TestClient_GetBodyString(t *testing.T) {
-	svr := mock.Server()
+	svr := mockServer()
 	defer svr.Close()
 
 	type client config.Client
@@ -0,0 +1,280 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrDownloadChanged = errors.New("resource changed while resuming download")    // the server sent a different version of the file
+	ErrRangeMismatch   = errors.New("server resumed download at the wrong offset") // the Content-Range did not start where we asked
+)
+
+// A file sent as part of a multipart/form-data upload.
+type File struct {
+	// form field name of the file
+	Field string
+
+	// file name sent to the server
+	Name string
+
+	// MIME type of the file. Defaults to "application/octet-stream".
+	ContentType string
+
+	// the file's contents, streamed as the request is sent
+	Content io.Reader
+}
+
+// Options for downloading a response body into an io.Writer.
+type DownloadOptions struct {
+	// Number of bytes already downloaded, e.g. the size of a partially
+	// downloaded file. The download asks the server for the rest of the
+	// body starting at this offset.
+	Offset int64
+
+	// Number of times a download interrupted by a network error is
+	// resumed with a Range request. Downloads are only resumed when the
+	// server supports byte ranges. Zero disables resuming.
+	MaxResumes int
+
+	// Called after every write with the number of bytes downloaded,
+	// including the offset, and the total size of the body or -1 when it
+	// is unknown.
+	Progress func(downloaded, total int64)
+}
+
+// Upload
+// streams a multipart/form-data POST request with the given form fields
+// and files. Files are read as the request is sent, so they are never
+// held in memory.
+func (c *Client) Upload(ctx context.Context, path string, params map[string][]string, fields map[string]string, files []File, opts ...RequestOption) (*Response, error) {
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeForm(form, fields, files))
+	}()
+
+	opts = append(opts[:len(opts):len(opts)], WithHeader("Content-Type", form.FormDataContentType()))
+	resp, err := c.send(ctx, http.MethodPost, c.resolve(path, params), pr, opts...)
+	if err != nil {
+		// release the form writer when the request failed before its body
+		// was read
+		pr.CloseWithError(err)
+	}
+	return resp, err
+}
+
+// Download
+// streams a GET response body into w and returns the number of bytes
+// written to it. Non 200 level responses are returned as errors.
+//
+// When the server supports byte ranges, a download can start from an
+// offset and downloads interrupted by network errors are resumed where
+// they left off. Downloads bypass the response cache so their bodies are
+// never held in memory.
+func (c *Client) Download(ctx context.Context, path string, params map[string][]string, w io.Writer, do DownloadOptions, opts ...RequestOption) (int64, error) {
+	uri := c.resolve(path, params)
+	ctx = context.WithValue(ctx, uncachedKey, true)
+
+	var (
+		downloaded = do.Offset
+		total      = int64(-1)
+		validator  string // ETag or Last-Modified of the first response
+		resumable  = do.Offset > 0
+	)
+	for attempt := 0; ; attempt++ {
+		reqOpts := opts[:len(opts):len(opts)]
+		if downloaded > 0 {
+			reqOpts = append(reqOpts, WithHeader("Range", fmt.Sprintf("bytes=%d-", downloaded)))
+			if validator != "" {
+				reqOpts = append(reqOpts, WithHeader("If-Range", validator))
+			}
+		}
+
+		resp, err := c.send(ctx, http.MethodGet, uri, nil, reqOpts...)
+		if err != nil {
+			return downloaded - do.Offset, err
+		}
+
+		var skip int64
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			start, size, err := contentRange(resp.Header.Get("Content-Range"))
+			if err != nil || start != downloaded {
+				resp.Close() // nolint:errcheck
+				return downloaded - do.Offset, fmt.Errorf("%s: %w", packageKey, ErrRangeMismatch)
+			}
+			total, resumable = size, true
+		case http.StatusOK:
+			// the server ignored the range and sent the whole body
+			if downloaded > 0 && validator != "" {
+				resp.Close() // nolint:errcheck
+				return downloaded - do.Offset, fmt.Errorf("%s: %w", packageKey, ErrDownloadChanged)
+			}
+			skip, total = downloaded, contentLength(resp.Header)
+			resumable = resp.Header.Get("Accept-Ranges") == "bytes"
+		case http.StatusRequestedRangeNotSatisfiable:
+			// the offset is already at the end of the body
+			if _, size, err := contentRange(resp.Header.Get("Content-Range")); err == nil && size == downloaded {
+				resp.Close() // nolint:errcheck
+				return downloaded - do.Offset, nil
+			}
+			fallthrough
+		default:
+			resp.Close() // nolint:errcheck
+			return downloaded - do.Offset, fmt.Errorf("%s: download returned %s", packageKey, resp.Status)
+		}
+
+		if validator == "" {
+			validator = rangeValidator(resp.Header)
+		}
+
+		err = copyBody(w, resp.body, skip, func(n int64) {
+			downloaded += n
+			if do.Progress != nil {
+				do.Progress(downloaded, total)
+			}
+		})
+		resp.Close() // nolint:errcheck
+
+		switch {
+		case err == nil:
+			return downloaded - do.Offset, nil
+		case resumable && attempt < do.MaxResumes && ctx.Err() == nil:
+			continue
+		default:
+			return downloaded - do.Offset, fmt.Errorf("%s: %w, download interrupted", packageKey, err)
+		}
+	}
+}
+
+/********** helper functions **********/
+
+// writeForm
+// writes the form fields, sorted by name, and then the files.
+func writeForm(form *multipart.Writer, fields map[string]string, files []File) error {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := form.WriteField(k, fields[k]); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(file.Field), escapeQuotes(file.Name)))
+		header.Set("Content-Type", contentType)
+
+		part, err := form.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, file.Content); err != nil {
+			return fmt.Errorf("%w, could not read file %s", err, file.Name)
+		}
+	}
+	return form.Close()
+}
+
+// copyBody
+// copies the body into w after discarding the first skip bytes, calling
+// written after every write.
+func copyBody(w io.Writer, body io.Reader, skip int64, written func(n int64)) error {
+	if skip > 0 {
+		if _, err := io.CopyN(io.Discard, body, skip); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			written(int64(n))
+		}
+
+		switch {
+		case readErr == io.EOF:
+			return nil
+		case readErr != nil:
+			return readErr
+		}
+	}
+}
+
+// contentRange
+// parses the start offset and full size from a "bytes 0-99/1000" or
+// "bytes */1000" Content-Range header. The size is -1 when unknown.
+func contentRange(header string) (start, size int64, err error) {
+	if !strings.HasPrefix(header, "bytes ") {
+		return 0, 0, fmt.Errorf("invalid content range %q", header)
+	}
+
+	rng, length, ok := strings.Cut(strings.TrimPrefix(header, "bytes "), "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid content range %q", header)
+	}
+
+	size = -1
+	if length != "*" {
+		if size, err = strconv.ParseInt(length, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid content range %q", header)
+		}
+	}
+
+	if rng == "*" {
+		return 0, size, nil
+	}
+	first, _, _ := strings.Cut(rng, "-")
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid content range %q", header)
+	}
+	return start, size, nil
+}
+
+// contentLength
+// returns the Content-Length header or -1 when it is missing.
+func contentLength(header http.Header) int64 {
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return length
+}
+
+// rangeValidator
+// returns the strong ETag or Last-Modified date used to make sure a
+// resumed download is of the same version of the resource.
+func rangeValidator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
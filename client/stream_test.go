@@ -0,0 +1,296 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+func TestClient_PostReader(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		io.Copy(w, r.Body) // nolint:errcheck
+	}))
+	defer svr.Close()
+
+	client, err := New(config.Client{URL: svr.URL})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	resp, err := client.Post("/", nil, strings.NewReader("raw,csv,data"), WithHeader("Content-Type", "text/csv"))
+	if err != nil {
+		t.Fatalf("Client.Post() error = %s", err)
+	}
+	defer resp.Close()
+
+	type result struct {
+		Body        string
+		ContentType string
+	}
+	want := result{Body: "raw,csv,data", ContentType: "text/csv"}
+	got := result{Body: resp.GetBodyString(), ContentType: resp.Header.Get("Content-Type")}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Client.Post() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_Upload(t *testing.T) {
+	type part struct {
+		Field, File, ContentType, Content string
+	}
+	var got []part
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for {
+			p, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			b, _ := io.ReadAll(p)
+			got = append(got, part{Field: p.FormName(), File: p.FileName(), ContentType: p.Header.Get("Content-Type"), Content: string(b)})
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer svr.Close()
+
+	client, err := New(config.Client{URL: svr.URL})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	resp, err := client.Upload(context.Background(), "/upload", nil,
+		map[string]string{"owner": "jb", "album": "2022"},
+		[]File{
+			{Field: "photo", Name: "cat.png", ContentType: "image/png", Content: bytes.NewReader([]byte("png bytes"))},
+			{Field: "notes", Name: "notes.txt", Content: strings.NewReader("some notes")},
+		},
+	)
+	if err != nil {
+		t.Fatalf("Client.Upload() error = %s", err)
+	}
+	resp.Close() // nolint:errcheck
+
+	want := []part{
+		{Field: "album", ContentType: "", Content: "2022"},
+		{Field: "owner", ContentType: "", Content: "jb"},
+		{Field: "photo", File: "cat.png", ContentType: "image/png", Content: "png bytes"},
+		{Field: "notes", File: "notes.txt", ContentType: "application/octet-stream", Content: "some notes"},
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Client.Upload() status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Client.Upload() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_Upload_SendFails(t *testing.T) {
+	// a transport that fails without reading or closing the request body
+	var body io.Reader
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body = req.Body
+		return nil, errors.New("connection refused")
+	})
+
+	client, err := New(config.Client{URL: "http://users.local"}, WithTransport(transport))
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	_, err = client.Upload(context.Background(), "/upload", nil, map[string]string{"owner": "jb"}, nil)
+	if err == nil {
+		t.Fatal("Client.Upload() error = nil, want the transport's error")
+	}
+
+	// the form writer is released instead of blocking on the pipe forever
+	if body == nil {
+		t.Fatal("Client.Upload() did not send the request")
+	}
+	if n, err := body.Read(make([]byte, 64)); err == nil {
+		t.Errorf("Client.Upload() body still readable, read %d bytes", n)
+	}
+}
+
+func TestClient_Download(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	modified := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	var requests int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			// the first response is cut off half way through the body
+			if atomic.AddInt32(&requests, 1) == 1 {
+				w.Header().Set("Accept-Ranges", "bytes")
+				w.Header().Set("Content-Length", "100000")
+				w.Header().Set("ETag", `"v1"`)
+				io.WriteString(w, content[:50000]) // nolint:errcheck
+				panic(http.ErrAbortHandler)
+			}
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, "file.txt", modified, strings.NewReader(content))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			http.ServeContent(w, r, "file.txt", modified, strings.NewReader(content))
+		}
+	}))
+	defer svr.Close()
+
+	type args struct {
+		path string
+		do   DownloadOptions
+	}
+	type resp struct {
+		Written int64
+		Body    string
+		HasErr  bool
+	}
+	tests := []struct {
+		name string
+		args args
+		resp resp
+	}{
+		{
+			name: "whole body",
+			args: args{path: "/file", do: DownloadOptions{}},
+			resp: resp{Written: 100000, Body: content},
+		},
+		{
+			name: "from offset",
+			args: args{path: "/file", do: DownloadOptions{Offset: 99990}},
+			resp: resp{Written: 10, Body: content[99990:]},
+		},
+		{
+			name: "offset at end",
+			args: args{path: "/file", do: DownloadOptions{Offset: 100000}},
+			resp: resp{Written: 0, Body: ""},
+		},
+		{
+			name: "resumes interrupted download",
+			args: args{path: "/flaky", do: DownloadOptions{MaxResumes: 1}},
+			resp: resp{Written: 100000, Body: content},
+		},
+		{
+			name: "not found",
+			args: args{path: "/missing", do: DownloadOptions{}},
+			resp: resp{Written: 0, Body: "", HasErr: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := New(config.Client{URL: svr.URL})
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+
+			var (
+				buf      bytes.Buffer
+				progress int64
+				got      resp
+			)
+			test.args.do.Progress = func(downloaded, total int64) {
+				if total != int64(len(content)) {
+					t.Errorf("Progress() total = %d, want %d", total, len(content))
+				}
+				progress = downloaded
+			}
+
+			got.Written, err = client.Download(context.Background(), test.args.path, nil, &buf, test.args.do)
+			got.Body, got.HasErr = buf.String(), err != nil
+
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Client.Download() mismatch (-want +got):\n%s", diff)
+			}
+			if got.Written > 0 && progress != test.args.do.Offset+got.Written {
+				t.Errorf("Client.Download() last progress = %d, want %d", progress, test.args.do.Offset+got.Written)
+			}
+		})
+	}
+}
+
+func TestClient_DownloadCached(t *testing.T) {
+	// the second half of the body is only sent once the first was seen
+	firstHalf := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, strings.Repeat("a", 1000)) // nolint:errcheck
+		w.(http.Flusher).Flush()
+		select {
+		case <-firstHalf:
+		case <-time.After(2 * time.Second):
+		}
+		io.WriteString(w, strings.Repeat("b", 1000)) // nolint:errcheck
+	}))
+	defer svr.Close()
+
+	client, err := New(config.Client{URL: svr.URL}, WithCache(NewLRU(10, 1<<20)))
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	// the download streams past the cache instead of being buffered by it
+	start := time.Now()
+	var once sync.Once
+	do := DownloadOptions{Progress: func(downloaded, total int64) {
+		if downloaded >= 1000 {
+			once.Do(func() { close(firstHalf) })
+		}
+	}}
+	var buf bytes.Buffer
+	if _, err := client.Download(context.Background(), "/file", nil, &buf, do); err != nil {
+		t.Fatalf("Client.Download() error = %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Client.Download() took %s, want progress before the whole body arrived", elapsed)
+	}
+	if buf.Len() != 2000 {
+		t.Errorf("Client.Download() wrote %d bytes, want 2000", buf.Len())
+	}
+}
+
+func Test_contentRange(t *testing.T) {
+	type resp struct {
+		Start, Size int64
+		HasErr      bool
+	}
+	tests := []struct {
+		name string
+		args string
+		resp resp
+	}{
+		{name: "range", args: "bytes 100-199/1000", resp: resp{Start: 100, Size: 1000}},
+		{name: "unknown size", args: "bytes 100-199/*", resp: resp{Start: 100, Size: -1}},
+		{name: "unsatisfied", args: "bytes */1000", resp: resp{Start: 0, Size: 1000}},
+		{name: "invalid unit", args: "items 0-1/2", resp: resp{HasErr: true}},
+		{name: "invalid size", args: "bytes 0-1/x", resp: resp{HasErr: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got resp
+			var err error
+			got.Start, got.Size, err = contentRange(test.args)
+			got.HasErr = err != nil
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("contentRange() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jobaldw/shared/v2/tracing"
+)
+
+// WithTracerProvider
+// starts the client's spans with a tracer of the given provider instead
+// of the global one. A nil provider turns the client's spans off; the
+// trace context of a request's context is still passed on.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *Client) {
+		c.tracer = nil
+		if provider != nil {
+			c.tracer = tracing.Tracer(provider)
+		}
+	}
+}
+
+/********** helper functions **********/
+
+// startSpan
+// starts a client span for a request, named after its method (e.g.
+// "HTTP GET"). Retries, hedged requests and cached responses are part of
+// the same span. The span records nothing when the client's spans are off.
+func (c *Client) startSpan(ctx context.Context, method string, uri *url.URL) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return c.tracer.Start(ctx, "HTTP "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(method),
+			semconv.HTTPURLKey.String(logURL(uri)),
+			semconv.PeerServiceKey.String(c.name),
+		),
+	)
+}
+
+// endSpan
+// records the outcome of a request on its span. Requests that got no
+// response or a server error have failed.
+func endSpan(span trace.Span, resp *Response, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	if resp == nil {
+		return
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+}
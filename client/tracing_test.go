@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jobaldw/shared/v2/config"
+	"github.com/jobaldw/shared/v2/testing/httpmock"
+	"github.com/jobaldw/shared/v2/tracing"
+)
+
+func TestClient_Tracing(t *testing.T) {
+	svr := httpmock.NewServer()
+	defer svr.Close()
+	svr.On(http.MethodGet, "/users").Respond(http.StatusOK)
+	svr.On(http.MethodGet, "/down").Respond(http.StatusServiceUnavailable)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "GET /profile")
+
+	client, err := New(config.Client{URL: svr.URL}, WithName("users"), WithTracerProvider(provider))
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+	for _, path := range []string{"/users", "/down"} {
+		resp, err := client.GetWithContext(ctx, path, map[string][]string{"token": {"secret"}})
+		if err != nil {
+			t.Fatalf("Client.Get() error = %s", err)
+		}
+		resp.Close() // nolint:errcheck
+	}
+
+	type span struct {
+		Name       string
+		Kind       trace.SpanKind
+		Parent     bool
+		Attributes map[string]interface{}
+		Status     codes.Code
+		Sent       bool // the span's trace context was sent in the request
+	}
+	var got []span
+	calls := svr.Calls()
+	for i, s := range exporter.GetSpans() {
+		traceparent := "00-" + s.SpanContext.TraceID().String() + "-" + s.SpanContext.SpanID().String() + "-01"
+		got = append(got, span{
+			Name:       s.Name,
+			Kind:       s.SpanKind,
+			Parent:     s.Parent.SpanID() == parent.SpanContext().SpanID(),
+			Attributes: attributes(s.Attributes),
+			Status:     s.Status.Code,
+			Sent:       calls[i].Header.Get(tracing.TraceparentHeader) == traceparent,
+		})
+	}
+
+	want := []span{
+		{Name: "HTTP GET", Kind: trace.SpanKindClient, Parent: true, Sent: true, Attributes: map[string]interface{}{
+			"http.method": "GET", "http.url": svr.URL + "/users", "http.status_code": int64(200), "peer.service": "users",
+		}},
+		{Name: "HTTP GET", Kind: trace.SpanKindClient, Parent: true, Sent: true, Status: codes.Error, Attributes: map[string]interface{}{
+			"http.method": "GET", "http.url": svr.URL + "/down", "http.status_code": int64(503), "peer.service": "users",
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Client tracing mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_TracingOff(t *testing.T) {
+	svr := httpmock.NewServer()
+	defer svr.Close()
+	svr.On(http.MethodGet, "/users").Respond(http.StatusOK)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "GET /profile")
+
+	client, err := New(config.Client{URL: svr.URL}, WithTracerProvider(nil))
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+	resp, err := client.GetWithContext(ctx, "/users", nil)
+	if err != nil {
+		t.Fatalf("Client.Get() error = %s", err)
+	}
+	resp.Close() // nolint:errcheck
+
+	// no client span is started but the caller's trace is passed on
+	if got := exporter.GetSpans(); len(got) != 0 {
+		t.Errorf("exported %d spans with tracing off, want 0", len(got))
+	}
+	want := "00-" + parent.SpanContext().TraceID().String() + "-" + parent.SpanContext().SpanID().String() + "-01"
+	if got := svr.Calls()[0].Header.Get(tracing.TraceparentHeader); got != want {
+		t.Errorf("traceparent header = %q, want %q", got, want)
+	}
+}
+
+// attributes
+// returns the span attributes by key.
+func attributes(kvs []attribute.KeyValue) map[string]interface{} {
+	m := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		m[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return m
+}
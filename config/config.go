@@ -46,10 +46,108 @@ type Application struct {
 	// Server port that the microservice communicates through.
 	Port int `json:"port,omitempty"`
 
+	// Application version added to every log, e.g. a release tag or
+	// commit hash.
+	Version string `json:"version,omitempty"`
+
 	// Used to set logging severity. Field is a string value to users can
 	// use this value with any logging packages such as zerolog, logrus,
 	// viper or an internal logging package.
 	LogLevel string `json:"log_level,omitempty"`
+
+	// Log output format, either "json", the default, or "console" for
+	// human readable logs during development.
+	LogFormat string `json:"log_format,omitempty"`
+
+	// Only every nth debug and info log is written when set above 1.
+	// Warnings and errors are never sampled.
+	LogSample int `json:"log_sample,omitempty"`
+
+	// Optional server timeouts. An omitted timeout uses the default
+	// described on each field.
+	Timeouts Timeouts `json:"timeouts,omitempty"`
+
+	// Optional configs for how the readiness endpoint checks the
+	// application's dependencies.
+	Readiness Readiness `json:"readiness,omitempty"`
+
+	// Optional cross-origin resource sharing policy. An omitted policy
+	// means cross-origin requests are not allowed.
+	CORS CORS `json:"cors,omitempty"`
+}
+
+// The CORS struct configures which cross-origin requests browsers may make
+// to a server.
+type CORS struct {
+	// Origins allowed to make cross-origin requests, e.g.
+	// "https://app.example.com". "*" allows any origin, and an origin may
+	// hold one "*" wildcard, e.g. "https://*.example.com".
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+
+	// Methods allowed in cross-origin requests. Defaults to GET, POST and
+	// HEAD when omitted.
+	AllowedMethods []string `json:"allowed_methods,omitempty"`
+
+	// Non-simple headers allowed in cross-origin requests. "*" allows any
+	// header.
+	AllowedHeaders []string `json:"allowed_headers,omitempty"`
+
+	// Response headers browsers let cross-origin requests read.
+	ExposedHeaders []string `json:"exposed_headers,omitempty"`
+
+	// Allows cross-origin requests to carry cookies and credentials.
+	AllowCredentials bool `json:"allow_credentials,omitempty"`
+
+	// Number of seconds browsers may cache a preflight request's result.
+	// Zero or omitted means browsers decide.
+	MaxAge int `json:"max_age,omitempty"`
+}
+
+// The readiness struct configures the dependency checks made by a
+// server's readiness endpoint.
+type Readiness struct {
+	// A time limit in seconds for each dependency check. Defaults to 5
+	// seconds when zero or omitted.
+	Timeout int `json:"timeout,omitempty"`
+
+	// Names of the dependencies the application can run without. Their
+	// failures mark the application as degraded instead of not ready.
+	Optional []string `json:"optional,omitempty"`
+
+	// How often in seconds dependencies are checked in the background.
+	// The readiness endpoint then reports the latest results instead of
+	// checking on every request. Zero or omitted disables background
+	// checks.
+	Interval int `json:"interval,omitempty"`
+}
+
+// The timeouts struct configures how long a server waits on clients and
+// how gracefully it shuts down. All values are in seconds.
+type Timeouts struct {
+	// Time allowed to read a whole request, including its body. Zero or
+	// omitted means no timeout.
+	Read int `json:"read,omitempty"`
+
+	// Time allowed to read a request's headers. Defaults to 10 seconds
+	// when zero or omitted.
+	ReadHeader int `json:"read_header,omitempty"`
+
+	// Time allowed to write a response. Zero or omitted means no timeout.
+	Write int `json:"write,omitempty"`
+
+	// Time an idle keep-alive connection is kept open. Defaults to 120
+	// seconds when zero or omitted.
+	Idle int `json:"idle,omitempty"`
+
+	// Time the readiness endpoint fails before the server stops accepting
+	// connections, giving load balancers time to stop sending requests.
+	// Zero or omitted means no delay.
+	ShutdownDelay int `json:"shutdown_delay,omitempty"`
+
+	// Time in-flight requests and then the shutdown hooks are given to
+	// finish when the server shuts down, together. Defaults to 30 seconds
+	// when zero or omitted.
+	Shutdown int `json:"shutdown,omitempty"`
 }
 
 // Holds multiple Client objects that can be used within the app via a map
@@ -68,9 +166,21 @@ type Client struct {
 	// values in Header may be ignored.
 	Headers map[string][]string `json:"headers,omitempty"`
 
+	// Optional in-memory cache of GET responses. An omitted cache means
+	// responses are never cached.
+	Cache Cache `json:"cache,omitempty"`
+
 	// Health check path used for pinging the client
 	Health string `json:"health,omitempty"`
 
+	// Optional rules a response from the health check path has to pass
+	// for the client to be considered up.
+	HealthCheck HealthCheck `json:"health_check,omitempty"`
+
+	// Optional limits on how fast and how many requests the client may
+	// make. An omitted rate limit means requests are never throttled.
+	RateLimit RateLimit `json:"rate_limit,omitempty"`
+
 	// A time limit for requests made by the client. The duration includes
 	// connection time, redirects and reading the response. A Timeout of
 	// zero or omitted means no timeout.
@@ -78,6 +188,131 @@ type Client struct {
 
 	// The client's base url.
 	URL string `json:"url,omitempty"`
+
+	// Fallback base urls used when the base url is down, in order of
+	// priority. They are also part of the round robin rotation.
+	URLs []string `json:"urls,omitempty"`
+
+	// How requests pick between the base and fallback urls. Either
+	// "priority", the default, or "round_robin".
+	Selection string `json:"selection,omitempty"`
+
+	// Number of seconds a url that failed is left out of rotation.
+	// Defaults to 30 seconds when zero or omitted.
+	Cooldown int `json:"cooldown,omitempty"`
+
+	// Optional policy for sending backup requests when a response is
+	// slow. An omitted policy means requests are never hedged.
+	Hedging Hedging `json:"hedging,omitempty"`
+
+	// Optional failures injected into the client's requests to test how
+	// an application copes with a failing dependency. Should never be set
+	// in production.
+	Faults Faults `json:"faults,omitempty"`
+}
+
+// The faults struct configures failures injected into a client's
+// requests. Each rule that matches a request gets a chance to fire and
+// the first one that does is applied.
+type Faults struct {
+	// Seed for deciding which requests fail. The same seed fails the same
+	// requests on every run. A random seed is used when zero or omitted.
+	Seed int64 `json:"seed,omitempty"`
+
+	// The fault rules, tried in order.
+	Rules []FaultRule `json:"rules,omitempty"`
+}
+
+// The fault rule struct describes a failure and the requests it applies
+// to.
+type FaultRule struct {
+	// Request method the rule applies to. Any method when omitted.
+	Method string `json:"method,omitempty"`
+
+	// Request path pattern the rule applies to, with "*" matching a
+	// single path segment, e.g. "/users/*". Any path when omitted.
+	Path string `json:"path,omitempty"`
+
+	// Chance between 0 and 1 of the fault being injected into a matching
+	// request. Every matching request fails when omitted and none does
+	// when zero, which turns the rule off.
+	Probability *float64 `json:"probability,omitempty"`
+
+	// Delay in milliseconds added before the request is made.
+	Latency int `json:"latency_ms,omitempty"`
+
+	// Kind of failure, either "reset", "timeout" or "truncate". Omitted
+	// when the rule only adds latency or responds with a status.
+	Fault string `json:"fault,omitempty"`
+
+	// Status code responded with instead of making the request.
+	Status int `json:"status,omitempty"`
+}
+
+// The hedging struct configures backup requests for idempotent requests.
+// If a response has not been received once the delay has passed, another
+// request is sent, to the next url when there is one, and whichever
+// response comes back first is used.
+type Hedging struct {
+	// Percentile of recent request latencies, e.g. 95, used as the delay
+	// before a backup request is sent.
+	Percentile float64 `json:"percentile,omitempty"`
+
+	// Delay in milliseconds before a backup request is sent. Used until
+	// enough latencies are recorded for the percentile or when no
+	// percentile is set.
+	Delay int `json:"delay_ms,omitempty"`
+
+	// Maximum number of backup requests sent for a single request.
+	// Defaults to 1 when a percentile or delay is set.
+	MaxRequests int `json:"max_requests,omitempty"`
+}
+
+// The cache struct bounds a client's in-memory response cache. The least
+// recently used responses are evicted once either limit is reached.
+type Cache struct {
+	// Maximum number of cached responses.
+	MaxEntries int `json:"max_entries,omitempty"`
+
+	// Maximum combined size in bytes of the cached responses.
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+}
+
+// The health check struct configures how a client's health check path is
+// probed and what a healthy response looks like.
+type HealthCheck struct {
+	// A time limit in seconds for a single health check. Defaults to 5
+	// seconds when zero or omitted.
+	Timeout int `json:"timeout,omitempty"`
+
+	// Status codes of a healthy response. Any 200 level status is healthy
+	// when omitted.
+	ExpectedStatus []int `json:"expected_status,omitempty"`
+
+	// Regular expression a healthy response body must match.
+	ExpectedBody string `json:"expected_body,omitempty"`
+
+	// Map of dot separated JSON field paths to the value they must have in
+	// a healthy response body, e.g. {"status": "UP"}.
+	ExpectedFields map[string]string `json:"expected_fields,omitempty"`
+}
+
+// The rate limit struct configures a client side token bucket. Tokens are
+// added at a steady rate up to the burst size and every request takes one.
+type RateLimit struct {
+	// Steady number of requests allowed per second. Zero or omitted
+	// disables the token bucket.
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+
+	// Maximum number of requests that can be made at once after the
+	// client has been idle. Defaults to 1 when a rate is set.
+	Burst int `json:"burst,omitempty"`
+
+	// Maximum number of requests in flight at the same time. A request is
+	// in flight until its response body is read to the end or closed, or
+	// for at most the client's timeout, a minute when it has none. Zero or
+	// omitted means no limit.
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
 }
 
 // Holds multiple Mongo objects that can be used within the app via a map
@@ -0,0 +1,67 @@
+/*
+Package health implements dependency health checks that can be registered
+with a Registry and reported by a readiness endpoint.
+
+Any dependency can be checked by implementing the Checker interface.
+Adapters are provided for client.Client and plain functions; mongo.Mongo
+connections are checked with the mongocheck subpackage.
+
+All functions that require a context to be passed should be given one from
+the service handler request to correctly handle cancellations.
+*/
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jobaldw/shared/v2/client"
+)
+
+// package logging key
+const packageKey = "health"
+
+// a check failed without saying why
+var ErrDown = errors.New("dependency is down")
+
+// Reports whether an application or one of its dependencies is healthy.
+type Status string
+
+const (
+	StatusUp       Status = "UP"       // every dependency is up
+	StatusDegraded Status = "DEGRADED" // only non-critical dependencies are down
+	StatusDown     Status = "DOWN"     // a critical dependency is down
+)
+
+// A Checker checks the health of a single dependency. A nil error means
+// the dependency is up. Checks should return once the context is done.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc
+// adapts a plain function to the Checker interface, e.g. to ping a queue
+// or a cache.
+type CheckerFunc func(ctx context.Context) error
+
+// Check
+// implements the Checker interface.
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Client
+// checks a client with its health check.
+func Client(c *client.Client) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		result := c.Health(ctx)
+		switch {
+		case result.IsUp():
+			return nil
+		case result.Err != nil:
+			return result.Err
+		}
+		return fmt.Errorf("%s: %w", packageKey, ErrDown)
+	})
+}
@@ -0,0 +1,17 @@
+/*
+Package mongocheck implements a health check for mongo connections. It is
+kept apart from the health package so applications that do not use mongo
+do not link the mongo driver.
+*/
+package mongocheck
+
+import (
+	"github.com/jobaldw/shared/v2/health"
+	"github.com/jobaldw/shared/v2/mongo"
+)
+
+// New
+// checks a mongo connection by pinging its database.
+func New(m *mongo.Mongo) health.Checker {
+	return health.CheckerFunc(m.PingWithContext)
+}
@@ -0,0 +1,14 @@
+package mongocheck
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jobaldw/shared/v2/mongo"
+)
+
+func TestNew(t *testing.T) {
+	if err := New(&mongo.Mongo{}).Check(context.Background()); err == nil {
+		t.Error("Check() error = nil, want an error for a connection without a database")
+	}
+}
@@ -0,0 +1,274 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// time limit for a single check when none is configured
+const defaultTimeout = 5 * time.Second
+
+// The result of checking a single dependency.
+type Result struct {
+	Name     string
+	Status   Status
+	Critical bool
+
+	// how long the check took
+	Latency time.Duration
+
+	// when the check finished
+	CheckedAt time.Time
+
+	// why the check failed, if it did
+	Err error
+}
+
+// The results of checking every registered dependency.
+type Report struct {
+	// the overall status, DOWN when any critical dependency is down and
+	// DEGRADED when only non-critical ones are
+	Status Status
+
+	// the result of every check, sorted by name
+	Results []Result
+}
+
+// A Registry holds the checks of an application's dependencies. It is safe
+// for concurrent use.
+type Registry struct {
+	timeout  time.Duration
+	interval time.Duration
+	optional map[string]bool
+
+	mu      sync.Mutex
+	checks  []registered
+	cached  *Report
+	started bool
+
+	// the context background checks run with while started
+	ctx context.Context
+}
+
+// A check added to the registry.
+type registered struct {
+	name     string
+	checker  Checker
+	critical bool
+}
+
+// Option
+// customizes a check when it is registered.
+type Option func(*registered)
+
+// NonCritical
+// marks a dependency the application can run without. Its failures
+// degrade the report instead of marking it down.
+func NonCritical() Option {
+	return func(r *registered) {
+		r.critical = false
+	}
+}
+
+// NewRegistry
+// creates an empty registry from the readiness configs. Dependencies named
+// in the optional configs are registered as non-critical.
+func NewRegistry(conf config.Readiness) *Registry {
+	r := &Registry{
+		timeout:  defaultTimeout,
+		interval: time.Duration(conf.Interval) * time.Second,
+		optional: make(map[string]bool, len(conf.Optional)),
+	}
+	if conf.Timeout > 0 {
+		r.timeout = time.Duration(conf.Timeout) * time.Second
+	}
+	for _, name := range conf.Optional {
+		r.optional[name] = true
+	}
+	return r
+}
+
+// Register
+// adds a dependency check to the registry, replacing any check with the
+// same name. Dependencies are critical unless made optional. Once the
+// registry has been started, only the new check is run in the background
+// and its result is added to the cached results.
+func (r *Registry) Register(name string, checker Checker, opts ...Option) {
+	check := registered{name: name, checker: checker, critical: !r.optional[name]}
+	for _, opt := range opts {
+		opt(&check)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started {
+		go r.refresh(r.ctx, check)
+	}
+
+	for i := range r.checks {
+		if r.checks[i].name == name {
+			r.checks[i] = check
+			return
+		}
+	}
+	r.checks = append(r.checks, check)
+}
+
+// Check
+// reports the health of every registered dependency. Once the registry
+// has been started the latest background results are returned straight
+// away, otherwise every dependency is checked concurrently, each bound by
+// the timeout and the passed in context.
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.Lock()
+	cached := r.cached
+	r.mu.Unlock()
+
+	if cached != nil {
+		return *cached
+	}
+	return r.run(ctx)
+}
+
+// Start
+// checks the dependencies in the background every interval until the
+// context is done, caching the results for Check(). Nothing is started
+// when no interval is configured or the registry is already started.
+func (r *Registry) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.interval <= 0 || r.started {
+		return
+	}
+	r.started, r.ctx = true, ctx
+
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			report := r.run(ctx)
+			if ctx.Err() != nil {
+				r.stop()
+				return
+			}
+			r.mu.Lock()
+			r.cached = &report
+			r.mu.Unlock()
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				r.stop()
+				return
+			}
+		}
+	}()
+}
+
+/********** helper functions **********/
+
+// run
+// checks every dependency concurrently.
+func (r *Registry) run(ctx context.Context) Report {
+	r.mu.Lock()
+	checks := append([]registered(nil), r.checks...)
+	r.mu.Unlock()
+
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check registered) {
+			defer wg.Done()
+			results[i] = r.check(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return summarize(results)
+}
+
+// check
+// runs a single check bound by the timeout. A check that panics is down.
+func (r *Registry) check(ctx context.Context, check registered) (result Result) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result = Result{Name: check.name, Status: StatusUp, Critical: check.critical}
+	start := time.Now()
+	defer func() {
+		if v := recover(); v != nil {
+			result.Err = fmt.Errorf("%s: check panicked, %v", packageKey, v)
+		}
+		result.Latency, result.CheckedAt = time.Since(start), time.Now()
+		if result.Err != nil {
+			result.Status = StatusDown
+		}
+	}()
+
+	result.Err = check.checker.Check(ctx)
+	if result.Err == nil && ctx.Err() != nil {
+		result.Err = ctx.Err()
+	}
+	return result
+}
+
+// refresh
+// checks a single dependency and replaces its result in the cached
+// results, if there are any.
+func (r *Registry) refresh(ctx context.Context, check registered) {
+	result := r.check(ctx, check)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cached == nil || ctx.Err() != nil {
+		return
+	}
+
+	results := make([]Result, 0, len(r.cached.Results)+1)
+	for _, res := range r.cached.Results {
+		if res.Name != check.name {
+			results = append(results, res)
+		}
+	}
+	results = append(results, result)
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	report := summarize(results)
+	r.cached = &report
+}
+
+// stop
+// drops the background results once the background checks have stopped.
+func (r *Registry) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started, r.cached, r.ctx = false, nil, nil
+}
+
+// summarize
+// sums up the results into a report.
+func summarize(results []Result) Report {
+	report := Report{Status: StatusUp, Results: results}
+	for _, result := range results {
+		switch {
+		case result.Status == StatusUp:
+		case result.Critical:
+			report.Status = StatusDown
+		case report.Status == StatusUp:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
@@ -0,0 +1,210 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/jobaldw/shared/v2/client"
+	"github.com/jobaldw/shared/v2/config"
+	"github.com/jobaldw/shared/v2/testing/httpmock"
+)
+
+func TestRegistry_Check(t *testing.T) {
+	svr := httpmock.NewServer()
+	defer svr.Close()
+	svr.On(http.MethodGet, "/health").Respond(http.StatusOK)
+
+	users, err := client.New(config.Client{URL: svr.URL, Health: "/health"})
+	if err != nil {
+		t.Fatalf("client.New() error = %s", err)
+	}
+
+	up := CheckerFunc(func(ctx context.Context) error { return nil })
+	down := CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") })
+	hang := CheckerFunc(func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() })
+	panics := CheckerFunc(func(ctx context.Context) error { panic("nil map") })
+
+	type check struct {
+		name    string
+		checker Checker
+		opts    []Option
+	}
+	type result struct {
+		Name     string
+		Status   Status
+		Critical bool
+		HasErr   bool
+	}
+	type resp struct {
+		Status  Status
+		Results []result
+	}
+	tests := []struct {
+		name   string
+		conf   config.Readiness
+		checks []check
+		resp   resp
+	}{
+		{
+			name:   "up",
+			checks: []check{{"users", Client(users), nil}, {"cache", up, nil}},
+			resp: resp{Status: StatusUp, Results: []result{
+				{Name: "cache", Status: StatusUp, Critical: true},
+				{Name: "users", Status: StatusUp, Critical: true},
+			}},
+		},
+		{
+			name:   "critical down",
+			checks: []check{{"queue", down, nil}, {"cache", up, nil}},
+			resp: resp{Status: StatusDown, Results: []result{
+				{Name: "cache", Status: StatusUp, Critical: true},
+				{Name: "queue", Status: StatusDown, Critical: true, HasErr: true},
+			}},
+		},
+		{
+			name:   "non-critical down",
+			checks: []check{{"queue", down, []Option{NonCritical()}}, {"cache", up, nil}},
+			resp: resp{Status: StatusDegraded, Results: []result{
+				{Name: "cache", Status: StatusUp, Critical: true},
+				{Name: "queue", Status: StatusDown, HasErr: true},
+			}},
+		},
+		{
+			name:   "optional config",
+			conf:   config.Readiness{Optional: []string{"queue"}},
+			checks: []check{{"queue", down, nil}},
+			resp: resp{Status: StatusDegraded, Results: []result{
+				{Name: "queue", Status: StatusDown, HasErr: true},
+			}},
+		},
+		{
+			name:   "timeout and panic",
+			conf:   config.Readiness{Timeout: 1},
+			checks: []check{{"queue", hang, nil}, {"cache", panics, nil}},
+			resp: resp{Status: StatusDown, Results: []result{
+				{Name: "cache", Status: StatusDown, Critical: true, HasErr: true},
+				{Name: "queue", Status: StatusDown, Critical: true, HasErr: true},
+			}},
+		},
+		{
+			name:   "replaced",
+			checks: []check{{"cache", down, nil}, {"cache", up, nil}},
+			resp: resp{Status: StatusUp, Results: []result{
+				{Name: "cache", Status: StatusUp, Critical: true},
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry := NewRegistry(test.conf)
+			for _, check := range test.checks {
+				registry.Register(check.name, check.checker, check.opts...)
+			}
+
+			report := registry.Check(context.Background())
+
+			got := resp{Status: report.Status}
+			for _, r := range report.Results {
+				got.Results = append(got.Results, result{Name: r.Name, Status: r.Status, Critical: r.Critical, HasErr: r.Err != nil})
+				if r.CheckedAt.IsZero() {
+					t.Errorf("Result %s has no check time", r.Name)
+				}
+			}
+			if diff := cmp.Diff(test.resp, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Registry.Check() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegistry_Start(t *testing.T) {
+	var calls int32
+	slow := CheckerFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+
+	registry := NewRegistry(config.Readiness{Interval: 60})
+	registry.Register("queue", slow)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	registry.Start(ctx)
+	registry.Start(ctx) // starting twice does nothing
+	time.Sleep(400 * time.Millisecond)
+
+	// the cached background results are returned without checking again
+	start := time.Now()
+	report := registry.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Registry.Check() took %s, want the cached results", elapsed)
+	}
+	if report.Status != StatusUp || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Registry.Check() = %s after %d checks, want UP after 1", report.Status, calls)
+	}
+
+	// once stopped every call checks again
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	registry.Check(context.Background())
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("checks = %d after the registry stopped, want 2", n)
+	}
+}
+
+func TestRegistry_RegisterAfterStart(t *testing.T) {
+	var slowCalls, newCalls int32
+	slow := CheckerFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&slowCalls, 1)
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+	down := CheckerFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&newCalls, 1)
+		return errors.New("connection refused")
+	})
+
+	registry := NewRegistry(config.Readiness{Interval: 60})
+	registry.Register("queue", slow)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registry.Start(ctx)
+	time.Sleep(400 * time.Millisecond)
+
+	// only the new check is run, and the cached results are kept meanwhile
+	registry.Register("cache", down)
+	start := time.Now()
+	registry.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Registry.Check() took %s after a check was registered, want the cached results", elapsed)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	type result struct {
+		Name   string
+		Status Status
+	}
+	var got []result
+	report := registry.Check(context.Background())
+	for _, r := range report.Results {
+		got = append(got, result{Name: r.Name, Status: r.Status})
+	}
+	want := []result{{Name: "cache", Status: StatusDown}, {Name: "queue", Status: StatusUp}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Registry.Check() mismatch (-want +got):\n%s", diff)
+	}
+	if report.Status != StatusDown {
+		t.Errorf("Registry.Check() status = %s, want %s", report.Status, StatusDown)
+	}
+	if s, n := atomic.LoadInt32(&slowCalls), atomic.LoadInt32(&newCalls); s != 1 || n != 1 {
+		t.Errorf("checks = %d and %d, want 1 and 1", s, n)
+	}
+}
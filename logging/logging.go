@@ -0,0 +1,150 @@
+/*
+Package logging builds structured zerolog loggers from the application's
+configs and carries them through a context.Context.
+
+The shared packages log through the logger found in the context passed to
+them, so a request's logs carry the same fields, such as its request id
+and route, no matter which package writes them. Nothing is logged when the
+context has no logger.
+*/
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// package logging key
+const packageKey = "logging"
+
+// log formats
+const (
+	FormatJSON    = "json"    // one JSON object per log
+	FormatConsole = "console" // human readable, colored logs
+)
+
+// The keys of the fields the shared packages log with.
+const (
+	AppKey       = "app"
+	VersionKey   = "version"
+	PackageKey   = "package"
+	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+	RouteKey     = "route"
+	MethodKey    = "method"
+	PathKey      = "path"
+	URLKey       = "url"
+	StatusKey    = "status"
+	LatencyKey   = "latency"
+	BytesKey     = "bytes"
+	StackKey     = "stack"
+	AddrKey      = "addr"
+)
+
+// Option
+// customizes a logger when it is created with New().
+type Option func(*options)
+
+type options struct {
+	writer io.Writer
+}
+
+// WithWriter
+// writes the logs to w instead of standard out.
+func WithWriter(w io.Writer) Option {
+	return func(o *options) {
+		o.writer = w
+	}
+}
+
+// New
+// creates a logger from the application's configs. Every log carries a
+// timestamp and the application's name and version.
+func New(conf config.Application, opts ...Option) (zerolog.Logger, error) {
+	o := options{writer: os.Stdout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	level := zerolog.InfoLevel
+	if conf.LogLevel != "" {
+		l, err := zerolog.ParseLevel(strings.ToLower(conf.LogLevel))
+		if err != nil {
+			return zerolog.Nop(), fmt.Errorf("%s: %w, invalid log level", packageKey, err)
+		}
+		level = l
+	}
+
+	w := o.writer
+	switch conf.LogFormat {
+	case "", FormatJSON:
+	case FormatConsole:
+		// only color logs written to the terminal
+		color := w == os.Stdout || w == os.Stderr
+		w = zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339, NoColor: !color}
+	default:
+		return zerolog.Nop(), fmt.Errorf("%s: unknown log format %q", packageKey, conf.LogFormat)
+	}
+
+	ctx := zerolog.New(w).Level(level).With().Timestamp()
+	if conf.Name != "" {
+		ctx = ctx.Str(AppKey, conf.Name)
+	}
+	if conf.Version != "" {
+		ctx = ctx.Str(VersionKey, conf.Version)
+	}
+	logger := ctx.Logger()
+
+	if conf.LogSample > 1 {
+		n := uint32(conf.LogSample)
+		logger = logger.Sample(zerolog.LevelSampler{
+			DebugSampler: &zerolog.BasicSampler{N: n},
+			InfoSampler:  &zerolog.BasicSampler{N: n},
+		})
+	}
+	return logger, nil
+}
+
+// NewContext
+// returns a copy of the context carrying the logger.
+func NewContext(ctx context.Context, logger zerolog.Logger) context.Context {
+	return logger.WithContext(ctx)
+}
+
+// FromContext
+// returns the logger carried by the context, or a disabled logger when
+// there is none.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	return zerolog.Ctx(ctx)
+}
+
+// With
+// returns a copy of the context whose logger adds the field to every log.
+// The context is returned as is when it carries no logger.
+func With(ctx context.Context, key string, value interface{}) context.Context {
+	logger := zerolog.Ctx(ctx)
+	if logger.GetLevel() == zerolog.Disabled {
+		return ctx
+	}
+	return logger.With().Interface(key, value).Logger().WithContext(ctx)
+}
+
+// For
+// returns the context's logger with the package field set, for shared
+// packages to log with.
+func For(ctx context.Context, pkg string) *zerolog.Logger {
+	logger := zerolog.Ctx(ctx)
+	if logger.GetLevel() == zerolog.Disabled {
+		return logger
+	}
+	l := logger.With().Str(PackageKey, pkg).Logger()
+	return &l
+}
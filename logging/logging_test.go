@@ -0,0 +1,155 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+func TestNew(t *testing.T) {
+	type resp struct {
+		Lines  []map[string]interface{}
+		Output string
+		HasErr bool
+	}
+	tests := []struct {
+		name string
+		conf config.Application
+		resp resp
+	}{
+		{
+			name: "json",
+			conf: config.Application{Name: "users", Version: "1.2.0"},
+			resp: resp{Lines: []map[string]interface{}{
+				{"level": "info", "app": "users", "version": "1.2.0", "message": "info"},
+				{"level": "warn", "app": "users", "version": "1.2.0", "message": "warn"},
+			}},
+		},
+		{
+			name: "level",
+			conf: config.Application{LogLevel: "DEBUG"},
+			resp: resp{Lines: []map[string]interface{}{
+				{"level": "debug", "message": "debug"},
+				{"level": "info", "message": "info"},
+				{"level": "warn", "message": "warn"},
+			}},
+		},
+		{
+			name: "sampled",
+			conf: config.Application{LogLevel: "debug", LogSample: 5},
+			resp: resp{Lines: []map[string]interface{}{
+				{"level": "debug", "message": "debug"},
+				{"level": "info", "message": "info"},
+				{"level": "warn", "message": "warn"},
+			}},
+		},
+		{
+			name: "console",
+			conf: config.Application{Name: "users", LogFormat: FormatConsole, LogLevel: "warn"},
+			resp: resp{Output: "WRN warn app=users"},
+		},
+		{
+			name: "invalid level",
+			conf: config.Application{LogLevel: "loud"},
+			resp: resp{HasErr: true},
+		},
+		{
+			name: "invalid format",
+			conf: config.Application{LogFormat: "xml"},
+			resp: resp{HasErr: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(test.conf, WithWriter(&buf))
+
+			var got resp
+			got.HasErr = err != nil
+			if err == nil {
+				logger.Debug().Msg("debug")
+				logger.Info().Msg("info")
+				logger.Warn().Msg("warn")
+
+				// sampled logs after the first are dropped
+				for i := 0; i < 3; i++ {
+					logger.Debug().Msg("sampled")
+				}
+			}
+
+			if test.conf.LogFormat == FormatConsole {
+				got.Output = strings.TrimSpace(buf.String())
+				if i := strings.Index(got.Output, " "); i >= 0 {
+					got.Output = got.Output[i+1:] // drop the timestamp
+				}
+			} else {
+				for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+					if line == "" {
+						continue
+					}
+					var m map[string]interface{}
+					if err := json.Unmarshal([]byte(line), &m); err != nil {
+						t.Fatalf("json.Unmarshal() error = %s, log line %q", err, line)
+					}
+					if m["message"] == "sampled" && test.conf.LogSample == 0 {
+						continue
+					}
+					if _, ok := m["time"]; !ok {
+						t.Errorf("log line %q has no timestamp", line)
+					}
+					delete(m, "time")
+					got.Lines = append(got.Lines, m)
+				}
+			}
+
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("New() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(config.Application{}, WithWriter(&buf))
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+
+	// nothing is logged without a logger in the context
+	ctx := With(context.Background(), RequestIDKey, "abc-123")
+	For(ctx, "client").Info().Msg("dropped")
+	if buf.Len() != 0 {
+		t.Errorf("logged %q without a logger in the context", buf.String())
+	}
+
+	ctx = NewContext(context.Background(), logger)
+	ctx = With(ctx, RequestIDKey, "abc-123")
+	ctx = With(ctx, RouteKey, "/users/{id}")
+	For(ctx, "client").Info().Msg("request made")
+	FromContext(ctx).Info().Msg("handled")
+
+	var got []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("json.Unmarshal() error = %s, log line %q", err, line)
+		}
+		delete(m, "time")
+		got = append(got, m)
+	}
+
+	want := []map[string]interface{}{
+		{"level": "info", "request_id": "abc-123", "route": "/users/{id}", "package": "client", "message": "request made"},
+		{"level": "info", "request_id": "abc-123", "route": "/users/{id}", "message": "handled"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("context logger mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// A CounterVec is a counter split up by label values.
+type CounterVec struct{ vec *prometheus.CounterVec }
+
+// With
+// returns the counter for the label values, in the order the labels were
+// declared.
+func (v *CounterVec) With(values ...string) *Counter {
+	return &Counter{v.vec.WithLabelValues(values...)}
+}
+
+// A Counter is a value that only goes up, e.g. the number of requests
+// served.
+type Counter struct{ c prometheus.Counter }
+
+// Inc
+// adds one to the counter.
+func (c *Counter) Inc() {
+	c.c.Inc()
+}
+
+// Add
+// adds a value to the counter. Negative values are ignored as counters
+// never go down.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.c.Add(v)
+}
+
+// A GaugeVec is a gauge split up by label values.
+type GaugeVec struct{ vec *prometheus.GaugeVec }
+
+// With
+// returns the gauge for the label values, in the order the labels were
+// declared.
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return &Gauge{v.vec.WithLabelValues(values...)}
+}
+
+// A Gauge is a value that can go up and down, e.g. the number of requests
+// in flight.
+type Gauge struct{ g prometheus.Gauge }
+
+// Set
+// sets the gauge to a value.
+func (g *Gauge) Set(v float64) { g.g.Set(v) }
+
+// Add
+// adds a value, which may be negative, to the gauge.
+func (g *Gauge) Add(v float64) { g.g.Add(v) }
+
+// Inc
+// adds one to the gauge.
+func (g *Gauge) Inc() { g.g.Inc() }
+
+// Dec
+// subtracts one from the gauge.
+func (g *Gauge) Dec() { g.g.Dec() }
+
+// A HistogramVec is a histogram split up by label values.
+type HistogramVec struct{ vec *prometheus.HistogramVec }
+
+// With
+// returns the histogram for the label values, in the order the labels
+// were declared.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return &Histogram{v.vec.WithLabelValues(values...)}
+}
+
+// A Histogram counts observations, e.g. request latencies in seconds, in
+// buckets.
+type Histogram struct{ o prometheus.Observer }
+
+// Observe
+// adds an observation to the histogram.
+func (h *Histogram) Observe(v float64) {
+	h.o.Observe(v)
+}
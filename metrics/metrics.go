@@ -0,0 +1,206 @@
+/*
+Package metrics implements counters, gauges and histograms that are
+exposed in the Prometheus text format.
+
+Metrics are created on a Registry, usually the Default one, and served
+by its Handler() on a "/metrics" endpoint. The router, client and mongo
+packages record their metrics on the Default registry. A Registry is
+backed by a prometheus.Registry, so any client_golang collector can be
+registered on it and served alongside them.
+*/
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+// package logging key
+const packageKey = "metrics"
+
+// metric types
+const (
+	counterType   = "counter"
+	gaugeType     = "gauge"
+	histogramType = "histogram"
+)
+
+// DefBuckets are the default histogram buckets, in seconds, suited to
+// request latencies.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry the shared packages record their metrics on. It
+// also exposes the Go runtime and process metrics.
+var Default = newDefault()
+
+// A Registry holds metrics and writes them in the Prometheus text format.
+// It is safe for concurrent use.
+type Registry struct {
+	prom *prometheus.Registry
+
+	mu      sync.Mutex
+	metrics map[string]*metric
+}
+
+// A metric created on the registry and how it was defined.
+type metric struct {
+	kind      string
+	labels    []string
+	buckets   []float64
+	collector prometheus.Collector
+}
+
+// NewRegistry
+// creates an empty registry, e.g. for tests.
+func NewRegistry() *Registry {
+	return &Registry{prom: prometheus.NewRegistry(), metrics: make(map[string]*metric)}
+}
+
+// Counter
+// returns the counter with the name, creating it when it does not exist.
+// An error is returned when a different metric already has the name or
+// the name or labels are invalid.
+func (r *Registry) Counter(name, help string, labels ...string) (*CounterVec, error) {
+	m, err := r.metric(name, counterType, nil, labels, func() prometheus.Collector {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CounterVec{m.collector.(*prometheus.CounterVec)}, nil
+}
+
+// Gauge
+// returns the gauge with the name, creating it when it does not exist.
+// An error is returned when a different metric already has the name or
+// the name or labels are invalid.
+func (r *Registry) Gauge(name, help string, labels ...string) (*GaugeVec, error) {
+	m, err := r.metric(name, gaugeType, nil, labels, func() prometheus.Collector {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &GaugeVec{m.collector.(*prometheus.GaugeVec)}, nil
+}
+
+// Histogram
+// returns the histogram with the name, creating it when it does not
+// exist. DefBuckets are used when no buckets are given. An error is
+// returned when a different metric already has the name or the name or
+// labels are invalid.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) (*HistogramVec, error) {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	m, err := r.metric(name, histogramType, buckets, labels, func() prometheus.Collector {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &HistogramVec{m.collector.(*prometheus.HistogramVec)}, nil
+}
+
+// Register
+// adds a client_golang collector to the registry, so its metrics are
+// served with the registry's own.
+func (r *Registry) Register(c prometheus.Collector) error {
+	if err := r.prom.Register(c); err != nil {
+		return fmt.Errorf("%s: %w, could not register collector", packageKey, err)
+	}
+	return nil
+}
+
+// Handler
+// returns an http handler serving the registry's metrics, e.g. on a
+// "/metrics" endpoint.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.prom, promhttp.HandlerOpts{})
+}
+
+// Write
+// writes every metric in the Prometheus text format, sorted by name.
+func (r *Registry) Write(w io.Writer) error {
+	families, err := r.prom.Gather()
+	if err != nil {
+		return fmt.Errorf("%s: %w, could not gather metrics", packageKey, err)
+	}
+
+	enc := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, f := range families {
+		if err := enc.Encode(f); err != nil {
+			return fmt.Errorf("%s: %w, could not write metrics", packageKey, err)
+		}
+	}
+	return nil
+}
+
+/********** helper functions **********/
+
+// newDefault
+// creates a registry exposing the Go runtime and process metrics.
+func newDefault() *Registry {
+	r := NewRegistry()
+	r.prom.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return r
+}
+
+// metric
+// returns the metric with the name, creating and registering it when it
+// does not exist.
+func (r *Registry) metric(name, kind string, buckets []float64, labels []string, create func() prometheus.Collector) (*metric, error) {
+	if kind == histogramType {
+		for _, label := range labels {
+			if label == "le" {
+				return nil, fmt.Errorf("%s: invalid label name %q for metric %s", packageKey, label, name)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m, ok := r.metrics[name]; ok {
+		if m.kind != kind || strings.Join(m.labels, ",") != strings.Join(labels, ",") {
+			return nil, fmt.Errorf("%s: metric %s already exists as a %s with labels %v", packageKey, name, m.kind, m.labels)
+		}
+		if !sameBuckets(m.buckets, buckets) {
+			return nil, fmt.Errorf("%s: metric %s already exists as a %s with buckets %v", packageKey, name, m.kind, m.buckets)
+		}
+		return m, nil
+	}
+
+	m := &metric{kind: kind, labels: append([]string(nil), labels...), buckets: buckets, collector: create()}
+	if err := r.prom.Register(m.collector); err != nil {
+		return nil, fmt.Errorf("%s: %w, could not register metric %s", packageKey, err, name)
+	}
+	r.metrics[name] = m
+	return m, nil
+}
+
+// sameBuckets
+// reports whether two sorted histogram bucket lists are equal.
+func sameBuckets(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
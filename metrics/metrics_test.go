@@ -0,0 +1,244 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func(r *Registry)
+		want   string
+	}{
+		{
+			name: "counter",
+			record: func(r *Registry) {
+				c := must(r.Counter("jobs_total", "Number of jobs run.", "queue", "status"))
+				c.With("emails", "ok").Inc()
+				c.With("emails", "ok").Add(2)
+				c.With("emails", "ok").Add(-5) // ignored
+				c.With("alerts", "failed").Inc()
+			},
+			want: `# HELP jobs_total Number of jobs run.
+# TYPE jobs_total counter
+jobs_total{queue="alerts",status="failed"} 1
+jobs_total{queue="emails",status="ok"} 3
+`,
+		},
+		{
+			name: "gauge",
+			record: func(r *Registry) {
+				g := must(r.Gauge("workers", "Number of workers.")).With()
+				g.Set(4)
+				g.Inc()
+				g.Dec()
+				g.Add(-0.5)
+			},
+			want: `# HELP workers Number of workers.
+# TYPE workers gauge
+workers 3.5
+`,
+		},
+		{
+			name: "histogram",
+			record: func(r *Registry) {
+				h := must(r.Histogram("latency_seconds", "Latency.", []float64{1, 0.1}, "route")).With("/users")
+				for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+					h.Observe(v)
+				}
+			},
+			want: `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/users",le="0.1"} 2
+latency_seconds_bucket{route="/users",le="1"} 3
+latency_seconds_bucket{route="/users",le="+Inf"} 4
+latency_seconds_sum{route="/users"} 2.65
+latency_seconds_count{route="/users"} 4
+`,
+		},
+		{
+			name: "escaped and sorted",
+			record: func(r *Registry) {
+				must(r.Gauge("b", "B.")).With().Set(1)
+				must(r.Counter("a", "line\none \\ two", "path")).With(`say "hi"`).Inc()
+				must(r.Counter("unused", "never recorded", "path"))
+			},
+			want: `# HELP a line\none \\ two
+# TYPE a counter
+a{path="say \"hi\""} 1
+# HELP b B.
+# TYPE b gauge
+b 1
+`,
+		},
+		{
+			name: "existing metric",
+			record: func(r *Registry) {
+				must(r.Counter("jobs_total", "Number of jobs run.", "queue")).With("emails").Inc()
+				must(r.Counter("jobs_total", "Number of jobs run.", "queue")).With("emails").Inc()
+			},
+			want: `# HELP jobs_total Number of jobs run.
+# TYPE jobs_total counter
+jobs_total{queue="emails"} 2
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reg := NewRegistry()
+			test.record(reg)
+
+			var got strings.Builder
+			if err := reg.Write(&got); err != nil {
+				t.Fatalf("Write() error = %s", err)
+			}
+			if diff := cmp.Diff(test.want, got.String()); diff != "" {
+				t.Errorf("Write() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegistry_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(r *Registry) error
+	}{
+		{name: "invalid name", fn: func(r *Registry) error { _, err := r.Counter("jobs-total", "Jobs."); return err }},
+		{name: "invalid label", fn: func(r *Registry) error { _, err := r.Counter("jobs_total", "Jobs.", "__name"); return err }},
+		{name: "le label", fn: func(r *Registry) error { _, err := r.Histogram("latency", "Latency.", nil, "le"); return err }},
+		{name: "different type", fn: func(r *Registry) error {
+			r.Counter("jobs", "Jobs.") // nolint:errcheck
+			_, err := r.Gauge("jobs", "Jobs.")
+			return err
+		}},
+		{name: "different labels", fn: func(r *Registry) error {
+			r.Counter("jobs", "Jobs.", "a") // nolint:errcheck
+			_, err := r.Counter("jobs", "Jobs.", "b")
+			return err
+		}},
+		{name: "different buckets", fn: func(r *Registry) error {
+			r.Histogram("latency", "Latency.", []float64{1}) // nolint:errcheck
+			_, err := r.Histogram("latency", "Latency.", []float64{2})
+			return err
+		}},
+		{name: "registered collector", fn: func(r *Registry) error {
+			r.Register(prometheus.NewCounter(prometheus.CounterOpts{Name: "jobs", Help: "Jobs."})) // nolint:errcheck
+			_, err := r.Counter("jobs", "Jobs.")
+			return err
+		}},
+		{name: "collector registered twice", fn: func(r *Registry) error {
+			r.Counter("jobs", "Jobs.") // nolint:errcheck
+			return r.Register(prometheus.NewCounter(prometheus.CounterOpts{Name: "jobs", Help: "Jobs."}))
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.fn(NewRegistry()); err == nil {
+				t.Errorf("%s error = nil, want an error", test.name)
+			}
+		})
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	reg := NewRegistry()
+
+	queue := prometheus.NewGauge(prometheus.GaugeOpts{Name: "queue_length", Help: "Number of queued jobs."})
+	if err := reg.Register(queue); err != nil {
+		t.Fatalf("Register() error = %s", err)
+	}
+	queue.Set(7)
+	must(reg.Counter("jobs_total", "Number of jobs run.")).With().Inc()
+
+	var got strings.Builder
+	if err := reg.Write(&got); err != nil {
+		t.Fatalf("Write() error = %s", err)
+	}
+	want := `# HELP jobs_total Number of jobs run.
+# TYPE jobs_total counter
+jobs_total 1
+# HELP queue_length Number of queued jobs.
+# TYPE queue_length gauge
+queue_length 7
+`
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Errorf("Write() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	var got strings.Builder
+	if err := Default.Write(&got); err != nil {
+		t.Fatalf("Write() error = %s", err)
+	}
+	for _, want := range []string{"# TYPE go_goroutines gauge", "# TYPE process_cpu_seconds_total counter"} {
+		if !strings.Contains(got.String(), want) {
+			t.Errorf("Default.Write() = %q, want it to contain %q", got.String(), want)
+		}
+	}
+}
+
+func TestRegistry_Concurrent(t *testing.T) {
+	reg := NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				hits, _ := reg.Counter("hits_total", "Hits.", "page")
+				hits.With("home").Inc()
+				latency, _ := reg.Histogram("latency_seconds", "Latency.", nil)
+				latency.With().Observe(0.01)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var got strings.Builder
+	reg.Write(&got) // nolint:errcheck
+	for _, want := range []string{`hits_total{page="home"} 1000`, "latency_seconds_count 1000"} {
+		if !strings.Contains(got.String(), want) {
+			t.Errorf("Write() = %q, want it to contain %q", got.String(), want)
+		}
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	reg := NewRegistry()
+	must(reg.Counter("hits_total", "Hits.")).With().Inc()
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	type resp struct {
+		Code        int
+		ContentType string
+		Body        string
+	}
+	want := resp{Code: 200, ContentType: "text/plain; version=0.0.4; charset=utf-8", Body: "# HELP hits_total Hits.\n# TYPE hits_total counter\nhits_total 1\n"}
+	got := resp{Code: rec.Code, ContentType: rec.Header().Get("Content-Type"), Body: rec.Body.String()}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Handler() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+/********** helper functions **********/
+
+// must
+// returns a metric, panicking when it could not be created.
+func must[V any](v V, err error) V {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -17,14 +17,20 @@ import (
 	"time"
 
 	"github.com/jobaldw/shared/v2/config"
+	"github.com/jobaldw/shared/v2/logging"
+	"github.com/jobaldw/shared/v2/metrics"
+	"github.com/jobaldw/shared/v2/tracing"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-// package logging key
-const packageKey = "mongo"
+// logging keys
+const (
+	packageKey  = "mongo"
+	databaseKey = "database"
+)
 
 var (
 	ErrNoCollections = errors.New("no configured collections") // no collection to read from or write to
@@ -113,18 +119,48 @@ func (m *Mongo) PingWithContext(ctx context.Context) (err error) {
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
+	start := time.Now()
 	if err = m.Database.Client().Ping(ctx, readpref.Primary()); err != nil {
+		logging.For(ctx, packageKey).Warn().Err(err).
+			Str(databaseKey, m.Database.Name()).
+			Dur(logging.LatencyKey, time.Since(start)).
+			Msg("ping failed")
 		return fmt.Errorf("%s: %s, could not ping database, %s", packageKey, err, m.Database.Name())
 	}
 	return err
 }
 
+// Disconnect
+// closes the mongo database connection with any passed in
+// context.Context(), e.g. as a router.Server shutdown hook.
+func (m *Mongo) Disconnect(ctx context.Context) error {
+	if m.Database == nil {
+		return fmt.Errorf("%s: %s", packageKey, ErrNoDatabase)
+	}
+
+	log := logging.For(ctx, packageKey)
+	if err := m.Database.Client().Disconnect(ctx); err != nil {
+		log.Error().Err(err).Str(databaseKey, m.Database.Name()).Msg("disconnect failed")
+		return fmt.Errorf("%s: %w, could not disconnect from database, %s", packageKey, err, m.Database.Name())
+	}
+	log.Info().Str(databaseKey, m.Database.Name()).Msg("disconnected")
+	return nil
+}
+
 /********** helper functions **********/
 
 // connect
-// creates, configures and connects to a mongo client.
+// creates, configures and connects to a mongo client. Every command the
+// client sends is recorded on the default metrics registry and traced
+// with the global tracer provider.
 func (m *Mongo) connect() (err error) {
-	client, err := mongo.NewClient(options.Client().ApplyURI(m.URI.String()))
+	monitor, err := newMonitor(metrics.Default, tracing.Tracer(nil))
+	if err != nil {
+		return fmt.Errorf("%s: %s, could not create mongo object", packageKey, err)
+	}
+
+	opts := options.Client().ApplyURI(m.URI.String()).SetMonitor(monitor)
+	client, err := mongo.NewClient(opts)
 	if err != nil {
 		return fmt.Errorf("%s: %s, could not create mongo object", packageKey, err)
 	}
@@ -1,3 +1,113 @@
 package mongo
 
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/jobaldw/shared/v2/metrics"
+)
+
 // TODO: Add mongo unit test with docker instance
+
+func Test_newMonitor(t *testing.T) {
+	reg := metrics.NewRegistry()
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+	monitor, err := newMonitor(reg, tracer)
+	if err != nil {
+		t.Fatalf("newMonitor() error = %s", err)
+	}
+	ctx, parent := tracer.Start(context.Background(), "GET /users")
+
+	started := func(id int64, name string, cmd bson.D) {
+		raw, err := bson.Marshal(cmd)
+		if err != nil {
+			t.Fatalf("bson.Marshal() error = %s", err)
+		}
+		monitor.Started(ctx, &event.CommandStartedEvent{
+			Command: raw, DatabaseName: "app", CommandName: name, RequestID: id, ConnectionID: "localhost:27017[-1]",
+		})
+	}
+	finished := func(id int64, name string) event.CommandFinishedEvent {
+		return event.CommandFinishedEvent{
+			DurationNanos: int64(20 * time.Millisecond), CommandName: name, RequestID: id, ConnectionID: "localhost:27017[-1]",
+		}
+	}
+
+	started(1, "find", bson.D{{Key: "find", Value: "users"}})
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished(1, "find")})
+	started(2, "insert", bson.D{{Key: "insert", Value: "users"}})
+	monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: finished(2, "insert"), Failure: "duplicate key"})
+	started(3, "ping", bson.D{{Key: "ping", Value: 1}})
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished(3, "ping")})
+
+	// a command finishing without having started is not recorded
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished(4, "find")})
+
+	var out strings.Builder
+	reg.Write(&out) // nolint:errcheck
+
+	var got []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, "mongo_operations_total") || strings.HasPrefix(line, "mongo_operation_duration_seconds_sum") {
+			got = append(got, line)
+		}
+	}
+
+	want := []string{
+		`mongo_operation_duration_seconds_sum{collection="",database="app",operation="ping"} 0.02`,
+		`mongo_operation_duration_seconds_sum{collection="users",database="app",operation="find"} 0.02`,
+		`mongo_operation_duration_seconds_sum{collection="users",database="app",operation="insert"} 0.02`,
+		`mongo_operations_total{collection="",database="app",operation="ping",status="success"} 1`,
+		`mongo_operations_total{collection="users",database="app",operation="find",status="success"} 1`,
+		`mongo_operations_total{collection="users",database="app",operation="insert",status="error"} 1`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("newMonitor() mismatch (-want +got):\n%s", diff)
+	}
+
+	type span struct {
+		Name       string
+		Parent     bool
+		Attributes map[string]interface{}
+		Status     codes.Code
+		Message    string
+	}
+	var spans []span
+	for _, s := range exporter.GetSpans() {
+		attrs := make(map[string]interface{}, len(s.Attributes))
+		for _, kv := range s.Attributes {
+			attrs[string(kv.Key)] = kv.Value.AsInterface()
+		}
+		spans = append(spans, span{
+			Name:       s.Name,
+			Parent:     s.Parent.SpanID() == parent.SpanContext().SpanID(),
+			Attributes: attrs,
+			Status:     s.Status.Code,
+			Message:    s.Status.Description,
+		})
+	}
+	wantSpans := []span{
+		{Name: "mongo.find", Parent: true, Attributes: map[string]interface{}{
+			"db.system": "mongodb", "db.name": "app", "db.operation": "find", "db.mongodb.collection": "users",
+		}},
+		{Name: "mongo.insert", Parent: true, Attributes: map[string]interface{}{
+			"db.system": "mongodb", "db.name": "app", "db.operation": "insert", "db.mongodb.collection": "users",
+		}, Status: codes.Error, Message: "duplicate key"},
+		{Name: "mongo.ping", Parent: true, Attributes: map[string]interface{}{
+			"db.system": "mongodb", "db.name": "app", "db.operation": "ping",
+		}},
+	}
+	if diff := cmp.Diff(wantSpans, spans); diff != "" {
+		t.Errorf("newMonitor() spans mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,126 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jobaldw/shared/v2/metrics"
+)
+
+// mongo metric names
+const (
+	operationsMetric = "mongo_operations_total"
+	durationMetric   = "mongo_operation_duration_seconds"
+)
+
+// operation statuses
+const (
+	statusSuccess = "success"
+	statusError   = "error"
+)
+
+// Records the count and latency of every command sent to mongo, by
+// database, collection and operation, and wraps it in a span.
+type monitor struct {
+	operations *metrics.CounterVec
+	duration   *metrics.HistogramVec
+	tracer     trace.Tracer
+
+	// the started commands' labels, by connection and request id
+	started sync.Map
+}
+
+// A started command's labels and span.
+type command struct {
+	database   string
+	collection string
+	operation  string
+	span       trace.Span
+}
+
+// the key a started command is tracked by until it finishes
+type commandKey struct {
+	connection string
+	request    int64
+}
+
+/********** helper functions **********/
+
+// newMonitor
+// registers the mongo metrics on the registry and returns a command
+// monitor for the mongo driver that records them and starts spans with
+// the tracer.
+func newMonitor(reg *metrics.Registry, tracer trace.Tracer) (*event.CommandMonitor, error) {
+	m := &monitor{tracer: tracer}
+	var err error
+	if m.operations, err = reg.Counter(operationsMetric, "Number of mongo operations, by collection.", "database", "collection", "operation", "status"); err != nil {
+		return nil, err
+	}
+	if m.duration, err = reg.Histogram(durationMetric, "Latency of mongo operations, in seconds.", metrics.DefBuckets, "database", "collection", "operation"); err != nil {
+		return nil, err
+	}
+	return &event.CommandMonitor{
+		Started: m.start,
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			m.finish(e.CommandFinishedEvent, statusSuccess, "")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			m.finish(e.CommandFinishedEvent, statusError, e.Failure)
+		},
+	}, nil
+}
+
+// start
+// starts a client span for a command, as a child of the operation's
+// context, and remembers its labels until it finishes. The collection is
+// the value of the command's first element, e.g. {"find": "users"};
+// commands without one, such as "ping", have no collection.
+func (m *monitor) start(ctx context.Context, e *event.CommandStartedEvent) {
+	cmd := command{database: e.DatabaseName, operation: e.CommandName}
+	if elem, err := e.Command.IndexErr(0); err == nil {
+		if name, ok := elem.Value().StringValueOK(); ok {
+			cmd.collection = name
+		}
+	}
+
+	if m.tracer != nil {
+		attrs := []attribute.KeyValue{
+			semconv.DBSystemMongoDB,
+			semconv.DBNameKey.String(cmd.database),
+			semconv.DBOperationKey.String(cmd.operation),
+		}
+		if cmd.collection != "" {
+			attrs = append(attrs, semconv.DBMongoDBCollectionKey.String(cmd.collection))
+		}
+		_, cmd.span = m.tracer.Start(ctx, "mongo."+cmd.operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	}
+	m.started.Store(commandKey{e.ConnectionID, e.RequestID}, cmd)
+}
+
+// finish
+// records a finished command with the given status and ends its span,
+// failed with the failure message when there is one.
+func (m *monitor) finish(e event.CommandFinishedEvent, status, failure string) {
+	v, ok := m.started.LoadAndDelete(commandKey{e.ConnectionID, e.RequestID})
+	if !ok {
+		return
+	}
+	cmd := v.(command)
+
+	if cmd.span != nil {
+		if failure != "" {
+			cmd.span.SetStatus(codes.Error, failure)
+		}
+		cmd.span.End()
+	}
+
+	m.operations.With(cmd.database, cmd.collection, cmd.operation, status).Inc()
+	m.duration.With(cmd.database, cmd.collection, cmd.operation).Observe(time.Duration(e.DurationNanos).Seconds())
+}
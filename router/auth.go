@@ -0,0 +1,316 @@
+package router
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/jobaldw/shared/v2/logging"
+)
+
+// the header API keys are read from by default
+const APIKeyHeader = "X-API-Key"
+
+var (
+	ErrMissingCredentials = errors.New("missing credentials") // the request has no token or API key
+	ErrInvalidAPIKey      = errors.New("invalid API key")     // the request's API key is not known
+)
+
+// The claims of a verified JWT, or of an API key, stored in the request's
+// context by the auth middleware.
+type Claims map[string]interface{}
+
+// Subject
+// returns the "sub" claim.
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// Issuer
+// returns the "iss" claim.
+func (c Claims) Issuer() string {
+	s, _ := c["iss"].(string)
+	return s
+}
+
+// Audience
+// returns the "aud" claim, which is either a string or a list of them.
+func (c Claims) Audience() []string {
+	return c.stringList("aud")
+}
+
+// Roles
+// returns the "roles" claim.
+func (c Claims) Roles() []string {
+	return c.stringList("roles")
+}
+
+// Scopes
+// returns the space separated "scope" claim, or the "scp" claim listing
+// them.
+func (c Claims) Scopes() []string {
+	if scope, ok := c["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	return c.stringList("scp")
+}
+
+// ClaimsFromContext
+// returns the claims the auth middleware stored in the context, or nil
+// when the request was not authenticated.
+func ClaimsFromContext(ctx context.Context) Claims {
+	claims, _ := ctx.Value(claimsKey).(Claims)
+	return claims
+}
+
+// ContextWithClaims
+// returns a copy of the context carrying the claims, e.g. to test
+// handlers behind the auth middleware.
+func ContextWithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// JWTOption
+// adds a requirement JWT() checks tokens against.
+type JWTOption func(*jwtVerifier)
+
+// WithIssuer
+// requires tokens to be issued by the issuer, their "iss" claim.
+func WithIssuer(issuer string) JWTOption {
+	return func(v *jwtVerifier) {
+		v.issuer = issuer
+	}
+}
+
+// WithAudience
+// requires tokens to be meant for the audience, one of their "aud"
+// claim.
+func WithAudience(audience string) JWTOption {
+	return func(v *jwtVerifier) {
+		v.audience = audience
+	}
+}
+
+// WithLeeway
+// allows for clock skew when checking the "exp" and "nbf" claims.
+func WithLeeway(leeway time.Duration) JWTOption {
+	return func(v *jwtVerifier) {
+		v.leeway = leeway
+	}
+}
+
+// WithRequiredExpiry
+// rejects tokens without an "exp" claim, which never expire otherwise.
+func WithRequiredExpiry() JWTOption {
+	return func(v *jwtVerifier) {
+		v.requireExp = true
+	}
+}
+
+// JWT
+// is middleware that authenticates requests with a bearer token in their
+// Authorization header. Tokens have to be signed with HS256, RS256 or
+// ES256 by a key of the key set, must not be expired and must meet the
+// options. The token's claims are stored in the request's context for
+// ClaimsFromContext(). A "401 Unauthorized" problem is replied with
+// otherwise.
+func JWT(keys KeySet, opts ...JWTOption) mux.MiddlewareFunc {
+	v := &jwtVerifier{keys: keys, now: time.Now}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !strings.EqualFold(scheme, "Bearer") || token == "" {
+				unauthorized(w, r, `Bearer`, ErrMissingCredentials)
+				return
+			}
+
+			claims, err := v.verify(r.Context(), strings.TrimSpace(token))
+			if err != nil {
+				// e.g. a key set that could not be fetched, which is not the client's to know
+				if !errors.Is(err, ErrInvalidToken) && !errors.Is(err, ErrExpiredToken) && !errors.Is(err, ErrUnknownKey) {
+					logging.FromContext(r.Context()).Warn().Err(err).Msg("could not verify token")
+					err = ErrInvalidToken
+				}
+				unauthorized(w, r, `Bearer error="invalid_token"`, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// APIKey
+// is middleware that authenticates requests with an API key in the header,
+// X-API-Key when empty. Keys are compared in constant time, and the claims
+// of the request's key, e.g. its "sub" and "roles", are stored in the
+// request's context for ClaimsFromContext(). A "401 Unauthorized" problem
+// is replied with for missing or unknown keys.
+func APIKey(header string, keys map[string]Claims) mux.MiddlewareFunc {
+	if header == "" {
+		header = APIKeyHeader
+	}
+
+	// keys are hashed so every comparison takes as long
+	hashed := make(map[[sha256.Size]byte]Claims, len(keys))
+	for key, claims := range keys {
+		if claims == nil {
+			claims = Claims{}
+		}
+		hashed[sha256.Sum256([]byte(key))] = claims
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(header)
+			if key == "" {
+				unauthorized(w, r, "", ErrMissingCredentials)
+				return
+			}
+
+			sum := sha256.Sum256([]byte(key))
+			var claims Claims
+			for h, c := range hashed {
+				if subtle.ConstantTimeCompare(h[:], sum[:]) == 1 {
+					claims = c
+				}
+			}
+			if claims == nil {
+				unauthorized(w, r, "", ErrInvalidAPIKey)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// RequireRoles
+// is middleware that only lets requests through whose claims have at
+// least one of the roles. It goes after JWT() or APIKey(), on a subrouter
+// or around a single route's handler. A "401 Unauthorized" problem is
+// replied with for requests that were not authenticated and a "403
+// Forbidden" one for requests without the roles.
+func RequireRoles(roles ...string) mux.MiddlewareFunc {
+	return authorize("role", func(c Claims) bool {
+		for _, role := range roles {
+			if contains(c.Roles(), role) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RequireScopes
+// is middleware that only lets requests through whose claims have all of
+// the scopes, like RequireRoles().
+func RequireScopes(scopes ...string) mux.MiddlewareFunc {
+	return authorize("scope", func(c Claims) bool {
+		granted := c.Scopes()
+		for _, scope := range scopes {
+			if !contains(granted, scope) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+/********** helper functions **********/
+
+// authorize
+// returns middleware that lets requests through when their claims are
+// allowed.
+func authorize(kind string, allowed func(Claims) bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := ClaimsFromContext(r.Context())
+			switch {
+			case claims == nil:
+				unauthorized(w, r, "", ErrMissingCredentials)
+			case !allowed(claims):
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+				RespondProblem(w, r, NewProblem(http.StatusForbidden, fmt.Sprintf("missing a required %s", kind)))
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// unauthorized
+// replies with a "401 Unauthorized" problem and the challenge, if any.
+func unauthorized(w http.ResponseWriter, r *http.Request, challenge string, err error) {
+	if challenge != "" {
+		w.Header().Set("WWW-Authenticate", challenge)
+	}
+	RespondProblem(w, r, NewProblem(http.StatusUnauthorized, err.Error()))
+}
+
+// stringList
+// returns a claim that is either a string or a list of them.
+func (c Claims) stringList(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// unixTime
+// returns a claim holding seconds since the epoch, e.g. "exp", and whether
+// the token has it. ErrInvalidToken is returned when the claim is not a
+// number.
+func (c Claims) unixTime(name string) (time.Time, bool, error) {
+	claim, ok := c[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	switch v := claim.(type) {
+	case float64:
+		// split the seconds off so times past 2262 don't overflow
+		sec, frac := math.Modf(v)
+		if sec >= math.MaxInt64 || sec < math.MinInt64 {
+			return time.Time{}, true, ErrInvalidToken
+		}
+		return time.Unix(int64(sec), int64(frac*float64(time.Second))), true, nil
+	case int64:
+		return time.Unix(v, 0), true, nil
+	case int:
+		return time.Unix(int64(v), 0), true, nil
+	}
+	return time.Time{}, true, ErrInvalidToken
+}
+
+// contains
+// reports whether the values hold the value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
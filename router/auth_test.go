@@ -0,0 +1,150 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+)
+
+// whoami
+// replies with the subject of the request's claims.
+func whoami(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, ClaimsFromContext(r.Context()).Subject()) // nolint:errcheck
+}
+
+func TestJWT(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(JWT(StaticKey(testSecret), WithAudience("users")))
+	r.HandleFunc("/me", whoami)
+
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(RequireRoles("admin", "owner"))
+	admin.HandleFunc("/me", whoami)
+
+	r.Handle("/orders", RequireScopes("orders:read", "orders:write")(http.HandlerFunc(whoami)))
+
+	exp := time.Now().Add(time.Hour).Unix()
+	type auth struct {
+		Code      int
+		Challenge string
+		Body      string
+	}
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		resp   auth
+	}{
+		{
+			name:   "authenticated",
+			path:   "/me",
+			header: "Bearer " + sign(t, HS256, "", Claims{"sub": "ada", "aud": "users", "exp": exp}),
+			resp:   auth{Code: 200, Body: "ada"},
+		},
+		{
+			name: "no token",
+			path: "/me",
+			resp: auth{Code: 401, Challenge: "Bearer", Body: `{"detail":"missing credentials","instance":"/me","status":401,"title":"Unauthorized","type":"about:blank"}`},
+		},
+		{
+			name:   "basic auth",
+			path:   "/me",
+			header: "Basic YWRhOnNlY3JldA==",
+			resp:   auth{Code: 401, Challenge: "Bearer", Body: `{"detail":"missing credentials","instance":"/me","status":401,"title":"Unauthorized","type":"about:blank"}`},
+		},
+		{
+			name:   "expired",
+			path:   "/me",
+			header: "Bearer " + sign(t, HS256, "", Claims{"sub": "ada", "aud": "users", "exp": time.Now().Add(-time.Hour).Unix()}),
+			resp:   auth{Code: 401, Challenge: `Bearer error="invalid_token"`, Body: `{"detail":"token expired","instance":"/me","status":401,"title":"Unauthorized","type":"about:blank"}`},
+		},
+		{
+			name:   "role",
+			path:   "/admin/me",
+			header: "Bearer " + sign(t, HS256, "", Claims{"sub": "ada", "aud": "users", "roles": []string{"owner"}}),
+			resp:   auth{Code: 200, Body: "ada"},
+		},
+		{
+			name:   "missing role",
+			path:   "/admin/me",
+			header: "Bearer " + sign(t, HS256, "", Claims{"sub": "ada", "aud": "users", "roles": []string{"member"}}),
+			resp:   auth{Code: 403, Challenge: `Bearer error="insufficient_scope"`, Body: `{"detail":"missing a required role","instance":"/admin/me","status":403,"title":"Forbidden","type":"about:blank"}`},
+		},
+		{
+			name:   "scopes",
+			path:   "/orders",
+			header: "Bearer " + sign(t, HS256, "", Claims{"sub": "ada", "aud": "users", "scope": "orders:read orders:write"}),
+			resp:   auth{Code: 200, Body: "ada"},
+		},
+		{
+			name:   "missing scope",
+			path:   "/orders",
+			header: "Bearer " + sign(t, HS256, "", Claims{"sub": "ada", "aud": "users", "scp": []string{"orders:read"}}),
+			resp:   auth{Code: 403, Challenge: `Bearer error="insufficient_scope"`, Body: `{"detail":"missing a required scope","instance":"/orders","status":403,"title":"Forbidden","type":"about:blank"}`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.header != "" {
+				req.Header.Set("Authorization", test.header)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			got := auth{Code: rec.Code, Challenge: rec.Header().Get("WWW-Authenticate"), Body: rec.Body.String()}
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("JWT() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	keys := map[string]Claims{
+		"key-1": {"sub": "billing", "roles": []string{"admin"}},
+		"key-2": nil,
+	}
+	h := APIKey("", keys)(RequireRoles("admin")(http.HandlerFunc(whoami)))
+
+	tests := []struct {
+		name string
+		key  string
+		code int
+		body string
+	}{
+		{name: "authorized", key: "key-1", code: 200, body: "billing"},
+		{name: "missing role", key: "key-2", code: 403, body: "missing a required role"},
+		{name: "unknown key", key: "key-3", code: 401, body: "invalid API key"},
+		{name: "no key", code: 401, body: "missing credentials"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.key != "" {
+				req.Header.Set(APIKeyHeader, test.key)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != test.code || !strings.Contains(rec.Body.String(), test.body) {
+				t.Errorf("APIKey() = %d %s, want %d with %q", rec.Code, rec.Body.String(), test.code, test.body)
+			}
+		})
+	}
+}
+
+func TestRequireRoles_Unauthenticated(t *testing.T) {
+	rec := httptest.NewRecorder()
+	RequireRoles("admin")(http.HandlerFunc(whoami)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("RequireRoles() code = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,538 @@
+package router
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the default limit on the size of a request body Bind() reads
+const DefaultMaxBodySize int64 = 1 << 20 // 1 MiB
+
+// the struct tags Bind() reads
+const (
+	pathTag     = "path"
+	queryTag    = "query"
+	formTag     = "form"
+	validateTag = "validate"
+)
+
+var (
+	ErrBodyTooLarge         = errors.New("request body too large")              // the body is larger than the limit
+	ErrUnsupportedMediaType = errors.New("unsupported content type")            // the body's content type cannot be decoded
+	ErrMalformedBody        = errors.New("malformed request body")              // the body cannot be decoded
+	ErrInvalidRequest       = errors.New("request parameters did not validate") // fields are missing or invalid
+)
+
+// A BindError is returned by Bind() when a request cannot be bound or does
+// not validate. RespondError(), RespondErrorTo() and RespondProblem() reply
+// with its status and the invalid fields.
+type BindError struct {
+	// 400 for a malformed request, 413 for a body that is too large, 415
+	// for a content type that cannot be decoded and 422 for fields that
+	// did not validate
+	Status int
+
+	// the fields that are invalid, if any
+	Params []InvalidParam
+
+	// the cause of the error
+	Err error
+}
+
+// Error
+// implements the error interface.
+func (e *BindError) Error() string {
+	if len(e.Params) == 0 {
+		return e.Err.Error()
+	}
+	reasons := make([]string, len(e.Params))
+	for i, p := range e.Params {
+		reasons[i] = p.Name + " " + p.Reason
+	}
+	return e.Err.Error() + ": " + strings.Join(reasons, ", ")
+}
+
+// Unwrap
+// returns the cause of the error.
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// BindOption
+// customizes how Bind() reads a request.
+type BindOption func(*bindOptions)
+
+type bindOptions struct {
+	maxBodySize  int64
+	allowUnknown bool
+}
+
+// WithMaxBodySize
+// limits the request body to n bytes instead of DefaultMaxBodySize.
+func WithMaxBodySize(n int64) BindOption {
+	return func(o *bindOptions) {
+		o.maxBodySize = n
+	}
+}
+
+// AllowUnknownFields
+// accepts JSON, XML and form bodies with fields or elements the struct
+// does not have, which are rejected by default.
+func AllowUnknownFields() BindOption {
+	return func(o *bindOptions) {
+		o.allowUnknown = true
+	}
+}
+
+// Bind
+// reads a request into the struct dst points to and validates it:
+//   - a JSON, XML or form body is decoded by its Content-Type; JSON fields
+//     use "json" tags, XML fields "xml" tags and form fields "form" tags
+//   - query params are read into fields with a "query" tag
+//   - path variables from Vars() are read into fields with a "path" tag
+//   - fields are then checked against their "validate" tags, see Validate()
+//
+// Later sources replace earlier ones. A *BindError is returned when the
+// request cannot be read or does not validate.
+func Bind(r *http.Request, dst interface{}, opts ...BindOption) error {
+	o := bindOptions{maxBodySize: DefaultMaxBodySize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%s: bind destination must be a pointer to a struct, got %T", packageKey, dst)
+	}
+
+	if err := bindBody(r, dst, v.Elem(), o); err != nil {
+		return err
+	}
+
+	var params []InvalidParam
+	params = append(params, bindValues(v.Elem(), queryTag, r.URL.Query())...)
+
+	vars := url.Values{}
+	for k, value := range Vars(r) {
+		vars.Set(k, value)
+	}
+	params = append(params, bindValues(v.Elem(), pathTag, vars)...)
+	if len(params) > 0 {
+		return &BindError{Status: http.StatusBadRequest, Params: params, Err: ErrInvalidRequest}
+	}
+
+	return Validate(dst)
+}
+
+/********** helper functions **********/
+
+// bindBody
+// decodes the request body, if any, by its content type.
+func bindBody(r *http.Request, dst interface{}, v reflect.Value, o bindOptions) error {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+	if r.ContentLength > o.maxBodySize {
+		return &BindError{Status: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+	}
+	r.Body = http.MaxBytesReader(nil, r.Body, o.maxBodySize)
+
+	mediaType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(ct); err != nil {
+			return &BindError{Status: http.StatusUnsupportedMediaType, Err: ErrUnsupportedMediaType}
+		}
+	}
+
+	var err error
+	switch {
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		err = decodeJSON(r.Body, dst, o.allowUnknown)
+	case mediaType == "application/xml", mediaType == "text/xml", strings.HasSuffix(mediaType, "+xml"):
+		err = decodeXML(r.Body, dst, o.allowUnknown)
+	case mediaType == "application/x-www-form-urlencoded", mediaType == "multipart/form-data":
+		err = decodeForm(r, v, o)
+	default:
+		return &BindError{Status: http.StatusUnsupportedMediaType, Err: ErrUnsupportedMediaType}
+	}
+	if err == nil {
+		return nil
+	}
+
+	var be *BindError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &be):
+		return be
+	case errors.As(err, &tooLarge):
+		return &BindError{Status: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+	}
+	return &BindError{Status: http.StatusBadRequest, Err: ErrMalformedBody}
+}
+
+// decodeJSON
+// decodes a single JSON value, reporting unknown fields and fields of the
+// wrong type as invalid params.
+func decodeJSON(body io.Reader, dst interface{}, allowUnknown bool) error {
+	dec := json.NewDecoder(body)
+	if !allowUnknown {
+		dec.DisallowUnknownFields()
+	}
+
+	err := dec.Decode(dst)
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil
+	case errors.As(err, &typeErr):
+		return &BindError{Status: http.StatusBadRequest, Err: ErrInvalidRequest, Params: []InvalidParam{
+			{Name: typeErr.Field, Reason: typeReason(typeErr.Type)},
+		}}
+	case err != nil && strings.HasPrefix(err.Error(), "json: unknown field "):
+		name, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		return &BindError{Status: http.StatusBadRequest, Err: ErrInvalidRequest, Params: []InvalidParam{
+			{Name: name, Reason: "is not allowed"},
+		}}
+	case err != nil:
+		return err
+	}
+
+	if dec.More() {
+		return errors.New("body must hold a single JSON value")
+	}
+	return nil
+}
+
+// decodeXML
+// decodes an XML body, reporting elements the struct has no field for as
+// invalid params.
+func decodeXML(body io.Reader, dst interface{}, allowUnknown bool) error {
+	var buf bytes.Buffer
+	if !allowUnknown {
+		body = io.TeeReader(body, &buf)
+	}
+
+	err := xml.NewDecoder(body).Decode(dst)
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil
+	case err != nil:
+		return err
+	case allowUnknown:
+		return nil
+	}
+
+	name, err := unknownElement(xml.NewDecoder(&buf), reflect.TypeOf(dst).Elem())
+	if err != nil || name == "" {
+		return err
+	}
+	return &BindError{Status: http.StatusBadRequest, Err: ErrInvalidRequest, Params: []InvalidParam{
+		{Name: name, Reason: "is not allowed"},
+	}}
+}
+
+// unknownElement
+// returns the name of the first element within the root element that
+// the struct type t has no field for, matching them like xml.Unmarshal().
+func unknownElement(dec *xml.Decoder, t reflect.Type) (string, error) {
+	// the struct type of every open element, nil when it takes anything
+	var open []reflect.Type
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if len(open) == 0 {
+				open = append(open, xmlStruct(t))
+				continue
+			}
+			parent := open[len(open)-1]
+			if parent == nil {
+				open = append(open, nil)
+				continue
+			}
+			ft, ok := xmlField(parent, tok.Name.Local)
+			if !ok {
+				return tok.Name.Local, nil
+			}
+			open = append(open, ft)
+		case xml.EndElement:
+			if open = open[:len(open)-1]; len(open) == 0 {
+				return "", nil
+			}
+		}
+	}
+}
+
+// xmlField
+// returns the struct type an element of the struct type t is decoded
+// into, nil when it takes anything, and whether t has a field for it.
+func xmlField(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || sf.Name == "XMLName" {
+			continue
+		}
+		tag, opts, _ := strings.Cut(sf.Tag.Get("xml"), ",")
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && tag == "" && xmlStruct(sf.Type) != nil {
+			if ft, ok := xmlField(xmlStruct(sf.Type), name); ok {
+				return ft, true
+			}
+			continue
+		}
+
+		switch flags := "," + opts + ","; {
+		case strings.Contains(flags, ",attr,"), strings.Contains(flags, ",chardata,"),
+			strings.Contains(flags, ",cdata,"), strings.Contains(flags, ",comment,"):
+			continue
+		case strings.Contains(flags, ",any,"), strings.Contains(flags, ",innerxml,"):
+			return nil, true
+		}
+
+		if tag == "" {
+			tag = xmlName(sf)
+		}
+		if i := strings.LastIndex(tag, " "); i >= 0 {
+			tag = tag[i+1:] // drops the namespace
+		}
+		first, _, nested := strings.Cut(tag, ">")
+		if first != name {
+			continue
+		}
+		if nested {
+			return nil, true
+		}
+		return xmlStruct(sf.Type), true
+	}
+	return nil, false
+}
+
+// xmlName
+// returns the element name of an untagged field: the name its type's
+// XMLName field is tagged with, or else the field's name.
+func xmlName(sf reflect.StructField) string {
+	if st := xmlStruct(sf.Type); st != nil {
+		if xn, ok := st.FieldByName("XMLName"); ok {
+			if name, _, _ := strings.Cut(xn.Tag.Get("xml"), ","); name != "" {
+				return name
+			}
+		}
+	}
+	return sf.Name
+}
+
+// xmlStruct
+// returns the struct type whose fields the elements of a t are decoded
+// into, or nil when t is not a struct or decodes itself.
+func xmlStruct(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if (t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8) || t.Kind() == reflect.Array {
+		return xmlStruct(t.Elem())
+	}
+	pt := reflect.PointerTo(t)
+	if t.Kind() != reflect.Struct || pt.Implements(xmlUnmarshaler) || pt.Implements(textUnmarshaler) {
+		return nil
+	}
+	return t
+}
+
+// decodeForm
+// parses a form body into the fields with a "form" tag.
+func decodeForm(r *http.Request, v reflect.Value, o bindOptions) error {
+	if err := r.ParseMultipartForm(o.maxBodySize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+
+	if !o.allowUnknown {
+		known := make(map[string]bool)
+		for _, f := range taggedFields(v, formTag) {
+			known[f.name] = true
+		}
+		var params []InvalidParam
+		for key := range r.PostForm {
+			if !known[key] {
+				params = append(params, InvalidParam{Name: key, Reason: "is not allowed"})
+			}
+		}
+		if len(params) > 0 {
+			sortParams(params)
+			return &BindError{Status: http.StatusBadRequest, Err: ErrInvalidRequest, Params: params}
+		}
+	}
+
+	if params := bindValues(v, formTag, r.PostForm); len(params) > 0 {
+		return &BindError{Status: http.StatusBadRequest, Err: ErrInvalidRequest, Params: params}
+	}
+	return nil
+}
+
+// A struct field read from a tagged source.
+type taggedField struct {
+	name  string
+	value reflect.Value
+	tag   reflect.StructTag
+}
+
+// taggedFields
+// returns the settable fields of a struct with the tag, including those of
+// embedded structs.
+func taggedFields(v reflect.Value, tag string) []taggedField {
+	var fields []taggedField
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, taggedFields(v.Field(i), tag)...)
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, taggedField{name: name, value: v.Field(i), tag: sf.Tag})
+	}
+	return fields
+}
+
+// bindValues
+// sets the fields with the tag from the values, returning the fields
+// whose values could not be parsed.
+func bindValues(v reflect.Value, tag string, values url.Values) []InvalidParam {
+	var params []InvalidParam
+	for _, f := range taggedFields(v, tag) {
+		vals, ok := values[f.name]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+		if err := setValue(f.value, vals); err != nil {
+			params = append(params, InvalidParam{Name: f.name, Reason: typeReason(f.value.Type())})
+		}
+	}
+	return params
+}
+
+var (
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	xmlUnmarshaler  = reflect.TypeOf((*xml.Unmarshaler)(nil)).Elem()
+	durationType    = reflect.TypeOf(time.Duration(0))
+)
+
+// setValue
+// parses the values into a field: a slice gets every value, anything else
+// the first.
+func setValue(field reflect.Value, vals []string) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 && !reflect.PointerTo(field.Type()).Implements(textUnmarshaler) {
+		slice := reflect.MakeSlice(field.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setString(slice.Index(i), val); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setString(field, vals[0])
+}
+
+// setString
+// parses a single value into a field.
+func setString(field reflect.Value, val string) error {
+	if field.Kind() == reflect.Pointer {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setString(ptr.Elem(), val); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshaler) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.Type() == durationType {
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("%s: cannot bind a value to a %s", packageKey, field.Type())
+	}
+	return nil
+}
+
+// typeReason
+// describes the kind of value a field of the type expects.
+func typeReason(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	switch {
+	case t == durationType:
+		return "must be a duration"
+	case t.Kind() == reflect.Bool:
+		return "must be true or false"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return "must be an integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return "must be a number"
+	case t.Kind() == reflect.String:
+		return "must be a string"
+	case t.Kind() == reflect.Struct || t.Kind() == reflect.Map:
+		return "must be an object"
+	}
+	return "is invalid"
+}
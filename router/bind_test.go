@@ -0,0 +1,242 @@
+package router
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type user struct {
+	ID      int           `path:"id" json:"-" validate:"required,min=1"`
+	Name    string        `json:"name" xml:"name" form:"name" validate:"required,max=8"`
+	Email   string        `json:"email,omitempty" xml:"email" form:"email" validate:"email"`
+	Role    string        `json:"role,omitempty" form:"role" validate:"oneof=admin member"`
+	Page    int           `query:"page" json:"-" validate:"min=1"`
+	Tags    []string      `query:"tag" json:"-" validate:"max=2"`
+	Timeout time.Duration `query:"timeout" json:"-"`
+	Address *address      `json:"address,omitempty"`
+}
+
+func TestBind(t *testing.T) {
+	type args struct {
+		contentType string
+		body        string
+		query       string
+		opts        []BindOption
+	}
+	tests := []struct {
+		name string
+		args args
+		want user
+		err  *BindError
+	}{
+		{
+			name: "json",
+			args: args{contentType: "application/json", body: `{"name":"ada","email":"ada@example.com","address":{"city":"London"}}`, query: "?page=2&tag=a&tag=b&timeout=5s"},
+			want: user{ID: 42, Name: "ada", Email: "ada@example.com", Page: 2, Tags: []string{"a", "b"}, Timeout: 5 * time.Second, Address: &address{City: "London"}},
+		},
+		{
+			name: "xml",
+			args: args{contentType: "application/xml", body: `<user><name>ada</name></user>`},
+			want: user{ID: 42, Name: "ada"},
+		},
+		{
+			name: "nested xml",
+			args: args{contentType: "application/xml", body: `<user><name>ada</name><Address><City>London</City></Address></user>`},
+			want: user{ID: 42, Name: "ada", Address: &address{City: "London"}},
+		},
+		{
+			name: "unknown xml element",
+			args: args{contentType: "application/xml", body: `<user><name>ada</name><Address><City>London</City><zip>N1</zip></Address></user>`},
+			err:  &BindError{Status: 400, Err: ErrInvalidRequest, Params: []InvalidParam{{Name: "zip", Reason: "is not allowed"}}},
+		},
+		{
+			name: "unknown xml element allowed",
+			args: args{contentType: "application/xml", body: `<user><name>ada</name><admin>true</admin></user>`, opts: []BindOption{AllowUnknownFields()}},
+			want: user{ID: 42, Name: "ada"},
+		},
+		{
+			name: "form",
+			args: args{contentType: "application/x-www-form-urlencoded", body: "name=ada&role=admin"},
+			want: user{ID: 42, Name: "ada", Role: "admin"},
+		},
+		{
+			name: "json by default",
+			args: args{body: `{"name":"ada"}`},
+			want: user{ID: 42, Name: "ada"},
+		},
+		{
+			name: "unknown json field",
+			args: args{contentType: "application/json", body: `{"name":"ada","admin":true}`},
+			err:  &BindError{Status: 400, Err: ErrInvalidRequest, Params: []InvalidParam{{Name: "admin", Reason: "is not allowed"}}},
+		},
+		{
+			name: "unknown json field allowed",
+			args: args{contentType: "application/json", body: `{"name":"ada","admin":true}`, opts: []BindOption{AllowUnknownFields()}},
+			want: user{ID: 42, Name: "ada"},
+		},
+		{
+			name: "unknown form fields",
+			args: args{contentType: "application/x-www-form-urlencoded", body: "name=ada&b=1&a=1"},
+			err:  &BindError{Status: 400, Err: ErrInvalidRequest, Params: []InvalidParam{{Name: "a", Reason: "is not allowed"}, {Name: "b", Reason: "is not allowed"}}},
+		},
+		{
+			name: "json type mismatch",
+			args: args{contentType: "application/json", body: `{"name":7}`},
+			err:  &BindError{Status: 400, Err: ErrInvalidRequest, Params: []InvalidParam{{Name: "name", Reason: "must be a string"}}},
+		},
+		{
+			name: "invalid query param",
+			args: args{contentType: "application/json", body: `{"name":"ada"}`, query: "?page=two"},
+			err:  &BindError{Status: 400, Err: ErrInvalidRequest, Params: []InvalidParam{{Name: "page", Reason: "must be an integer"}}},
+		},
+		{
+			name: "malformed json",
+			args: args{contentType: "application/json", body: `{"name":`},
+			err:  &BindError{Status: 400, Err: ErrMalformedBody},
+		},
+		{
+			name: "too large",
+			args: args{contentType: "application/json", body: `{"name":"ada"}`, opts: []BindOption{WithMaxBodySize(4)}},
+			err:  &BindError{Status: 413, Err: ErrBodyTooLarge},
+		},
+		{
+			name: "unsupported media type",
+			args: args{contentType: "text/csv", body: "name\nada"},
+			err:  &BindError{Status: 415, Err: ErrUnsupportedMediaType},
+		},
+		{
+			name: "does not validate",
+			args: args{contentType: "application/json", body: `{"email":"ada","role":"owner","address":{}}`, query: "?page=-1&tag=a&tag=b&tag=c"},
+			err: &BindError{Status: 422, Err: ErrInvalidRequest, Params: []InvalidParam{
+				{Name: "name", Reason: "is required"},
+				{Name: "email", Reason: "must be an email address"},
+				{Name: "role", Reason: "must be one of admin, member"},
+				{Name: "page", Reason: "must be at least 1"},
+				{Name: "tag", Reason: "must have at most 2 items"},
+				{Name: "address.city", Reason: "is required"},
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users/42"+test.args.query, strings.NewReader(test.args.body))
+			req.Header.Set("Content-Type", test.args.contentType)
+			req = mux.SetURLVars(req, map[string]string{"id": "42"})
+
+			var got user
+			err := Bind(req, &got, test.args.opts...)
+
+			var be *BindError
+			switch {
+			case test.err == nil && err != nil:
+				t.Fatalf("Bind() error = %s", err)
+			case test.err != nil && !errors.As(err, &be):
+				t.Fatalf("Bind() error = %v, want a *BindError", err)
+			case test.err != nil:
+				if diff := cmp.Diff(test.err.Status, be.Status); diff != "" {
+					t.Errorf("Bind() status mismatch (-want +got):\n%s", diff)
+				}
+				if diff := cmp.Diff(test.err.Params, be.Params); diff != "" {
+					t.Errorf("Bind() params mismatch (-want +got):\n%s", diff)
+				}
+				if !errors.Is(err, test.err.Err) {
+					t.Errorf("Bind() error = %s, want %s", err, test.err.Err)
+				}
+				return
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Bind() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_unknownElement(t *testing.T) {
+	type item struct {
+		XMLName xml.Name `xml:"entry"`
+		Value   string   `xml:"value"`
+	}
+	type order struct {
+		ID    string `xml:"id,attr"`
+		Note  string `xml:",chardata"`
+		Items []item `xml:"items>entry"`
+		Item  item
+		Tags  []string `xml:"urn:tags tag"`
+		When  time.Time
+	}
+	type anything struct {
+		Name string   `xml:"name"`
+		Rest []string `xml:",any"`
+	}
+	tests := []struct {
+		name string
+		typ  reflect.Type
+		body string
+		want string
+	}{
+		{name: "known", typ: reflect.TypeOf(order{}), body: `<order id="1">note<items><entry><value>a</value></entry></items><tag xmlns="urn:tags">x</tag><When>2024-01-02T00:00:00Z</When></order>`},
+		{name: "named by XMLName", typ: reflect.TypeOf(order{}), body: `<order><entry><value>a</value></entry></order>`},
+		{name: "unknown", typ: reflect.TypeOf(order{}), body: `<order><Items></Items></order>`, want: "Items"},
+		{name: "unknown nested", typ: reflect.TypeOf(order{}), body: `<order><entry><price>1</price></entry></order>`, want: "price"},
+		{name: "attribute field", typ: reflect.TypeOf(order{}), body: `<order><id>1</id></order>`, want: "id"},
+		{name: "any", typ: reflect.TypeOf(anything{}), body: `<anything><name>a</name><extra><more/></extra></anything>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := unknownElement(xml.NewDecoder(strings.NewReader(test.body)), test.typ)
+			if err != nil {
+				t.Fatalf("unknownElement() error = %s", err)
+			}
+			if got != test.want {
+				t.Errorf("unknownElement() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBind_InvalidDestination(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	var be *BindError
+	if err := Bind(req, user{}); err == nil || errors.As(err, &be) {
+		t.Errorf("Bind() error = %v, want an invalid destination error", err)
+	}
+}
+
+func TestValidate_UnknownRule(t *testing.T) {
+	v := struct {
+		Name string `validate:"uppercase"`
+	}{Name: "ada"}
+
+	var be *BindError
+	if err := Validate(&v); err == nil || errors.As(err, &be) {
+		t.Errorf("Validate() error = %v, want an unknown rule error", err)
+	}
+}
+
+func TestRespondError_Bind(t *testing.T) {
+	err := &BindError{Status: http.StatusUnprocessableEntity, Err: ErrInvalidRequest, Params: []InvalidParam{{Name: "name", Reason: "is required"}}}
+
+	rec := httptest.NewRecorder()
+	RespondError(rec, json.Marshal, http.StatusBadRequest, err)
+
+	body := `{"error":"request parameters did not validate: name is required","fields":[{"name":"name","reason":"is required"}]}`
+	want := reply{Code: 422, ContentType: "application/json", Length: "115", Body: body}
+	if diff := cmp.Diff(want, recordReply(rec)); diff != "" {
+		t.Errorf("RespondError() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,335 @@
+package router
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// the smallest reply Compress() compresses by default, in bytes
+const DefaultCompressMinSize = 1024
+
+// the content types Compress() compresses by default; "text/*" stands for
+// every text type
+var DefaultCompressTypes = []string{
+	ContentTypeJSON,
+	ContentTypeProblem,
+	ContentTypeXML,
+	"application/javascript",
+	"image/svg+xml",
+	"text/*",
+}
+
+// CompressOption
+// changes which replies Compress() compresses.
+type CompressOption func(*compressor)
+
+// WithMinSize
+// only compresses replies of at least n bytes, smaller ones are not worth
+// it.
+func WithMinSize(n int) CompressOption {
+	return func(c *compressor) {
+		c.minSize = n
+	}
+}
+
+// WithContentTypes
+// only compresses replies of the content types, e.g. "application/json"
+// or "text/*", instead of DefaultCompressTypes.
+func WithContentTypes(types ...string) CompressOption {
+	return func(c *compressor) {
+		c.types = types
+	}
+}
+
+// Compress
+// is middleware that compresses replies with gzip or deflate, whichever
+// the request's Accept-Encoding header prefers. Only replies of at least
+// DefaultCompressMinSize bytes with one of DefaultCompressTypes are
+// compressed, and never replies that already have a Content-Encoding, to
+// HEAD requests or to range requests. Replies are buffered until they are
+// large enough, so the Content-Length header is dropped from compressed
+// replies.
+func Compress(opts ...CompressOption) mux.MiddlewareFunc {
+	c := &compressor{minSize: DefaultCompressMinSize, types: DefaultCompressTypes}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := acceptedEncoding(r.Header.Values("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, c: c, encoding: encoding, code: http.StatusOK}
+			next.ServeHTTP(cw, r)
+			cw.close() // nolint:errcheck
+		})
+	}
+}
+
+/********** helper functions **********/
+
+// the encoders compressed replies are written with, pooled since they are
+// costly to create
+var (
+	gzipPool = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	zlibPool = sync.Pool{New: func() any { return zlib.NewWriter(io.Discard) }}
+)
+
+// A gzip or zlib writer.
+type encodingWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Which replies are compressed.
+type compressor struct {
+	minSize int
+	types   []string
+}
+
+// allows
+// reports whether replies of the content type are compressed.
+func (c *compressor) allows(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.types {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptedEncoding
+// returns the encoding of an Accept-Encoding header the middleware
+// supports with the highest quality, gzip when tied, or "" when neither is
+// accepted.
+func acceptedEncoding(values []string) string {
+	weights := make(map[string]float64)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			name, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if f, err := strconv.ParseFloat(params["q"], 64); err == nil {
+				q = f
+			}
+			weights[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{"gzip", "deflate"} {
+		q, ok := weights[encoding]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// A response writer that buffers the reply until it knows whether to
+// compress it: once the reply reaches the minimum size, when it is
+// flushed or when the handler returns.
+type compressWriter struct {
+	http.ResponseWriter
+	c        *compressor
+	encoding string
+
+	code        int
+	wroteHeader bool           // the handler wrote its status
+	decided     bool           // the status was sent, compressed or not
+	enc         encodingWriter // nil when the reply is not compressed
+	buf         []byte
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	// informational replies are sent right away
+	if code >= 100 && code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.code, w.wroteHeader = code, true
+	if w.ruledOut() {
+		w.start(false) // nolint:errcheck
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.c.minSize {
+		if err := w.start(w.compressible()); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush
+// sends what was written so far, deciding whether to compress the reply
+// if that is still open.
+func (w *compressWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.start(w.compressible()) // nolint:errcheck
+	}
+	if w.enc != nil {
+		w.enc.Flush() // nolint:errcheck
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack
+// implements the http.Hijacker interface when the wrapped writer does.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: response writer cannot be hijacked", packageKey)
+	}
+	return hj.Hijack()
+}
+
+// Unwrap
+// returns the wrapped response writer.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ruledOut
+// reports whether the headers already tell the reply is not compressed.
+func (w *compressWriter) ruledOut() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || !bodyAllowed(w.code) {
+		return true
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < w.c.minSize {
+		return true
+	}
+	contentType := h.Get("Content-Type")
+	return contentType != "" && !w.c.allows(contentType)
+}
+
+// compressible
+// reports whether the reply is compressed, detecting its content type
+// from what was written when it has none.
+func (w *compressWriter) compressible() bool {
+	if w.ruledOut() {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Type") == "" {
+		if len(w.buf) == 0 {
+			return false
+		}
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	return w.c.allows(h.Get("Content-Type"))
+}
+
+// start
+// sends the status and the buffered reply, compressed or not. A strong
+// ETag is made weak when compressing, as the bytes sent are no longer the
+// ones it was computed for.
+func (w *compressWriter) start(compress bool) error {
+	w.decided = true
+	if compress {
+		h := w.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+
+		if w.encoding == "gzip" {
+			w.enc = gzipPool.Get().(*gzip.Writer)
+		} else {
+			w.enc = zlibPool.Get().(*zlib.Writer)
+		}
+		w.enc.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close
+// sends a reply that stayed below the minimum size as is, or finishes the
+// compressed reply.
+func (w *compressWriter) close() error {
+	if !w.decided {
+		if !w.wroteHeader {
+			return nil
+		}
+		if err := w.start(false); err != nil {
+			return err
+		}
+	}
+	if w.enc == nil {
+		return nil
+	}
+
+	err := w.enc.Close()
+	w.enc.Reset(io.Discard)
+	switch enc := w.enc.(type) {
+	case *gzip.Writer:
+		gzipPool.Put(enc)
+	case *zlib.Writer:
+		zlibPool.Put(enc)
+	}
+	w.enc = nil
+	return err
+}
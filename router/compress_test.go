@@ -0,0 +1,205 @@
+package router
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"msg":"hello"}`, 100)
+
+	type args struct {
+		method          string
+		accept          string
+		contentType     string
+		contentEncoding string
+		etag            string
+		code            int
+		body            string
+	}
+	type compressed struct {
+		Code            int
+		ContentEncoding string
+		ContentType     string
+		Length          string
+		ETag            string
+		Vary            string
+		Body            string
+	}
+	tests := []struct {
+		name string
+		args args
+		resp compressed
+	}{
+		{
+			name: "gzip",
+			args: args{accept: "gzip, deflate, br", contentType: ContentTypeJSON, body: large},
+			resp: compressed{Code: 200, ContentEncoding: "gzip", ContentType: ContentTypeJSON, Vary: "Accept-Encoding", Body: large},
+		},
+		{
+			name: "deflate preferred",
+			args: args{accept: "gzip;q=0.5, deflate", contentType: ContentTypeProblem, code: http.StatusNotFound, body: large},
+			resp: compressed{Code: 404, ContentEncoding: "deflate", ContentType: ContentTypeProblem, Vary: "Accept-Encoding", Body: large},
+		},
+		{
+			name: "any but gzip",
+			args: args{accept: "gzip;q=0, *", contentType: "text/csv", body: large},
+			resp: compressed{Code: 200, ContentEncoding: "deflate", ContentType: "text/csv", Vary: "Accept-Encoding", Body: large},
+		},
+		{
+			name: "detected content type",
+			args: args{accept: "gzip", body: strings.Repeat("hello ", 200)},
+			resp: compressed{Code: 200, ContentEncoding: "gzip", ContentType: "text/plain; charset=utf-8", Vary: "Accept-Encoding", Body: strings.Repeat("hello ", 200)},
+		},
+		{
+			name: "strong etag made weak",
+			args: args{accept: "gzip", contentType: ContentTypeJSON, etag: `"v1"`, body: large},
+			resp: compressed{Code: 200, ContentEncoding: "gzip", ContentType: ContentTypeJSON, ETag: `W/"v1"`, Vary: "Accept-Encoding", Body: large},
+		},
+		{
+			name: "weak etag kept",
+			args: args{accept: "gzip", contentType: ContentTypeJSON, etag: `W/"v1"`, body: large},
+			resp: compressed{Code: 200, ContentEncoding: "gzip", ContentType: ContentTypeJSON, ETag: `W/"v1"`, Vary: "Accept-Encoding", Body: large},
+		},
+		{
+			name: "etag of an uncompressed reply kept",
+			args: args{accept: "gzip", contentType: ContentTypeJSON, etag: `"v1"`, body: `{"msg":"hello"}`},
+			resp: compressed{Code: 200, ContentType: ContentTypeJSON, Length: "15", ETag: `"v1"`, Vary: "Accept-Encoding", Body: `{"msg":"hello"}`},
+		},
+		{
+			name: "too small",
+			args: args{accept: "gzip", contentType: ContentTypeJSON, body: `{"msg":"hello"}`},
+			resp: compressed{Code: 200, ContentType: ContentTypeJSON, Length: "15", Vary: "Accept-Encoding", Body: `{"msg":"hello"}`},
+		},
+		{
+			name: "content type not allowed",
+			args: args{accept: "gzip", contentType: "image/png", body: large},
+			resp: compressed{Code: 200, ContentType: "image/png", Length: "1500", Vary: "Accept-Encoding", Body: large},
+		},
+		{
+			name: "already encoded",
+			args: args{accept: "gzip", contentType: ContentTypeJSON, contentEncoding: "br", body: large},
+			resp: compressed{Code: 200, ContentEncoding: "br", ContentType: ContentTypeJSON, Length: "1500", Vary: "Accept-Encoding", Body: large},
+		},
+		{
+			name: "not accepted",
+			args: args{contentType: ContentTypeJSON, body: large},
+			resp: compressed{Code: 200, ContentType: ContentTypeJSON, Length: "1500", Vary: "Accept-Encoding", Body: large},
+		},
+		{
+			name: "head",
+			args: args{method: http.MethodHead, accept: "gzip", contentType: ContentTypeJSON, body: large},
+			resp: compressed{Code: 200, ContentType: ContentTypeJSON, Length: "1500", Vary: "Accept-Encoding"},
+		},
+		{
+			name: "no content",
+			args: args{accept: "gzip", code: http.StatusNoContent},
+			resp: compressed{Code: 204, Vary: "Accept-Encoding"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := Compress()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.args.contentType != "" {
+					w.Header().Set("Content-Type", test.args.contentType)
+				}
+				if test.args.contentEncoding != "" {
+					w.Header().Set("Content-Encoding", test.args.contentEncoding)
+				}
+				if test.args.etag != "" {
+					w.Header().Set("ETag", test.args.etag)
+				}
+				if test.args.body != "" {
+					w.Header().Set("Content-Length", strconv.Itoa(len(test.args.body)))
+				}
+				if test.args.code != 0 {
+					w.WriteHeader(test.args.code)
+				}
+				// written in two parts to cross the minimum size halfway
+				half := len(test.args.body) / 2
+				io.WriteString(w, test.args.body[:half]) // nolint:errcheck
+				io.WriteString(w, test.args.body[half:]) // nolint:errcheck
+			}))
+
+			method := test.args.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/", nil)
+			if test.args.accept != "" {
+				req.Header.Set("Accept-Encoding", test.args.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			got := compressed{
+				Code:            rec.Code,
+				ContentEncoding: rec.Header().Get("Content-Encoding"),
+				ContentType:     rec.Header().Get("Content-Type"),
+				Length:          rec.Header().Get("Content-Length"),
+				ETag:            rec.Header().Get("ETag"),
+				Vary:            rec.Header().Get("Vary"),
+				Body:            decompress(t, rec),
+			}
+			if method == http.MethodHead {
+				got.Body = ""
+			}
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Compress() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompress_Options(t *testing.T) {
+	h := Compress(WithMinSize(10), WithContentTypes("application/x-ndjson"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, `{"n":1}`+"\n") // nolint:errcheck
+		w.(http.Flusher).Flush()
+		io.WriteString(w, `{"n":2}`+"\n") // nolint:errcheck
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding = %q, want a flushed reply to be compressed", got)
+	}
+	if diff := cmp.Diff(`{"n":1}`+"\n"+`{"n":2}`+"\n", decompress(t, rec)); diff != "" {
+		t.Errorf("Compress() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// decompress
+// returns the reply's body, decompressed by its Content-Encoding.
+func decompress(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var r io.Reader = rec.Body
+	var err error
+	switch rec.Header().Get("Content-Encoding") {
+	case "gzip":
+		r, err = gzip.NewReader(rec.Body)
+	case "deflate":
+		r, err = zlib.NewReader(rec.Body)
+	}
+	if err != nil {
+		t.Fatalf("decompress() error = %s", err)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompress() error = %s", err)
+	}
+	return string(b)
+}
@@ -0,0 +1,31 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/cors"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// CORS
+// is middleware that applies the cross-origin resource sharing policy of
+// the configs and answers preflight requests. Preflight requests use the
+// OPTIONS method, which routes usually do not match, so the middleware
+// has to wrap the router instead of being added with r.Use(), e.g.
+// "srv.Handler = router.CORS(conf.CORS)(r)". Servers created with
+// NewServer() wrap their router when origins are configured.
+func CORS(conf config.CORS) mux.MiddlewareFunc {
+	c := cors.New(cors.Options{
+		AllowedOrigins:   conf.AllowedOrigins,
+		AllowedMethods:   conf.AllowedMethods,
+		AllowedHeaders:   conf.AllowedHeaders,
+		ExposedHeaders:   conf.ExposedHeaders,
+		AllowCredentials: conf.AllowCredentials,
+		MaxAge:           conf.MaxAge,
+	})
+	return func(next http.Handler) http.Handler {
+		return c.Handler(next)
+	}
+}
@@ -0,0 +1,128 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+var testCORS = config.CORS{
+	AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+	AllowedMethods:   []string{http.MethodGet, http.MethodPut},
+	AllowedHeaders:   []string{"Authorization"},
+	ExposedHeaders:   []string{RequestIDHeader},
+	AllowCredentials: true,
+	MaxAge:           600,
+}
+
+func TestCORS(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "users") // nolint:errcheck
+	}).Methods(http.MethodGet, http.MethodPut)
+	h := CORS(testCORS)(r)
+
+	type cors struct {
+		Code          int
+		AllowOrigin   string
+		AllowMethods  string
+		AllowHeaders  string
+		ExposeHeaders string
+		MaxAge        string
+		Body          string
+	}
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		resp    cors
+	}{
+		{
+			name:    "preflight",
+			method:  http.MethodOptions,
+			headers: map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "Authorization"},
+			resp:    cors{Code: 204, AllowOrigin: "https://app.example.com", AllowMethods: "PUT", AllowHeaders: "Authorization", MaxAge: "600"},
+		},
+		{
+			name:    "preflight from a wildcard origin",
+			method:  http.MethodOptions,
+			headers: map[string]string{"Origin": "https://admin.example.org", "Access-Control-Request-Method": "GET"},
+			resp:    cors{Code: 204, AllowOrigin: "https://admin.example.org", AllowMethods: "GET", MaxAge: "600"},
+		},
+		{
+			name:    "preflight from another origin",
+			method:  http.MethodOptions,
+			headers: map[string]string{"Origin": "https://evil.example.net", "Access-Control-Request-Method": "PUT"},
+			resp:    cors{Code: 204},
+		},
+		{
+			name:    "preflight for a method not allowed",
+			method:  http.MethodOptions,
+			headers: map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"},
+			resp:    cors{Code: 204},
+		},
+		{
+			name:    "cross-origin request",
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://app.example.com"},
+			resp:    cors{Code: 200, AllowOrigin: "https://app.example.com", ExposeHeaders: "X-Request-Id", Body: "users"},
+		},
+		{
+			name:   "same-origin request",
+			method: http.MethodGet,
+			resp:   cors{Code: 200, Body: "users"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/users", nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			got := cors{
+				Code:          rec.Code,
+				AllowOrigin:   rec.Header().Get("Access-Control-Allow-Origin"),
+				AllowMethods:  rec.Header().Get("Access-Control-Allow-Methods"),
+				AllowHeaders:  rec.Header().Get("Access-Control-Allow-Headers"),
+				ExposeHeaders: rec.Header().Get("Access-Control-Expose-Headers"),
+				MaxAge:        rec.Header().Get("Access-Control-Max-Age"),
+				Body:          rec.Body.String(),
+			}
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("CORS() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewServer_CORS(t *testing.T) {
+	s := NewServer(config.Application{CORS: testCORS}, nil)
+	url, cancel, errc := start(t, s)
+	defer func() {
+		cancel()
+		<-errc
+	}()
+	waitReady(t, url)
+
+	req, _ := http.NewRequest(http.MethodOptions, url+"/ready", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("http.Do() error = %s", err)
+	}
+	resp.Body.Close() // nolint:errcheck
+
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); resp.StatusCode != http.StatusNoContent || got != "https://app.example.com" {
+		t.Errorf("preflight = %d with origin %q, want 204 with origin %q", resp.StatusCode, got, "https://app.example.com")
+	}
+}
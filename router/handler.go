@@ -0,0 +1,151 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/jobaldw/shared/v2/logging"
+)
+
+// An HTTPError is an error replied with a status code, for handlers to
+// return when no other error describes what went wrong.
+type HTTPError struct {
+	// the status code replied with
+	Status int
+
+	// the message replied with, the status text by default
+	Msg string
+
+	// the cause of the error, logged but never replied with
+	Err error
+}
+
+// NewHTTPError
+// creates an error replied with the status code and message.
+func NewHTTPError(status int, msg string) *HTTPError {
+	return &HTTPError{Status: status, Msg: msg}
+}
+
+// Error
+// implements the error interface.
+func (e *HTTPError) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap
+// returns the cause of the error.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// A StatusCoder chooses the status code it is replied with by handlers
+// made with Handle(), "200 OK" otherwise.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// HandlerFunc
+// is an http handler that returns its error instead of replying with it.
+// A returned error is replied with as problem details, see ToProblem(),
+// unless the handler already started its reply. The outcome of every call
+// is logged once: by AccessLog(), with the handler's function name and
+// error, or with the logger of the request's context when there is no
+// access log.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP
+// calls the handler and replies with its error, if any.
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, funcName(f), f)
+}
+
+// Handle
+// adapts a typed handler to an http handler. The request is read into a
+// Req struct with Bind(), and the Resp returned is replied with in the
+// content type the request accepts, see RespondTo(). Errors are replied
+// with and logged like a HandlerFunc's. The handler documents Req and
+// Resp in the router's OpenAPI document, see Document(). Handle() panics
+// when Req is not a struct, as no request could be bound to it.
+func Handle[Req, Resp any](h func(ctx context.Context, req Req) (Resp, error), opts ...BindOption) http.Handler {
+	name := funcName(h)
+	request := reflect.TypeOf((*Req)(nil)).Elem()
+	if request.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("%s: request type of %s must be a struct, got %s", packageKey, name, request))
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, name, func(w http.ResponseWriter, r *http.Request) error {
+			var req Req
+			if err := Bind(r, &req, opts...); err != nil {
+				return err
+			}
+
+			resp, err := h(r.Context(), req)
+			if err != nil {
+				return err
+			}
+
+			code := http.StatusOK
+			if sc, ok := any(resp).(StatusCoder); ok {
+				code = sc.StatusCode()
+			}
+			RespondTo(w, r, code, resp)
+			return nil
+		})
+	})
+
+	doc := routeDoc{
+		request:   request,
+		response:  reflect.TypeOf((*Resp)(nil)).Elem(),
+		responses: make(map[int]docResponse),
+		problems:  true,
+	}
+	return &documented{Handler: handler, doc: doc}
+}
+
+/********** helper functions **********/
+
+// serve
+// calls a handler, replies with its error and hands the outcome to the
+// access log, or logs it when there is none.
+func serve(w http.ResponseWriter, r *http.Request, name string, h HandlerFunc) {
+	sw := wrap(w)
+	err := h(sw, r)
+	if err != nil && !sw.wroteHeader {
+		RespondProblem(sw, r, err)
+	}
+
+	if out, ok := r.Context().Value(outcomeKey).(*outcome); ok {
+		out.handler, out.err = name, err
+		return
+	}
+
+	status := sw.status
+	logger := logging.FromContext(r.Context())
+	event := logger.Info()
+	switch {
+	case err != nil && status >= http.StatusInternalServerError:
+		event = logger.Error().Err(err)
+	case err != nil:
+		event = logger.Warn().Err(err)
+	}
+	event.Str(FunctionKey, name).Int(StatusKey, status).Msg("handler finished")
+}
+
+// funcName
+// returns the package qualified name of a func, e.g. "users.get".
+func funcName(fn interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
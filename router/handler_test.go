@@ -0,0 +1,185 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+
+	errs "github.com/jobaldw/shared/v2/errors"
+	"github.com/jobaldw/shared/v2/logging"
+)
+
+// a handler log entry the tests compare
+type handlerEntry struct {
+	Level    string `json:"level"`
+	Function string `json:"function"`
+	Status   int    `json:"status"`
+	Error    string `json:"error"`
+	Message  string `json:"message"`
+}
+
+func serveLogged(t *testing.T, h http.Handler, req *http.Request) (reply, handlerEntry) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	req = req.WithContext(logging.NewContext(req.Context(), zerolog.New(&buf)))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var entry handlerEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("json.Unmarshal() error = %s, log = %q", err, buf.String())
+	}
+	return recordReply(rec), entry
+}
+
+func TestHandlerFunc(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		resp  reply
+		entry handlerEntry
+	}{
+		{
+			name:  "ok",
+			resp:  reply{Code: 200, ContentType: "text/plain; charset=utf-8", Length: "5", Body: "hello"},
+			entry: handlerEntry{Level: "info", Status: 200, Message: "handler finished"},
+		},
+		{
+			name:  "http error",
+			err:   &HTTPError{Status: http.StatusForbidden, Msg: "not your user", Err: errors.New("owner is 7")},
+			resp:  reply{Code: 403, ContentType: "application/problem+json", Length: "103", Body: `{"detail":"not your user","instance":"/users/42","status":403,"title":"Forbidden","type":"about:blank"}`},
+			entry: handlerEntry{Level: "warn", Status: 403, Error: "not your user: owner is 7", Message: "handler finished"},
+		},
+		{
+			name:  "client error",
+			err:   fmt.Errorf("getting user: %w", errs.ClientErr{Client: "users", StatusCode: 404, Msg: "user not found"}),
+			resp:  reply{Code: 404, ContentType: "application/problem+json", Length: "143", Body: `{"client":"users","detail":"user not found","instance":"/users/42","status":404,"title":"Not Found","type":"about:blank","upstream_status":404}`},
+			entry: handlerEntry{Level: "warn", Status: 404, Error: "getting user: " + errs.ClientErr{Client: "users", StatusCode: 404, Msg: "user not found"}.Error(), Message: "handler finished"},
+		},
+		{
+			name:  "mongo error",
+			err:   errs.NewMongoErr(errs.Update, "users", errors.New("connection reset")),
+			resp:  reply{Code: 500, ContentType: "application/problem+json", Length: "148", Body: `{"detail":"could not update document","instance":"/users/42","operation":"update","status":500,"title":"Internal Server Error","type":"about:blank"}`},
+			entry: handlerEntry{Level: "error", Status: 500, Error: errs.NewMongoErr(errs.Update, "users", errors.New("connection reset")).Error(), Message: "handler finished"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				if test.err != nil {
+					return test.err
+				}
+				w.Header().Set("Content-Type", ContentTypeText)
+				w.Header().Set("Content-Length", "5")
+				_, err := w.Write([]byte("hello"))
+				return err
+			})
+
+			resp, entry := serveLogged(t, h, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+			if diff := cmp.Diff(test.resp, resp); diff != "" {
+				t.Errorf("HandlerFunc.ServeHTTP() mismatch (-want +got):\n%s", diff)
+			}
+			if !strings.HasPrefix(entry.Function, "router.TestHandlerFunc.") {
+				t.Errorf("HandlerFunc.ServeHTTP() logged function = %q", entry.Function)
+			}
+			entry.Function = ""
+			if diff := cmp.Diff(test.entry, entry); diff != "" {
+				t.Errorf("HandlerFunc.ServeHTTP() log mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandlerFunc_AlreadyReplied(t *testing.T) {
+	h := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return errors.New("stream closed")
+	})
+
+	resp, entry := serveLogged(t, h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if diff := cmp.Diff(reply{Code: 202}, resp); diff != "" {
+		t.Errorf("HandlerFunc.ServeHTTP() mismatch (-want +got):\n%s", diff)
+	}
+	if entry.Level != "warn" || entry.Error != "stream closed" {
+		t.Errorf("HandlerFunc.ServeHTTP() log = %+v, want a warning with the error", entry)
+	}
+}
+
+type getUser struct {
+	ID int `path:"id" validate:"min=1"`
+}
+
+type createdUser struct {
+	ID int `json:"id"`
+}
+
+func (createdUser) StatusCode() int { return http.StatusCreated }
+
+func createUser(ctx context.Context, req getUser) (createdUser, error) {
+	if req.ID == 7 {
+		return createdUser{}, NewHTTPError(http.StatusConflict, "user already exists")
+	}
+	return createdUser{ID: req.ID}, nil
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name  string
+		id    string
+		resp  reply
+		entry handlerEntry
+	}{
+		{
+			name:  "created",
+			id:    "42",
+			resp:  reply{Code: 201, ContentType: "application/json", Length: "9", Body: `{"id":42}`},
+			entry: handlerEntry{Level: "info", Function: "router.createUser", Status: 201, Message: "handler finished"},
+		},
+		{
+			name:  "does not validate",
+			id:    "-1",
+			resp:  reply{Code: 422, ContentType: "application/problem+json", Length: "223", Body: `{"detail":"the request parameters did not validate","instance":"/users/-1","invalid-params":[{"name":"id","reason":"must be at least 1"}],"status":422,"title":"Your request parameters didn't validate.","type":"about:blank"}`},
+			entry: handlerEntry{Level: "warn", Function: "router.createUser", Status: 422, Error: "request parameters did not validate: id must be at least 1", Message: "handler finished"},
+		},
+		{
+			name:  "handler error",
+			id:    "7",
+			resp:  reply{Code: 409, ContentType: "application/problem+json", Length: "107", Body: `{"detail":"user already exists","instance":"/users/7","status":409,"title":"Conflict","type":"about:blank"}`},
+			entry: handlerEntry{Level: "warn", Function: "router.createUser", Status: 409, Error: "user already exists", Message: "handler finished"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users/"+test.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": test.id})
+
+			resp, entry := serveLogged(t, Handle(createUser), req)
+			if diff := cmp.Diff(test.resp, resp); diff != "" {
+				t.Errorf("Handle() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.entry, entry); diff != "" {
+				t.Errorf("Handle() log mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandle_NotStruct(t *testing.T) {
+	defer func() {
+		if got, _ := recover().(string); !strings.HasSuffix(got, "must be a struct, got string") {
+			t.Errorf("Handle() panic = %q, want a request type must be a struct panic", got)
+		}
+	}()
+	Handle(func(ctx context.Context, req string) (string, error) { return req, nil })
+}
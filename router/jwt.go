@@ -0,0 +1,420 @@
+package router
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/jobaldw/shared/v2/client"
+)
+
+// supported JWT signing algorithms
+const (
+	HS256 = "HS256" // HMAC with SHA-256, verified with a []byte secret
+	RS256 = "RS256" // RSA PKCS #1 v1.5 with SHA-256, verified with an *rsa.PublicKey
+	ES256 = "ES256" // ECDSA P-256 with SHA-256, verified with an *ecdsa.PublicKey
+)
+
+// how long a JWKS is cached for by default, how often it may be fetched
+// again for a key id it does not have, how long to wait before trying
+// again after a failed fetch and how long a fetch may take
+const (
+	DefaultJWKSTTL    = time.Hour
+	jwksRefetchPeriod = time.Minute
+	jwksRetryPeriod   = 5 * time.Second
+	jwksFetchTimeout  = 10 * time.Second
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token") // the token is malformed or its signature does not verify
+	ErrExpiredToken = errors.New("token expired") // the token is expired or not valid yet
+	ErrUnknownKey   = errors.New("unknown key")   // no key verifies tokens with the token's key id and algorithm
+)
+
+// A KeySet returns the key that verifies tokens signed with an algorithm
+// and key id: a []byte secret for HS256, an *rsa.PublicKey for RS256 and
+// an *ecdsa.PublicKey for ES256. The key id is empty when the token has
+// none.
+type KeySet interface {
+	Key(ctx context.Context, alg, kid string) (interface{}, error)
+}
+
+// StaticKeys
+// returns a key set of keys by key id. A token without a key id is
+// verified with the only key of the set, if there is just one.
+func StaticKeys(keys map[string]interface{}) KeySet {
+	return staticKeys(keys)
+}
+
+// StaticKey
+// returns a key set that verifies every token with the key, whatever its
+// key id.
+func StaticKey(key interface{}) KeySet {
+	return staticKey{key: key}
+}
+
+// A JWKS is a key set fetched from a JSON Web Key Set endpoint
+// (https://www.rfc-editor.org/rfc/rfc7517) with a client. Keys are cached
+// and fetched again in the background once they expire, so requests keep
+// being verified with the cached keys while the endpoint is slow or down.
+// A token with a key id the set does not have fetches it at most once a
+// minute, so rotated keys are picked up. Failed fetches are tried again
+// after a few seconds. Callers that need the set fetched share a single
+// fetch. RSA and P-256 EC keys are supported.
+type JWKS struct {
+	client *client.Client
+	path   string
+	ttl    time.Duration
+	now    func() time.Time
+
+	group singleflight.Group
+	mu    sync.Mutex
+	keys  map[string]jwk
+
+	// when the keys were last fetched, when a fetch was last started and
+	// why it failed, if it did
+	fetched   time.Time
+	attempted time.Time
+	err       error
+}
+
+// NewJWKS
+// creates a key set fetched from the path of the client, e.g.
+// "/.well-known/jwks.json", and cached for the ttl, an hour when 0.
+func NewJWKS(c *client.Client, path string, ttl time.Duration) *JWKS {
+	if ttl <= 0 {
+		ttl = DefaultJWKSTTL
+	}
+	return &JWKS{client: c, path: path, ttl: ttl, now: time.Now}
+}
+
+// Key
+// returns the key with the key id. Callers wait for the key set to be
+// fetched only when none is cached yet or the token's key id is unknown;
+// an expired set is served while it is fetched again in the background.
+// A caller whose context is done stops waiting for the fetch, but does
+// not cancel it for the others.
+func (s *JWKS) Key(ctx context.Context, alg, kid string) (interface{}, error) {
+	s.mu.Lock()
+	keys, now := s.keys, s.now()
+	_, known := keys[kid]
+	age, tried := now.Sub(s.fetched), now.Sub(s.attempted)
+
+	wait := false
+	switch {
+	case keys == nil:
+		// don't fetch again for every request while the endpoint fails
+		if s.err != nil && tried < jwksRetryPeriod {
+			err := s.err
+			s.mu.Unlock()
+			return nil, err
+		}
+		wait = true
+	case !known && kid != "" && tried >= jwksRefetchPeriod:
+		s.attempted, wait = now, true
+	case age >= s.ttl && tried >= jwksRetryPeriod:
+		s.attempted = now
+		s.group.DoChan(s.path, s.refresh)
+	}
+	s.mu.Unlock()
+
+	if wait {
+		select {
+		case res := <-s.group.DoChan(s.path, s.refresh):
+			if res.Err == nil {
+				keys = res.Val.(map[string]jwk)
+			} else if keys == nil {
+				return nil, res.Err
+			}
+		case <-ctx.Done():
+			if keys == nil {
+				return nil, fmt.Errorf("%s: %w, could not fetch jwks", packageKey, ctx.Err())
+			}
+		}
+	}
+
+	var candidates []jwk
+	for id, k := range keys {
+		if (id == kid || kid == "") && (k.alg == "" || k.alg == alg) {
+			candidates = append(candidates, k)
+		}
+	}
+	if len(candidates) != 1 {
+		return nil, ErrUnknownKey
+	}
+	return candidates[0].key, nil
+}
+
+/********** helper functions **********/
+
+type staticKeys map[string]interface{}
+
+func (s staticKeys) Key(_ context.Context, _, kid string) (interface{}, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(s) == 1 {
+		for _, key := range s {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+type staticKey struct {
+	key interface{}
+}
+
+func (s staticKey) Key(context.Context, string, string) (interface{}, error) {
+	return s.key, nil
+}
+
+// A key of a JWKS and the algorithm it is for, if the set says.
+type jwk struct {
+	alg string
+	key interface{}
+}
+
+// refresh
+// fetches the key set and caches it, or records why it could not be
+// fetched. The fetch has a timeout of its own rather than the context of
+// the caller that started it, as other callers may be waiting on it too.
+func (s *JWKS) refresh() (interface{}, error) {
+	s.mu.Lock()
+	s.attempted = s.now()
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+
+	keys, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	if err != nil {
+		return nil, err
+	}
+	s.keys, s.fetched = keys, s.now()
+	return keys, nil
+}
+
+// fetch
+// returns the keys the endpoint serves. Keys that are not for signatures
+// or cannot be parsed are skipped.
+func (s *JWKS) fetch(ctx context.Context) (map[string]jwk, error) {
+	resp, err := s.client.GetWithContext(ctx, s.path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w, could not fetch jwks", packageKey, err)
+	}
+	defer resp.Close() // nolint:errcheck
+
+	if !resp.IsSuccessful() {
+		return nil, fmt.Errorf("%s: could not fetch jwks, got %s", packageKey, resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.GetBody()).Decode(&set); err != nil {
+		return nil, fmt.Errorf("%s: %w, could not decode jwks", packageKey, err)
+	}
+
+	keys := make(map[string]jwk, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key interface{}
+		switch k.Kty {
+		case "RSA":
+			key, err = rsaKey(k.N, k.E)
+		case "EC":
+			key, err = ecKey(k.Crv, k.X, k.Y)
+		default:
+			continue
+		}
+		if err == nil {
+			keys[k.Kid] = jwk{alg: k.Alg, key: key}
+		}
+	}
+
+	return keys, nil
+}
+
+// rsaKey
+// builds an RSA public key from its base64url encoded modulus and
+// exponent.
+func rsaKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(eb)
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid rsa exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}, nil
+}
+
+// ecKey
+// builds a P-256 public key from its base64url encoded coordinates.
+func ecKey(crv, x, y string) (*ecdsa.PublicKey, error) {
+	if crv != "P-256" {
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, err
+	}
+	yb, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(xb), Y: new(big.Int).SetBytes(yb)}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return key, nil
+}
+
+// A JWT verifier and the claims it requires.
+type jwtVerifier struct {
+	keys       KeySet
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	requireExp bool
+	now        func() time.Time
+}
+
+// verify
+// checks a compact serialized token's signature and time claims, and the
+// expiry, issuer and audience when they are required, returning its
+// claims. Time claims that are not numbers make the token invalid.
+func (v *jwtVerifier) verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := v.keys.Key(ctx, header.Alg, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if !verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil || claims == nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := v.now()
+	exp, hasExp, err := claims.unixTime("exp")
+	if err != nil || (v.requireExp && !hasExp) {
+		return nil, ErrInvalidToken
+	}
+	if hasExp && !now.Before(exp.Add(v.leeway)) {
+		return nil, ErrExpiredToken
+	}
+	nbf, hasNbf, err := claims.unixTime("nbf")
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if hasNbf && now.Add(v.leeway).Before(nbf) {
+		return nil, ErrExpiredToken
+	}
+	if v.issuer != "" && claims.Issuer() != v.issuer {
+		return nil, ErrInvalidToken
+	}
+	if v.audience != "" && !contains(claims.Audience(), v.audience) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// decodeSegment
+// decodes a base64url encoded JSON segment of a token.
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// verifySignature
+// reports whether the signature of the signing input verifies with the
+// key. The key's type has to match the algorithm, so a public key can
+// never be used as an HMAC secret.
+func verifySignature(alg string, key interface{}, input string, sig []byte) bool {
+	switch alg {
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok || len(secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(input)) // nolint:errcheck
+		return hmac.Equal(mac.Sum(nil), sig)
+	case RS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		digest := sha256.Sum256([]byte(input))
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+	case ES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() || len(sig) != 64 {
+			return false
+		}
+		digest := sha256.Sum256([]byte(input))
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(pub, digest[:], r, s)
+	}
+	return false
+}
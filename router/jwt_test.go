@@ -0,0 +1,433 @@
+package router
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/client"
+	"github.com/jobaldw/shared/v2/config"
+)
+
+var (
+	testSecret = []byte("a secret of at least 256 bits!!!")
+	testRSA, _ = rsa.GenerateKey(rand.Reader, 2048)
+	testEC, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testNow    = time.Unix(1666094400, 0) // 2022-10-18T12:00:00Z
+)
+
+// sign
+// creates a compact serialized token signed with the algorithm.
+func sign(t *testing.T, alg, kid string, claims Claims) string {
+	t.Helper()
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch alg {
+	case HS256:
+		mac := hmac.New(sha256.New, testSecret)
+		mac.Write([]byte(input)) // nolint:errcheck
+		sig = mac.Sum(nil)
+	case RS256:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, testRSA, crypto.SHA256, digest[:]); err != nil {
+			t.Fatalf("rsa.SignPKCS1v15() error = %s", err)
+		}
+	case ES256:
+		r, s, err := ecdsa.Sign(rand.Reader, testEC, digest[:])
+		if err != nil {
+			t.Fatalf("ecdsa.Sign() error = %s", err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func Test_jwtVerifier_verify(t *testing.T) {
+	keys := StaticKeys(map[string]interface{}{"hs": testSecret, "rs": &testRSA.PublicKey, "es": &testEC.PublicKey})
+	valid := Claims{"sub": "ada", "iss": "auth", "aud": []string{"users", "orders"}, "exp": testNow.Add(time.Minute).Unix()}
+
+	tests := []struct {
+		name  string
+		token string
+		opts  []JWTOption
+		want  Claims
+		err   error
+	}{
+		{
+			name:  "hs256",
+			token: sign(t, HS256, "hs", valid),
+			opts:  []JWTOption{WithIssuer("auth"), WithAudience("users")},
+			want:  Claims{"sub": "ada", "iss": "auth", "aud": []interface{}{"users", "orders"}, "exp": float64(testNow.Add(time.Minute).Unix())},
+		},
+		{
+			name:  "rs256",
+			token: sign(t, RS256, "rs", Claims{"sub": "ada"}),
+			want:  Claims{"sub": "ada"},
+		},
+		{
+			name:  "es256",
+			token: sign(t, ES256, "es", Claims{"sub": "ada"}),
+			want:  Claims{"sub": "ada"},
+		},
+		{
+			name:  "expired",
+			token: sign(t, HS256, "hs", Claims{"exp": testNow.Add(-time.Second).Unix()}),
+			err:   ErrExpiredToken,
+		},
+		{
+			name:  "expired within leeway",
+			token: sign(t, HS256, "hs", Claims{"exp": testNow.Add(-time.Second).Unix()}),
+			opts:  []JWTOption{WithLeeway(5 * time.Second)},
+			want:  Claims{"exp": float64(testNow.Add(-time.Second).Unix())},
+		},
+		{
+			name:  "expires after 2262",
+			token: sign(t, HS256, "hs", Claims{"exp": 1e10}),
+			want:  Claims{"exp": 1e10},
+		},
+		{
+			name:  "fractional expiry",
+			token: sign(t, HS256, "hs", Claims{"exp": float64(testNow.Unix()) + 0.5}),
+			want:  Claims{"exp": float64(testNow.Unix()) + 0.5},
+		},
+		{
+			name:  "not valid yet",
+			token: sign(t, HS256, "hs", Claims{"nbf": testNow.Add(time.Minute).Unix()}),
+			err:   ErrExpiredToken,
+		},
+		{
+			name:  "exp is not a number",
+			token: sign(t, HS256, "hs", Claims{"exp": "9999999999"}),
+			err:   ErrInvalidToken,
+		},
+		{
+			name:  "nbf is not a number",
+			token: sign(t, HS256, "hs", Claims{"exp": testNow.Add(time.Minute).Unix(), "nbf": "0"}),
+			err:   ErrInvalidToken,
+		},
+		{
+			name:  "required expiry",
+			token: sign(t, HS256, "hs", valid),
+			opts:  []JWTOption{WithRequiredExpiry()},
+			want:  Claims{"sub": "ada", "iss": "auth", "aud": []interface{}{"users", "orders"}, "exp": float64(testNow.Add(time.Minute).Unix())},
+		},
+		{
+			name:  "required expiry missing",
+			token: sign(t, HS256, "hs", Claims{"sub": "ada"}),
+			opts:  []JWTOption{WithRequiredExpiry()},
+			err:   ErrInvalidToken,
+		},
+		{
+			name:  "wrong issuer",
+			token: sign(t, HS256, "hs", valid),
+			opts:  []JWTOption{WithIssuer("someone else")},
+			err:   ErrInvalidToken,
+		},
+		{
+			name:  "wrong audience",
+			token: sign(t, HS256, "hs", valid),
+			opts:  []JWTOption{WithAudience("payments")},
+			err:   ErrInvalidToken,
+		},
+		{
+			name:  "unknown key",
+			token: sign(t, HS256, "other", valid),
+			err:   ErrUnknownKey,
+		},
+		{
+			name:  "algorithm does not match the key",
+			token: sign(t, HS256, "rs", valid),
+			err:   ErrInvalidToken,
+		},
+		{
+			name:  "none",
+			token: sign(t, "none", "hs", valid),
+			err:   ErrInvalidToken,
+		},
+		{
+			name:  "tampered",
+			token: sign(t, HS256, "hs", valid)[:20] + "x" + sign(t, HS256, "hs", valid)[21:],
+			err:   ErrInvalidToken,
+		},
+		{
+			name:  "malformed",
+			token: "not.a-token",
+			err:   ErrInvalidToken,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := &jwtVerifier{keys: keys, now: func() time.Time { return testNow }}
+			for _, opt := range test.opts {
+				opt(v)
+			}
+
+			got, err := v.verify(context.Background(), test.token)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("verify() error = %v, want %v", err, test.err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("verify() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// settle
+// waits for the key set's background fetch, if any, to finish.
+func settle(s *JWKS) {
+	<-s.group.DoChan(s.path, func() (interface{}, error) { return nil, nil })
+}
+
+func TestJWKS(t *testing.T) {
+	var fetches int32
+	var status int32 = http.StatusOK
+	kids := []string{"rs-1", "es-1"}
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if code := int(atomic.LoadInt32(&status)); code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+		enc := base64.RawURLEncoding
+		fmt.Fprintf(w, `{"keys":[
+			{"kty":"RSA","kid":%q,"alg":"RS256","use":"sig","n":%q,"e":"AQAB"},
+			{"kty":"EC","kid":%q,"crv":"P-256","x":%q,"y":%q},
+			{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"}
+		]}`, kids[0], enc.EncodeToString(testRSA.N.Bytes()), kids[1], enc.EncodeToString(testEC.X.Bytes()), enc.EncodeToString(testEC.Y.Bytes()))
+	}))
+	defer svr.Close()
+
+	c, err := client.New(config.Client{URL: svr.URL}, client.WithMetrics(nil), client.WithTracerProvider(nil))
+	if err != nil {
+		t.Fatalf("client.New() error = %s", err)
+	}
+	now := testNow
+	jwks := NewJWKS(c, "/.well-known/jwks.json", time.Hour)
+	jwks.now = func() time.Time { return now }
+	v := &jwtVerifier{keys: jwks, now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		status  int
+		token   string
+		err     error
+		fetches int32
+	}{
+		{name: "rsa", token: sign(t, RS256, "rs-1", Claims{"sub": "ada"}), fetches: 1},
+		{name: "ec from the cache", token: sign(t, ES256, "es-1", Claims{"sub": "ada"}), fetches: 1},
+		{name: "encryption keys are skipped", token: sign(t, RS256, "enc", Claims{"sub": "ada"}), err: ErrUnknownKey, fetches: 1},
+		{name: "unknown keys refetch at most once a minute", advance: time.Minute, token: sign(t, RS256, "rs-2", Claims{}), err: ErrUnknownKey, fetches: 2},
+		{name: "unknown keys do not refetch within a minute", advance: 30 * time.Second, token: sign(t, RS256, "rs-3", Claims{}), err: ErrUnknownKey, fetches: 2},
+		{name: "refetched after the ttl", advance: time.Hour, token: sign(t, RS256, "rs-1", Claims{}), fetches: 3},
+		{name: "cached keys survive failures", advance: time.Hour, status: http.StatusServiceUnavailable, token: sign(t, ES256, "es-1", Claims{}), fetches: 4},
+		{name: "failures are not retried right away", status: http.StatusServiceUnavailable, token: sign(t, ES256, "es-1", Claims{}), fetches: 4},
+		{name: "failures are retried", advance: jwksRetryPeriod, token: sign(t, ES256, "es-1", Claims{}), fetches: 5},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		if step.status == 0 {
+			step.status = http.StatusOK
+		}
+		atomic.StoreInt32(&status, int32(step.status))
+
+		if _, err := v.verify(ctx, step.token); !errors.Is(err, step.err) {
+			t.Errorf("%s: verify() error = %v, want %v", step.name, err, step.err)
+		}
+		settle(jwks)
+		if got := atomic.LoadInt32(&fetches); got != step.fetches {
+			t.Errorf("%s: fetches = %d, want %d", step.name, got, step.fetches)
+		}
+	}
+}
+
+func TestJWKS_SharedFetch(t *testing.T) {
+	var fetches int32
+	started, release := make(chan struct{}), make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			close(started)
+		}
+		<-release
+		fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":"rs-1","n":%q,"e":"AQAB"}]}`, base64.RawURLEncoding.EncodeToString(testRSA.N.Bytes()))
+	}))
+	defer svr.Close()
+
+	c, err := client.New(config.Client{URL: svr.URL}, client.WithMetrics(nil), client.WithTracerProvider(nil))
+	if err != nil {
+		t.Fatalf("client.New() error = %s", err)
+	}
+	jwks := NewJWKS(c, "/jwks", 0)
+
+	// the caller that starts the fetch gives up while it is in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key(ctx, RS256, "rs-1")
+		canceled <- err
+	}()
+	<-started
+	cancel()
+	select {
+	case err := <-canceled:
+		if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+			t.Errorf("JWKS.Key() error = %v, want a canceled error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("JWKS.Key() kept waiting for the fetch after its context was canceled")
+	}
+
+	errs := make(chan error, 5)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := jwks.Key(context.Background(), RS256, "rs-1")
+			errs <- err
+		}()
+	}
+	close(release)
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("JWKS.Key() error = %s", err)
+		}
+	}
+	if got := atomic.LoadInt32(&fetches); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+}
+
+func TestJWKS_Unavailable(t *testing.T) {
+	svr := httptest.NewServer(http.NotFoundHandler())
+	defer svr.Close()
+
+	c, err := client.New(config.Client{URL: svr.URL}, client.WithMetrics(nil), client.WithTracerProvider(nil))
+	if err != nil {
+		t.Fatalf("client.New() error = %s", err)
+	}
+	if _, err := NewJWKS(c, "/jwks", 0).Key(context.Background(), RS256, "rs-1"); err == nil {
+		t.Errorf("JWKS.Key() error = nil, want a fetch error")
+	}
+}
+
+func TestJWKS_HangingEndpoint(t *testing.T) {
+	var fetches int32
+	hung, hang := make(chan struct{}, 1), make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			select {
+			case hung <- struct{}{}:
+			default:
+			}
+			<-hang
+		}
+		fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":"rs-1","n":%q,"e":"AQAB"}]}`, base64.RawURLEncoding.EncodeToString(testRSA.N.Bytes()))
+	}))
+	defer svr.Close()
+	defer close(hang)
+
+	c, err := client.New(config.Client{URL: svr.URL}, client.WithMetrics(nil), client.WithTracerProvider(nil))
+	if err != nil {
+		t.Fatalf("client.New() error = %s", err)
+	}
+	var now atomic.Value
+	now.Store(testNow)
+	jwks := NewJWKS(c, "/jwks", time.Hour)
+	jwks.now = func() time.Time { return now.Load().(time.Time) }
+
+	if _, err := jwks.Key(context.Background(), RS256, "rs-1"); err != nil {
+		t.Fatalf("JWKS.Key() error = %s", err)
+	}
+
+	// once the keys expire requests are verified with them right away
+	// while the endpoint hangs
+	now.Store(testNow.Add(2 * time.Hour))
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		if _, err := jwks.Key(context.Background(), RS256, "rs-1"); err != nil {
+			t.Errorf("JWKS.Key() error = %s with expired keys", err)
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("JWKS.Key() took %s with expired keys, want them served right away", elapsed)
+		}
+	}
+
+	// unknown key ids do not wait on the hanging fetch either
+	start := time.Now()
+	if _, err := jwks.Key(context.Background(), RS256, "rs-2"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("JWKS.Key() error = %v, want %v", err, ErrUnknownKey)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("JWKS.Key() took %s for an unknown key id, want no new fetch", elapsed)
+	}
+
+	select {
+	case <-hung:
+	case <-time.After(time.Second):
+		t.Fatal("JWKS.Key() did not fetch the expired keys in the background")
+	}
+	if got := atomic.LoadInt32(&fetches); got != 2 {
+		t.Errorf("fetches = %d, want 2", got)
+	}
+}
+
+func TestJWKS_UnknownKeys(t *testing.T) {
+	var fetches int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":"rs-1","n":%q,"e":"AQAB"}]}`, base64.RawURLEncoding.EncodeToString(testRSA.N.Bytes()))
+	}))
+	defer svr.Close()
+
+	c, err := client.New(config.Client{URL: svr.URL}, client.WithMetrics(nil), client.WithTracerProvider(nil))
+	if err != nil {
+		t.Fatalf("client.New() error = %s", err)
+	}
+	now := testNow
+	jwks := NewJWKS(c, "/jwks", time.Hour)
+	jwks.now = func() time.Time { return now }
+
+	if _, err := jwks.Key(context.Background(), RS256, "rs-1"); err != nil {
+		t.Fatalf("JWKS.Key() error = %s", err)
+	}
+
+	// a flood of made up key ids fetches the set once a minute at most
+	now = now.Add(jwksRefetchPeriod)
+	for i := 0; i < 20; i++ {
+		now = now.Add(time.Second)
+		if _, err := jwks.Key(context.Background(), RS256, fmt.Sprintf("made-up-%d", i)); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("JWKS.Key() error = %v, want %v", err, ErrUnknownKey)
+		}
+	}
+	if got := atomic.LoadInt32(&fetches); got != 2 {
+		t.Errorf("fetches = %d, want 2", got)
+	}
+}
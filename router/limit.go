@@ -0,0 +1,192 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// how often idle buckets are dropped by RateLimit()
+const bucketSweepPeriod = time.Minute
+
+var (
+	ErrTooManyRequests = errors.New("too many requests")    // the request's key is out of tokens
+	ErrOverloaded      = errors.New("server is overloaded") // too many requests are in flight
+)
+
+// A KeyFunc returns the key a request is rate limited by, e.g. its IP
+// address or API key. Requests with the same key share a token bucket.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP
+// keys requests by the IP address they were made from. Behind a proxy,
+// that is the proxy's address unless a middleware sets the request's
+// RemoteAddr from the proxy's headers first.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByHeader
+// keys requests by a header, e.g. KeyByHeader(router.APIKeyHeader).
+// Requests without the header share one bucket.
+func KeyByHeader(header string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// RateLimit
+// is middleware that limits how fast requests can be made per key, by IP
+// address when the key func is nil. Every key gets a token bucket that
+// holds up to burst requests, at least 1, and refills at perSecond
+// requests a second. Requests made once a key's bucket is empty are
+// replied to with a "429 Too Many Requests" error and a Retry-After
+// header through RespondError(). The built-in live, ready and metrics
+// routes are never limited, and a rate of 0 limits nothing.
+func RateLimit(perSecond float64, burst int, key KeyFunc) mux.MiddlewareFunc {
+	if perSecond <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	if key == nil {
+		key = KeyByIP
+	}
+	l := &rateLimiter{
+		rate:    perSecond,
+		burst:   math.Max(float64(burst), 1),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if builtIn(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if wait, ok := l.take(key(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				RespondError(w, json.Marshal, http.StatusTooManyRequests, ErrTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LoadShed
+// is middleware that sheds load once more than maxInFlight requests are
+// being served at once. Requests over the limit are replied to with a
+// "503 Service Unavailable" error and a Retry-After header of a second
+// through RespondError(), so load balancers and clients back off. The
+// built-in live, ready and metrics routes are never shed, and a limit of 0
+// sheds nothing.
+func LoadShed(maxInFlight int) mux.MiddlewareFunc {
+	if maxInFlight <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	var inFlight int64
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if builtIn(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			defer atomic.AddInt64(&inFlight, -1)
+			if atomic.AddInt64(&inFlight, 1) > int64(maxInFlight) {
+				w.Header().Set("Retry-After", "1")
+				RespondError(w, json.Marshal, http.StatusServiceUnavailable, ErrOverloaded)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+/********** helper functions **********/
+
+// builtIn
+// reports whether the request matched one of the built-in routes.
+func builtIn(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	switch route.GetName() {
+	case LiveRoute, ReadyRoute, MetricsRoute:
+		return true
+	}
+	return false
+}
+
+// Token buckets by key.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+// A key's tokens as of the last time it took one.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take
+// takes a token from the key's bucket, or returns how long until the
+// bucket has one again.
+func (l *rateLimiter) take(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// sweep
+// drops the buckets that have refilled since they were last used, they
+// are no different from new ones.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketSweepPeriod {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+func Test_rateLimiter_take(t *testing.T) {
+	now := testNow
+	l := &rateLimiter{rate: 2, burst: 2, buckets: make(map[string]*bucket), now: func() time.Time { return now }}
+
+	type take struct {
+		Wait time.Duration
+		OK   bool
+	}
+	steps := []struct {
+		name    string
+		advance time.Duration
+		key     string
+		want    take
+	}{
+		{name: "burst", key: "a", want: take{OK: true}},
+		{name: "burst again", key: "a", want: take{OK: true}},
+		{name: "empty", key: "a", want: take{Wait: 500 * time.Millisecond}},
+		{name: "other key", key: "b", want: take{OK: true}},
+		{name: "refilling", advance: 250 * time.Millisecond, key: "a", want: take{Wait: 250 * time.Millisecond}},
+		{name: "refilled", advance: 250 * time.Millisecond, key: "a", want: take{OK: true}},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		wait, ok := l.take(step.key)
+		if diff := cmp.Diff(step.want, take{Wait: wait, OK: ok}); diff != "" {
+			t.Errorf("%s: take() mismatch (-want +got):\n%s", step.name, diff)
+		}
+	}
+
+	// buckets that refilled are dropped
+	now = now.Add(bucketSweepPeriod)
+	l.take("c")
+	if diff := cmp.Diff([]string{"c"}, keys(l.buckets)); diff != "" {
+		t.Errorf("sweep() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	r := newRouter(newRegistry(nil, config.Readiness{}), nil)
+	r.Use(RateLimit(0.5, 1, KeyByHeader(APIKeyHeader)))
+	r.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello") // nolint:errcheck
+	})
+
+	type limited struct {
+		Code       int
+		RetryAfter string
+		Body       string
+	}
+	tests := []struct {
+		name string
+		path string
+		key  string
+		resp limited
+	}{
+		{name: "first", path: "/hello", key: "key-1", resp: limited{Code: 200, Body: "hello"}},
+		{name: "limited", path: "/hello", key: "key-1", resp: limited{Code: 429, RetryAfter: "2", Body: `{"error":"too many requests"}`}},
+		{name: "other key", path: "/hello", key: "key-2", resp: limited{Code: 200, Body: "hello"}},
+		{name: "ready is never limited", path: "/ready", key: "key-1", resp: limited{Code: 200, Body: `{"status":"UP"}`}},
+		{name: "health is never limited", path: "/health", key: "key-1", resp: limited{Code: 200}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			req.Header.Set(APIKeyHeader, test.key)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			got := limited{Code: rec.Code, RetryAfter: rec.Header().Get("Retry-After"), Body: rec.Body.String()}
+			if test.path == "/health" {
+				got.Body = ""
+			}
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("RateLimit() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadShed(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	r := newRouter(newRegistry(nil, config.Readiness{}), nil)
+	r.Use(LoadShed(1))
+	r.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	r.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-started
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := serve("/fast")
+	want := reply{Code: 503, ContentType: "application/json", Length: "32", Body: `{"error":"server is overloaded"}`}
+	if diff := cmp.Diff(want, recordReply(rec)); diff != "" || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("LoadShed() mismatch (-want +got):\n%s", diff)
+	}
+	if rec := serve("/ready"); rec.Code != http.StatusOK {
+		t.Errorf("LoadShed() shed the ready route, code = %d", rec.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	if rec := serve("/fast"); rec.Code != http.StatusOK {
+		t.Errorf("LoadShed() code = %d once the load is gone, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestLoadShed_NoLimit(t *testing.T) {
+	for _, maxInFlight := range []int{0, -1} {
+		r := newRouter(newRegistry(nil, config.Readiness{}), nil)
+		r.Use(LoadShed(maxInFlight))
+		r.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {})
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hello", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("LoadShed(%d) code = %d, want %d", maxInFlight, rec.Code, http.StatusOK)
+		}
+	}
+}
+
+// keys
+// returns the keys of the buckets.
+func keys(buckets map[string]*bucket) []string {
+	var k []string
+	for key := range buckets {
+		k = append(k, key)
+	}
+	return k
+}
@@ -0,0 +1,74 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/jobaldw/shared/v2/metrics"
+)
+
+// the path the metrics endpoint is served on
+const MetricsPath = "/metrics"
+
+// server metric names
+const (
+	requestsMetric = "http_server_requests_total"
+	durationMetric = "http_server_request_duration_seconds"
+	inFlightMetric = "http_server_requests_in_flight"
+)
+
+// Metrics
+// is middleware that records every request on the registry: a counter by
+// method, route and status, a latency histogram by method and route, and
+// a gauge of the requests in flight. The route is the path template
+// (e.g. "/users/{id}"), which keeps the number of series bounded. An
+// error is returned when the registry already has different metrics with
+// the same names.
+func Metrics(reg *metrics.Registry) (mux.MiddlewareFunc, error) {
+	requests, err := reg.Counter(requestsMetric, "Number of HTTP requests served.", "method", "route", "status")
+	if err != nil {
+		return nil, err
+	}
+	duration, err := reg.Histogram(durationMetric, "Latency of HTTP requests served, in seconds.", metrics.DefBuckets, "method", "route")
+	if err != nil {
+		return nil, err
+	}
+	inFlightVec, err := reg.Gauge(inFlightMetric, "Number of HTTP requests being served.")
+	if err != nil {
+		return nil, err
+	}
+	inFlight := inFlightVec.With()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
+
+			sw := wrap(w)
+			defer func() {
+				// a panic is recorded as a server error before it is passed on
+				status := sw.status
+				v := recover()
+				if v != nil && !sw.wroteHeader {
+					status = http.StatusInternalServerError
+				}
+
+				requests.With(r.Method, route, strconv.Itoa(status)).Inc()
+				duration.With(r.Method, route).Observe(time.Since(start).Seconds())
+				if v != nil {
+					panic(v)
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}, nil
+}
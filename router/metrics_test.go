@@ -0,0 +1,81 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+
+	"github.com/jobaldw/shared/v2/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	m, err := Metrics(reg)
+	if err != nil {
+		t.Fatalf("Metrics() error = %s", err)
+	}
+
+	r := mux.NewRouter()
+	r.Use(m)
+	r.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if Vars(r)["id"] == "0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, "user") // nolint:errcheck
+	})
+	r.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map")
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/0", "/panic"} {
+		func() {
+			defer func() { recover() }() // nolint:errcheck
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}()
+	}
+
+	var out strings.Builder
+	reg.Write(&out) // nolint:errcheck
+
+	var got []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, "http_server_requests_total") ||
+			strings.HasPrefix(line, "http_server_request_duration_seconds_count") ||
+			strings.HasPrefix(line, "http_server_requests_in_flight") {
+			got = append(got, line)
+		}
+	}
+
+	want := []string{
+		`http_server_request_duration_seconds_count{method="GET",route="/panic"} 1`,
+		`http_server_request_duration_seconds_count{method="GET",route="/users/{id}"} 3`,
+		`http_server_requests_in_flight 0`,
+		`http_server_requests_total{method="GET",route="/panic",status="500"} 1`,
+		`http_server_requests_total{method="GET",route="/users/{id}",status="200"} 2`,
+		`http_server_requests_total{method="GET",route="/users/{id}",status="404"} 1`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Metrics() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNew_Metrics(t *testing.T) {
+	_, r := New(8080, nil)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, MetricsPath, nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET %s code = %d, want %d", MetricsPath, rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("GET %s Content-Type = %q, want the Prometheus text format", MetricsPath, got)
+	}
+}
@@ -0,0 +1,251 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jobaldw/shared/v2/logging"
+	"github.com/jobaldw/shared/v2/metrics"
+)
+
+// the header a request id is read from and written to
+const RequestIDHeader = "X-Request-ID"
+
+// access logging keys
+const (
+	RequestIDKey = logging.RequestIDKey
+	TraceIDKey   = logging.TraceIDKey
+	RouteKey     = logging.RouteKey
+	MethodKey    = logging.MethodKey
+	PathKey      = logging.PathKey
+	StatusKey    = logging.StatusKey
+	LatencyKey   = logging.LatencyKey
+	BytesKey     = logging.BytesKey
+	StackKey     = logging.StackKey
+)
+
+// a handler panicked and the request could not be served
+var ErrInternal = errors.New("internal server error")
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	claimsKey
+	outcomeKey
+)
+
+// UseStandard
+// adds the standard middleware stack to a router: request ids, tracing
+// with the global tracer provider, metrics on the default registry, access
+// logging and panic recovery, in that order. Middleware only runs for
+// requests that match a route. Requests are served without metrics, and
+// the error is logged, when the default registry already has different
+// metrics with the same names.
+func UseStandard(r *mux.Router, log *zerolog.Logger) {
+	r.Use(RequestID, Tracing(nil))
+	if m, err := Metrics(metrics.Default); err != nil {
+		log.Error().Err(err).Msg("requests served without metrics")
+	} else {
+		r.Use(m)
+	}
+	r.Use(AccessLog(log), Recover(log))
+}
+
+// RequestID
+// is middleware that gives every request an id. The id is read from the
+// X-Request-ID header, or generated when there is none, and is written to
+// the response's X-Request-ID header. Handlers can read it with
+// GetRequestID().
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// GetRequestID
+// returns the id of the request the context belongs to, if any.
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// AccessLog
+// is middleware that logs every request once it is served with its
+// method, path, status, latency and number of bytes written, and the
+// function name and error of handlers made with HandlerFunc or Handle().
+// A logger with the request id, trace id and route is added to the
+// request's context and can be retrieved with logging.FromContext().
+func AccessLog(log *zerolog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			fields := log.With().Str(RequestIDKey, GetRequestID(r.Context()))
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				fields = fields.Str(TraceIDKey, sc.TraceID().String())
+			}
+			if route := mux.CurrentRoute(r); route != nil {
+				if tmpl, err := route.GetPathTemplate(); err == nil {
+					fields = fields.Str(RouteKey, tmpl)
+				}
+			}
+			logger := fields.Logger()
+
+			out := &outcome{}
+			ctx := context.WithValue(logging.NewContext(r.Context(), logger), outcomeKey, out)
+			sw := wrap(w)
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			event := logger.Info()
+			switch {
+			case sw.status >= http.StatusInternalServerError:
+				event = logger.Error()
+			case sw.status >= http.StatusBadRequest || out.err != nil:
+				event = logger.Warn()
+			}
+			if out.handler != "" {
+				event = event.Str(FunctionKey, out.handler)
+			}
+			if out.err != nil {
+				event = event.Err(out.err)
+			}
+			event.
+				Str(MethodKey, r.Method).
+				Str(PathKey, r.URL.Path).
+				Int(StatusKey, sw.status).
+				Dur(LatencyKey, time.Since(start)).
+				Int64(BytesKey, sw.bytes).
+				Msg("request served")
+		})
+	}
+}
+
+// Recover
+// is middleware that recovers from panicking handlers. The panic is
+// logged with its stack and a "500 Internal Server Error" is responded
+// with, unless the handler had already started its response.
+func Recover(log *zerolog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := wrap(w)
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// the standard way for a handler to abort a response
+				if v == http.ErrAbortHandler { // nolint:errorlint
+					panic(v)
+				}
+
+				log.Error().
+					Str(RequestIDKey, GetRequestID(r.Context())).
+					Str(MethodKey, r.Method).
+					Str(PathKey, r.URL.Path).
+					Str(StackKey, string(debug.Stack())).
+					Msgf("handler panicked: %v", v)
+
+				if !sw.wroteHeader {
+					RespondError(sw, json.Marshal, http.StatusInternalServerError, ErrInternal)
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+/********** helper functions **********/
+
+// newRequestID
+// generates a random 128 bit request id.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// The function name and returned error of a handler made with HandlerFunc
+// or Handle(), reported by AccessLog().
+type outcome struct {
+	handler string
+	err     error
+}
+
+// A response writer that records the status and number of bytes written.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// wrap
+// wraps a response writer in a status writer, reusing it when it already
+// is one.
+func wrap(w http.ResponseWriter) *statusWriter {
+	if sw, ok := w.(*statusWriter); ok {
+		return sw
+	}
+	return &statusWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush
+// implements the http.Flusher interface when the wrapped writer does.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Hijack
+// implements the http.Hijacker interface when the wrapped writer does.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: response writer cannot be hijacked", packageKey)
+	}
+	return hj.Hijack()
+}
+
+// Unwrap
+// returns the wrapped response writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
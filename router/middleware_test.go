@@ -0,0 +1,137 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestUseStandard(t *testing.T) {
+	// requests without a traceparent header only get a trace id once the
+	// application sets a tracer provider
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	var buf bytes.Buffer
+	log := zerolog.New(&buf)
+
+	r := mux.NewRouter()
+	UseStandard(r, &log)
+	r.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		zerolog.Ctx(r.Context()).Info().Msg("saying hello")
+		io.WriteString(w, "hello "+GetRequestID(r.Context())) // nolint:errcheck
+	})
+	r.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map")
+	})
+	r.Handle("/conflict", HandlerFunc(conflict))
+
+	type entry struct {
+		Level     string `json:"level"`
+		RequestID string `json:"request_id"`
+		Route     string `json:"route"`
+		Method    string `json:"method"`
+		Path      string `json:"path"`
+		Status    int    `json:"status"`
+		Bytes     int64  `json:"bytes"`
+		Function  string `json:"function"`
+		Error     string `json:"error"`
+		Message   string `json:"message"`
+		HasStack  bool
+		HasTrace  bool
+	}
+	type resp struct {
+		Code      int
+		Body      string
+		RequestID string
+		Log       []entry
+	}
+	tests := []struct {
+		name      string
+		path      string
+		requestID string
+		resp      resp
+	}{
+		{
+			name:      "request id passed on",
+			path:      "/hello",
+			requestID: "abc-123",
+			resp: resp{Code: 200, Body: "hello abc-123", RequestID: "abc-123", Log: []entry{
+				{Level: "info", RequestID: "abc-123", Route: "/hello", Message: "saying hello", HasTrace: true},
+				{Level: "info", RequestID: "abc-123", Route: "/hello", Method: "GET", Path: "/hello", Status: 200, Bytes: 13, Message: "request served", HasTrace: true},
+			}},
+		},
+		{
+			name:      "panic recovered",
+			path:      "/panic",
+			requestID: "def-456",
+			resp: resp{Code: 500, Body: `{"error":"internal server error"}`, RequestID: "def-456", Log: []entry{
+				{Level: "error", RequestID: "def-456", Method: "GET", Path: "/panic", Message: "handler panicked: nil map", HasStack: true},
+				{Level: "error", RequestID: "def-456", Route: "/panic", Method: "GET", Path: "/panic", Status: 500, Bytes: 33, Message: "request served", HasTrace: true},
+			}},
+		},
+		{
+			name:      "handler error logged once",
+			path:      "/conflict",
+			requestID: "ghi-789",
+			resp: resp{Code: 409, Body: `{"detail":"user already exists","instance":"/conflict","status":409,"title":"Conflict","type":"about:blank"}`, RequestID: "ghi-789", Log: []entry{
+				{Level: "warn", RequestID: "ghi-789", Route: "/conflict", Method: "GET", Path: "/conflict", Status: 409, Bytes: 108, Function: "router.conflict", Error: "user already exists", Message: "request served", HasTrace: true},
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			req.Header.Set(RequestIDHeader, test.requestID)
+			rec := httptest.NewRecorder()
+
+			r.ServeHTTP(rec, req)
+
+			got := resp{Code: rec.Code, Body: rec.Body.String(), RequestID: rec.Header().Get(RequestIDHeader)}
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var e entry
+				if err := json.Unmarshal([]byte(line), &e); err != nil {
+					t.Fatalf("json.Unmarshal() error = %s, log line %q", err, line)
+				}
+				e.HasStack = strings.Contains(line, `"stack":"goroutine`)
+				e.HasTrace = strings.Contains(line, `"trace_id":"`)
+				got.Log = append(got.Log, e)
+			}
+
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("middleware mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func conflict(http.ResponseWriter, *http.Request) error {
+	return NewHTTPError(http.StatusConflict, "user already exists")
+}
+
+func TestRequestID_Generated(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = GetRequestID(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if len(seen) != 32 {
+		t.Errorf("GetRequestID() = %q, want a generated 32 character id", seen)
+	}
+	if got := rec.Header().Get(RequestIDHeader); got != seen {
+		t.Errorf("X-Request-ID header = %q, want %q", got, seen)
+	}
+}
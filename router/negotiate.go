@@ -0,0 +1,273 @@
+package router
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// built-in content types
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeXML  = "application/xml"
+	ContentTypeText = "text/plain; charset=utf-8"
+)
+
+// none of the registered encoders produce a type the request accepts
+var ErrNotAcceptable = errors.New("not acceptable")
+
+// An encoder for a content type, e.g. json.Marshal for "application/json".
+type encoder struct {
+	contentType string // the Content-Type header value
+	mediaType   string // the content type without parameters, lowercased
+	encode      func(v any) ([]byte, error)
+}
+
+var encoders = struct {
+	sync.RWMutex
+	list []encoder
+}{list: []encoder{
+	{contentType: ContentTypeJSON, mediaType: "application/json", encode: json.Marshal},
+	{contentType: ContentTypeXML, mediaType: "application/xml", encode: xml.Marshal},
+	{contentType: ContentTypeText, mediaType: "text/plain", encode: encodeText},
+}}
+
+// RegisterEncoder
+// adds an encoder that RespondTo() can pick for requests accepting its
+// content type (e.g. "application/yaml"), replacing any encoder already
+// registered for it. When a request accepts any type, encoders are tried
+// in the order they were registered, JSON first.
+func RegisterEncoder(contentType string, encode func(v any) ([]byte, error)) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%s: %w, invalid content type %q", packageKey, err, contentType)
+	}
+
+	encoders.Lock()
+	defer encoders.Unlock()
+
+	e := encoder{contentType: contentType, mediaType: mediaType, encode: encode}
+	for i := range encoders.list {
+		if encoders.list[i].mediaType == mediaType {
+			encoders.list[i] = e
+			return nil
+		}
+	}
+	encoders.list = append(encoders.list, e)
+	return nil
+}
+
+// RespondTo
+// writes a reply in the content type the request's Accept header prefers
+// among JSON, XML, plain text and any registered encoders, JSON when it
+// accepts anything. A "406 Not Acceptable" is replied with when none are
+// accepted, and a "500 Internal Server Error" when the payload cannot be
+// encoded. HEAD requests get the headers without the body. The reply
+// varies by Accept so caches keep one copy per content type.
+func RespondTo(w http.ResponseWriter, r *http.Request, code int, payload interface{}) {
+	w.Header().Add("Vary", "Accept")
+	e, ok := negotiate(r.Header.Values("Accept"))
+	if !ok {
+		e = encoder{contentType: ContentTypeJSON, encode: json.Marshal}
+		code, payload = http.StatusNotAcceptable, Error{Err: ErrNotAcceptable.Error()}
+	}
+	write(w, r.Method == http.MethodHead, e, code, payload)
+}
+
+// RespondErrorTo
+// writes an error reply in the content type the request accepts, like
+// RespondTo(). A *BindError is replied with its own status and the fields
+// that are invalid.
+func RespondErrorTo(w http.ResponseWriter, r *http.Request, code int, err error) {
+	code, e := errorReply(code, err)
+	RespondTo(w, r, code, e)
+}
+
+/********** helper functions **********/
+
+// negotiate
+// returns the encoder for the most preferred type of the Accept header,
+// by quality and then specificity. A missing header accepts anything.
+func negotiate(accept []string) (encoder, bool) {
+	encoders.RLock()
+	defer encoders.RUnlock()
+
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return encoders.list[0], true
+	}
+	for _, ar := range ranges {
+		if ar.q <= 0 {
+			break
+		}
+		for _, e := range encoders.list {
+			if ar.matches(e.mediaType) && !excluded(ranges, e.mediaType) {
+				return e, true
+			}
+		}
+	}
+	return encoder{}, false
+}
+
+// A media range of an Accept header, e.g. "text/*;q=0.5".
+type acceptRange struct {
+	mediaType   string
+	q           float64
+	specificity int // 2 for "type/subtype", 1 for "type/*", 0 for "*/*"
+}
+
+// matches
+// reports whether the media type is within the range.
+func (ar acceptRange) matches(mediaType string) bool {
+	switch ar.specificity {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(ar.mediaType, "*"))
+	}
+	return ar.mediaType == mediaType
+}
+
+// excluded
+// reports whether the most specific range matching the media type has a
+// quality of 0, e.g. "application/xml" in "*/*, application/xml;q=0".
+func excluded(ranges []acceptRange, mediaType string) bool {
+	best := -1
+	q := 1.0
+	for _, ar := range ranges {
+		if ar.matches(mediaType) && ar.specificity > best {
+			best, q = ar.specificity, ar.q
+		}
+	}
+	return q <= 0
+}
+
+// parseAccept
+// parses the media ranges of Accept headers, most preferred first.
+// Invalid ranges are skipped.
+func parseAccept(values []string) []acceptRange {
+	var ranges []acceptRange
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil || !strings.Contains(mediaType, "/") {
+				continue
+			}
+
+			ar := acceptRange{mediaType: mediaType, q: 1, specificity: 2}
+			if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+				ar.q = q
+			}
+			switch {
+			case mediaType == "*/*":
+				ar.specificity = 0
+			case strings.HasSuffix(mediaType, "/*"):
+				ar.specificity = 1
+			}
+			ranges = append(ranges, ar)
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity > ranges[j].specificity
+	})
+	return ranges
+}
+
+// lookupEncoder
+// returns the registered encoder for an encoding func, or one without a
+// content type for an unknown func.
+func lookupEncoder(encode func(v any) ([]byte, error)) encoder {
+	ptr := reflect.ValueOf(encode).Pointer()
+
+	encoders.RLock()
+	defer encoders.RUnlock()
+	for _, e := range encoders.list {
+		if reflect.ValueOf(e.encode).Pointer() == ptr {
+			return e
+		}
+	}
+	return encoder{encode: encode}
+}
+
+// write
+// encodes the payload and writes the reply. Status codes that have no
+// body, such as "204 No Content", are written without one, and head only
+// writes the headers. A "500 Internal Server Error" is written when the
+// payload cannot be encoded.
+func write(w http.ResponseWriter, head bool, e encoder, code int, payload interface{}) {
+	if !bodyAllowed(code) {
+		w.WriteHeader(code)
+		return
+	}
+
+	body, err := e.encode(payload)
+	if err != nil {
+		code = http.StatusInternalServerError
+		if body, err = e.encode(Error{Err: ErrInternal.Error()}); err != nil {
+			e, body = encoder{contentType: ContentTypeText}, []byte(ErrInternal.Error())
+		}
+	}
+
+	// unknown encoding funcs get a content type detected from what they wrote
+	contentType := e.contentType
+	switch {
+	case contentType != "":
+	case json.Valid(body):
+		contentType = ContentTypeJSON
+	default:
+		contentType = http.DetectContentType(body)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+
+	// If WriteHeader is not called, Write() calls WriteHeader(http.StatusOK).
+	w.WriteHeader(code)
+
+	if !head {
+		w.Write(body) // nolint:errcheck
+	}
+}
+
+// bodyAllowed
+// reports whether a response with the status code may have a body.
+func bodyAllowed(code int) bool {
+	switch {
+	case code >= 100 && code < 200:
+		return false
+	case code == http.StatusNoContent, code == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// encodeText
+// encodes strings, byte slices, errors and fmt.Stringers as they are and
+// anything else with fmt.Sprint().
+func encodeText(v any) ([]byte, error) {
+	switch t := v.(type) {
+	case string:
+		return []byte(t), nil
+	case []byte:
+		return t, nil
+	case error:
+		return []byte(t.Error()), nil
+	case fmt.Stringer:
+		return []byte(t.String()), nil
+	}
+	return []byte(fmt.Sprint(v)), nil
+}
@@ -0,0 +1,195 @@
+package router
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// the parts of a reply the tests compare
+type reply struct {
+	Code        int
+	ContentType string
+	Length      string
+	Body        string
+}
+
+func recordReply(rec *httptest.ResponseRecorder) reply {
+	return reply{
+		Code:        rec.Code,
+		ContentType: rec.Header().Get("Content-Type"),
+		Length:      rec.Header().Get("Content-Length"),
+		Body:        rec.Body.String(),
+	}
+}
+
+func TestRespond(t *testing.T) {
+	type args struct {
+		encoding func(v any) ([]byte, error)
+		code     int
+		payload  interface{}
+	}
+	tests := []struct {
+		name string
+		args args
+		resp reply
+	}{
+		{
+			name: "json",
+			args: args{encoding: json.Marshal, code: http.StatusOK, payload: Message{MSG: "OK"}},
+			resp: reply{Code: 200, ContentType: "application/json", Length: "12", Body: `{"msg":"OK"}`},
+		},
+		{
+			name: "xml",
+			args: args{encoding: xml.Marshal, code: http.StatusCreated, payload: Message{ID: 7, MSG: "created"}},
+			resp: reply{Code: 201, ContentType: "application/xml", Length: "47", Body: `<Message><id>7</id><msg>created</msg></Message>`},
+		},
+		{
+			name: "unknown json encoding",
+			args: args{encoding: func(v any) ([]byte, error) { return json.MarshalIndent(v, "", " ") }, code: http.StatusOK, payload: Message{MSG: "OK"}},
+			resp: reply{Code: 200, ContentType: "application/json", Length: "16", Body: "{\n \"msg\": \"OK\"\n}"},
+		},
+		{
+			name: "encoding error",
+			args: args{encoding: json.Marshal, code: http.StatusOK, payload: map[string]interface{}{"fn": func() {}}},
+			resp: reply{Code: 500, ContentType: "application/json", Length: "33", Body: `{"error":"internal server error"}`},
+		},
+		{
+			name: "no content",
+			args: args{encoding: json.Marshal, code: http.StatusNoContent, payload: Message{MSG: "deleted"}},
+			resp: reply{Code: 204},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Respond(rec, test.args.encoding, test.args.code, test.args.payload)
+
+			if diff := cmp.Diff(test.resp, recordReply(rec)); diff != "" {
+				t.Errorf("Respond() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRespondTo(t *testing.T) {
+	if err := RegisterEncoder("application/x-test", func(v any) ([]byte, error) { return []byte("test"), nil }); err != nil {
+		t.Fatalf("RegisterEncoder() error = %s", err)
+	}
+
+	type args struct {
+		method  string
+		accept  string
+		code    int
+		payload interface{}
+	}
+	tests := []struct {
+		name string
+		args args
+		resp reply
+	}{
+		{
+			name: "no accept header",
+			args: args{accept: "", code: http.StatusOK, payload: Message{MSG: "OK"}},
+			resp: reply{Code: 200, ContentType: "application/json", Length: "12", Body: `{"msg":"OK"}`},
+		},
+		{
+			name: "xml",
+			args: args{accept: "application/xml", code: http.StatusOK, payload: Message{MSG: "OK"}},
+			resp: reply{Code: 200, ContentType: "application/xml", Length: "32", Body: `<Message><msg>OK</msg></Message>`},
+		},
+		{
+			name: "plain text",
+			args: args{accept: "text/*", code: http.StatusOK, payload: Message{MSG: "OK"}},
+			resp: reply{Code: 200, ContentType: "text/plain; charset=utf-8", Length: "2", Body: "OK"},
+		},
+		{
+			name: "quality",
+			args: args{accept: "application/json;q=0.5, text/plain;q=0.9", code: http.StatusOK, payload: "hello"},
+			resp: reply{Code: 200, ContentType: "text/plain; charset=utf-8", Length: "5", Body: "hello"},
+		},
+		{
+			name: "browser",
+			args: args{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", code: http.StatusOK, payload: Message{MSG: "OK"}},
+			resp: reply{Code: 200, ContentType: "application/xml", Length: "32", Body: `<Message><msg>OK</msg></Message>`},
+		},
+		{
+			name: "excluded",
+			args: args{accept: "*/*, application/json;q=0", code: http.StatusOK, payload: Message{MSG: "OK"}},
+			resp: reply{Code: 200, ContentType: "application/xml", Length: "32", Body: `<Message><msg>OK</msg></Message>`},
+		},
+		{
+			name: "registered encoder",
+			args: args{accept: "application/x-test", code: http.StatusOK, payload: Message{MSG: "OK"}},
+			resp: reply{Code: 200, ContentType: "application/x-test", Length: "4", Body: "test"},
+		},
+		{
+			name: "not acceptable",
+			args: args{accept: "image/png", code: http.StatusOK, payload: Message{MSG: "OK"}},
+			resp: reply{Code: 406, ContentType: "application/json", Length: "26", Body: `{"error":"not acceptable"}`},
+		},
+		{
+			name: "encoding error",
+			args: args{accept: "application/xml", code: http.StatusOK, payload: map[string]string{"a": "b"}},
+			resp: reply{Code: 500, ContentType: "application/xml", Length: "51", Body: `<Error><error>internal server error</error></Error>`},
+		},
+		{
+			name: "head",
+			args: args{method: http.MethodHead, code: http.StatusOK, payload: Message{MSG: "OK"}},
+			resp: reply{Code: 200, ContentType: "application/json", Length: "12"},
+		},
+		{
+			name: "no content",
+			args: args{code: http.StatusNoContent, payload: Message{MSG: "OK"}},
+			resp: reply{Code: 204},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.args.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/", nil)
+			if test.args.accept != "" {
+				req.Header.Set("Accept", test.args.accept)
+			}
+			rec := httptest.NewRecorder()
+			RespondTo(rec, req, test.args.code, test.args.payload)
+
+			if diff := cmp.Diff(test.resp, recordReply(rec)); diff != "" {
+				t.Errorf("RespondTo() mismatch (-want +got):\n%s", diff)
+			}
+			if vary := rec.Header().Values("Vary"); !cmp.Equal([]string{"Accept"}, vary) {
+				t.Errorf("RespondTo() Vary = %q, want [Accept]", vary)
+			}
+		})
+	}
+}
+
+func TestRespondErrorTo(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/plain")
+	rec := httptest.NewRecorder()
+
+	RespondErrorTo(rec, req, http.StatusNotFound, errors.New("user not found"))
+
+	want := reply{Code: 404, ContentType: "text/plain; charset=utf-8", Length: "14", Body: "user not found"}
+	if diff := cmp.Diff(want, recordReply(rec)); diff != "" {
+		t.Errorf("RespondErrorTo() mismatch (-want +got):\n%s", diff)
+	}
+	if vary := rec.Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("RespondErrorTo() Vary = %q, want Accept", vary)
+	}
+}
+
+func TestRegisterEncoder_Invalid(t *testing.T) {
+	if err := RegisterEncoder("not a type;;", json.Marshal); err == nil {
+		t.Errorf("RegisterEncoder() error = nil, want an invalid content type error")
+	}
+}
@@ -0,0 +1,294 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+func updateUser(ctx context.Context, req user) (user, error) {
+	return req, nil
+}
+
+func getUserHandler(ctx context.Context, req getUser) (user, error) {
+	return user{}, nil
+}
+
+// apiRouter
+// returns a router with the built-in routes and a documented api.
+func apiRouter() *mux.Router {
+	r := newRouter(newRegistry(nil, config.Readiness{}), nil)
+	api := r.PathPrefix("/api").Subrouter()
+	api.Handle("/users/{id:[0-9]+}", Document(Handle(updateUser),
+		WithSummary("Update a user"),
+		WithTags("users"),
+		WithResponse(http.StatusNotFound, "no such user", Problem{}),
+	)).Methods(http.MethodPut).Name("updateUser")
+	api.Handle("/users/{id}", Document(Handle(getUserHandler),
+		WithResponse(http.StatusOK, "the user", user{}),
+		WithParam("path", "id", "the user's id"),
+		WithParam("header", RequestIDHeader, "traces the request"),
+		Deprecated(),
+	)).Methods(http.MethodGet, http.MethodHead).Name("getUser")
+	api.HandleFunc("/files/{path:.+}", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	api.HandleFunc("/anything", func(w http.ResponseWriter, r *http.Request) {})
+	return r
+}
+
+func TestNewOpenAPI(t *testing.T) {
+	info := Info{Title: "users", Version: "1.0.0"}
+	spec, err := NewOpenAPI(apiRouter(), info)
+	if err != nil {
+		t.Fatalf("NewOpenAPI() error = %s", err)
+	}
+
+	var paths []string
+	for p, ops := range spec.Paths {
+		for method := range ops {
+			paths = append(paths, method+" "+p)
+		}
+	}
+	sort.Strings(paths)
+	want := []string{"get /api/files/{path}", "get /api/users/{id}", "get /health", "get /metrics", "get /ready", "head /api/users/{id}", "put /api/users/{id}"}
+	if diff := cmp.Diff(want, paths); diff != "" {
+		t.Errorf("NewOpenAPI() paths mismatch (-want +got):\n%s", diff)
+	}
+	if spec.OpenAPI != OpenAPIVersion || spec.Info != info {
+		t.Errorf("NewOpenAPI() = %s %+v, want %s %+v", spec.OpenAPI, spec.Info, OpenAPIVersion, info)
+	}
+
+	userRef := &Schema{Ref: "#/components/schemas/user"}
+	problemRef := &Schema{Ref: "#/components/schemas/Problem"}
+	query := []Parameter{
+		{Name: "page", In: "query", Schema: &Schema{Type: "integer", Format: "int64", Minimum: ptr(1.0)}},
+		{Name: "tag", In: "query", Schema: &Schema{Type: "array", Items: &Schema{Type: "string"}, MaxItems: ptr(2)}},
+		{Name: "timeout", In: "query", Schema: &Schema{Type: "string", Format: "duration"}},
+	}
+	tests := []struct {
+		name   string
+		path   string
+		method string
+		want   *Operation
+	}{
+		{
+			name:   "typed handler",
+			path:   "/api/users/{id}",
+			method: "put",
+			want: &Operation{
+				OperationID: "updateUser",
+				Summary:     "Update a user",
+				Tags:        []string{"users"},
+				Parameters: append([]Parameter{
+					{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer", Format: "int64", Minimum: ptr(1.0)}},
+				}, query...),
+				RequestBody: &RequestBody{Content: map[string]MediaType{"application/json": {Schema: userRef}}},
+				Responses: map[string]Response{
+					"200":     {Description: "OK", Content: map[string]MediaType{"application/json": {Schema: userRef}}},
+					"404":     {Description: "no such user", Content: map[string]MediaType{"application/problem+json": {Schema: problemRef}}},
+					"default": {Description: "an error as problem details", Content: map[string]MediaType{"application/problem+json": {Schema: problemRef}}},
+				},
+			},
+		},
+		{
+			name:   "documented params",
+			path:   "/api/users/{id}",
+			method: "head",
+			want: &Operation{
+				OperationID: "getUser_head",
+				Parameters: []Parameter{
+					{Name: "id", In: "path", Description: "the user's id", Required: true, Schema: &Schema{Type: "integer", Format: "int64", Minimum: ptr(1.0)}},
+					{Name: RequestIDHeader, In: "header", Description: "traces the request", Schema: &Schema{Type: "string"}},
+				},
+				Responses: map[string]Response{
+					"200":     {Description: "the user", Content: map[string]MediaType{"application/json": {Schema: userRef}}},
+					"default": {Description: "an error as problem details", Content: map[string]MediaType{"application/problem+json": {Schema: problemRef}}},
+				},
+				Deprecated: true,
+			},
+		},
+		{
+			name:   "undocumented",
+			path:   "/api/files/{path}",
+			method: "get",
+			want: &Operation{
+				Parameters: []Parameter{{Name: "path", In: "path", Required: true, Schema: &Schema{Type: "string", Pattern: "^.+$"}}},
+				Responses:  map[string]Response{"default": {Description: "an undocumented reply"}},
+			},
+		},
+		{
+			name:   "built-in",
+			path:   "/ready",
+			method: "get",
+			want: &Operation{
+				OperationID: ReadyRoute,
+				Summary:     "Readiness check",
+				Responses: map[string]Response{
+					"200": {Description: "every critical dependency is up", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Readiness"}}}},
+					"503": {Description: "a critical dependency is down or the application is shutting down", Content: map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Readiness"}}}},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, spec.Paths[test.path][test.method]); diff != "" {
+				t.Errorf("NewOpenAPI() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	var components []string
+	for name := range spec.Components.Schemas {
+		components = append(components, name)
+	}
+	sort.Strings(components)
+	if diff := cmp.Diff([]string{"Dependency", "InvalidParam", "Message", "Problem", "Readiness", "address", "user"}, components); diff != "" {
+		t.Errorf("NewOpenAPI() components mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewOpenAPI_Error(t *testing.T) {
+	type stream struct {
+		Events chan string `json:"events"`
+	}
+	r := mux.NewRouter()
+	r.Handle("/stream", Document(http.NotFoundHandler(), WithResponse(http.StatusOK, "", stream{}))).Methods(http.MethodGet)
+
+	_, err := NewOpenAPI(r, Info{})
+	want := "router: 200 reply: field Events: cannot describe a chan string, could not document GET /stream"
+	if err == nil || err.Error() != want {
+		t.Errorf("NewOpenAPI() error = %v, want %s", err, want)
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	r := mux.NewRouter()
+	v1 := r.PathPrefix("/v1").Subrouter()
+	ServeOpenAPI(v1, "/openapi.json", Info{Title: "users <api>", Version: "1.0.0"}, WithDocsPage("/docs"))
+	// registered after ServeOpenAPI() to be in the document all the same
+	v1.Handle("/users", Handle(updateUser)).Methods(http.MethodPost)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ContentTypeJSON {
+		t.Fatalf("ServeOpenAPI() = %d %s, want 200 %s", rec.Code, rec.Header().Get("Content-Type"), ContentTypeJSON)
+	}
+
+	var spec OpenAPI
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("json.Unmarshal() error = %s", err)
+	}
+	if _, ok := spec.Paths["/v1/users"]["post"]; !ok {
+		t.Errorf("ServeOpenAPI() did not document a route registered after it")
+	}
+	for _, p := range []string{"/v1/openapi.json", "/v1/docs"} {
+		if _, ok := spec.Paths[p]; ok {
+			t.Errorf("ServeOpenAPI() documented its own route %s", p)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/docs", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	type page struct {
+		Code        int
+		ContentType string
+		Title       bool
+		SpecURL     bool
+		ScriptHash  bool
+	}
+	want := page{Code: 200, ContentType: "text/html; charset=utf-8", Title: true, SpecURL: true, ScriptHash: true}
+	got := page{
+		Code:        rec.Code,
+		ContentType: rec.Header().Get("Content-Type"),
+		Title:       strings.Contains(string(body), "<title>users &lt;api&gt;</title>"),
+		SpecURL:     strings.Contains(string(body), `SwaggerUIBundle({url: "/v1/openapi.json"`),
+		ScriptHash:  strings.Contains(rec.Header().Get("Content-Security-Policy"), "script-src "+SwaggerUIURL+"/swagger-ui-bundle.js 'sha256-"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ServeOpenAPI() docs page mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_docsPage(t *testing.T) {
+	type docs struct {
+		Stylesheet string
+		Script     string
+		StyleSrc   string
+		ScriptSrc  string
+	}
+	tests := []struct {
+		name   string
+		assets docsAssets
+		want   docs
+	}{
+		{
+			name:   "default",
+			assets: docsAssets{baseURL: SwaggerUIURL},
+			want: docs{
+				Stylesheet: `<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui.css">`,
+				Script:     `<script src="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui-bundle.js"></script>`,
+				StyleSrc:   "https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui.css",
+				ScriptSrc:  "https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui-bundle.js",
+			},
+		},
+		{
+			name:   "integrity",
+			assets: docsAssets{baseURL: "https://cdn.example.com/swagger-ui/", cssIntegrity: "sha384-css", jsIntegrity: "sha384-js"},
+			want: docs{
+				Stylesheet: `<link rel="stylesheet" href="https://cdn.example.com/swagger-ui/swagger-ui.css" integrity="sha384-css" crossorigin="anonymous">`,
+				Script:     `<script src="https://cdn.example.com/swagger-ui/swagger-ui-bundle.js" integrity="sha384-js" crossorigin="anonymous"></script>`,
+				StyleSrc:   "https://cdn.example.com/swagger-ui/swagger-ui.css",
+				ScriptSrc:  "https://cdn.example.com/swagger-ui/swagger-ui-bundle.js",
+			},
+		},
+		{
+			name:   "self hosted",
+			assets: docsAssets{baseURL: "/static/swagger-ui"},
+			want: docs{
+				Stylesheet: `<link rel="stylesheet" href="/static/swagger-ui/swagger-ui.css">`,
+				Script:     `<script src="/static/swagger-ui/swagger-ui-bundle.js"></script>`,
+				StyleSrc:   "'self'",
+				ScriptSrc:  "'self'",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, csp := docsPage("users", "/openapi.json", test.assets)
+
+			var got docs
+			for _, line := range strings.Split(string(page), "\n") {
+				switch {
+				case strings.HasPrefix(line, "<link"):
+					got.Stylesheet = line
+				case strings.HasPrefix(line, "<script src"):
+					got.Script = line
+				}
+			}
+			for _, directive := range strings.Split(csp, "; ") {
+				fields := strings.Fields(directive)
+				switch fields[0] {
+				case "style-src":
+					got.StyleSrc = fields[1]
+				case "script-src":
+					got.ScriptSrc = fields[1]
+				}
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("docsPage() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
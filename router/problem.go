@@ -0,0 +1,290 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+
+	errs "github.com/jobaldw/shared/v2/errors"
+	"github.com/jobaldw/shared/v2/mongo"
+)
+
+// the content type of RFC 7807 problem details
+const ContentTypeProblem = "application/problem+json"
+
+// the problem type of problems that are only described by their status
+const BlankProblemType = "about:blank"
+
+// problem extension keys
+const (
+	InvalidParamsKey  = "invalid-params"
+	ClientKey         = "client"
+	UpstreamStatusKey = "upstream_status"
+	OperationKey      = "operation"
+)
+
+// A Problem describes an error reply in the RFC 7807 problem details
+// format (https://www.rfc-editor.org/rfc/rfc7807), replied with as
+// "application/problem+json". Extension members are written alongside the
+// standard ones. A Problem is an error, so handlers can return it as is.
+type Problem struct {
+	// a URI identifying the kind of problem, "about:blank" by default
+	Type string
+
+	// a short summary of the kind of problem, the status text by default
+	Title string
+
+	// the HTTP status code
+	Status int
+
+	// an explanation of this occurrence of the problem
+	Detail string
+
+	// a URI identifying this occurrence, the request's path by default
+	Instance string
+
+	// any other members, e.g. "invalid-params"
+	Extensions map[string]interface{}
+}
+
+// A request parameter that did not validate, listed by a validation
+// problem.
+type InvalidParam struct {
+	Name   string `json:"name" xml:"name"`
+	Reason string `json:"reason" xml:"reason"`
+}
+
+// NewProblem
+// creates a problem for a status code, titled with its status text.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{Type: BlankProblemType, Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// ValidationProblem
+// creates a "400 Bad Request" problem listing the request parameters that
+// did not validate under "invalid-params".
+func ValidationProblem(params ...InvalidParam) *Problem {
+	p := NewProblem(http.StatusBadRequest, "the request parameters did not validate")
+	p.Title = "Your request parameters didn't validate."
+	return p.With(InvalidParamsKey, params)
+}
+
+// With
+// sets an extension member on the problem and returns it.
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// Error
+// implements the error interface.
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+// MarshalJSON
+// encodes the problem's members and extension members as one object.
+// Extension members never replace the standard ones.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	for k, v := range map[string]string{"type": p.Type, "title": p.Title, "detail": p.Detail, "instance": p.Instance} {
+		delete(m, k)
+		if v != "" {
+			m[k] = v
+		}
+	}
+	delete(m, "status")
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON
+// decodes a problem, keeping any members other than the standard ones as
+// extension members.
+func (p *Problem) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	*p = Problem{}
+	members := map[string]interface{}{"type": &p.Type, "title": &p.Title, "status": &p.Status, "detail": &p.Detail, "instance": &p.Instance}
+	for k, raw := range m {
+		if member, ok := members[k]; ok {
+			if err := json.Unmarshal(raw, member); err != nil {
+				return fmt.Errorf("%s: %w, invalid problem member %q", packageKey, err, k)
+			}
+			continue
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		p.With(k, v)
+	}
+	return nil
+}
+
+// ToProblem
+// describes an error as a problem:
+//   - a Problem is returned as is
+//   - an HTTPError is a problem with its status and message
+//   - a BindError is a validation problem with the bind error's status, or
+//     a problem detailing why the request could not be read
+//   - an errors.ClientErr is a "404 Not Found" or "409 Conflict" when the
+//     client got one, a "504 Gateway Timeout" when it timed out, a
+//     "503 Service Unavailable" when it was rate limited or unavailable,
+//     and a "502 Bad Gateway" otherwise
+//   - an errors.MongoErr is a "404 Not Found" when no documents were
+//     found, a "409 Conflict" for duplicate keys, a "504 Gateway Timeout"
+//     when it timed out and a "500 Internal Server Error" otherwise
+//   - an expired context is a "504 Gateway Timeout"
+//
+// Any other error is a "500 Internal Server Error" that does not expose
+// the error's message.
+func ToProblem(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return NewProblem(he.Status, he.Msg)
+	}
+
+	var be *BindError
+	if errors.As(err, &be) {
+		return bindProblem(be)
+	}
+
+	var ce errs.ClientErr
+	if errors.As(err, &ce) {
+		return clientProblem(ce)
+	}
+	var cePtr *errs.ClientErr
+	if errors.As(err, &cePtr) && cePtr != nil {
+		return clientProblem(*cePtr)
+	}
+
+	var me errs.MongoErr
+	if errors.As(err, &me) {
+		return mongoProblem(me)
+	}
+	var mePtr *errs.MongoErr
+	if errors.As(err, &mePtr) && mePtr != nil {
+		return mongoProblem(*mePtr)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewProblem(http.StatusGatewayTimeout, "")
+	}
+	return NewProblem(http.StatusInternalServerError, "")
+}
+
+// RespondProblem
+// writes the error as "application/problem+json", describing it with
+// ToProblem(). The problem's instance is the request's path unless it is
+// set. HEAD requests get the headers without the body.
+func RespondProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := *ToProblem(err)
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+	if problem.Instance == "" {
+		problem.Instance = r.URL.Path
+	}
+
+	e := encoder{contentType: ContentTypeProblem, encode: json.Marshal}
+	write(w, r.Method == http.MethodHead, e, problem.Status, problem)
+}
+
+/********** helper functions **********/
+
+// bindProblem
+// describes a bind error, listing its invalid params if any.
+func bindProblem(be *BindError) *Problem {
+	if len(be.Params) == 0 {
+		return NewProblem(be.Status, be.Err.Error())
+	}
+	p := ValidationProblem(be.Params...)
+	p.Status = be.Status
+	return p
+}
+
+// clientProblem
+// describes a client error by the status the client got.
+func clientProblem(ce errs.ClientErr) *Problem {
+	status := http.StatusBadGateway
+	switch {
+	case ce.StatusCode == 0 && errors.Is(ce.Err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case ce.StatusCode == http.StatusNotFound, ce.StatusCode == http.StatusConflict:
+		status = ce.StatusCode
+	case ce.StatusCode == http.StatusRequestTimeout, ce.StatusCode == http.StatusGatewayTimeout:
+		status = http.StatusGatewayTimeout
+	case ce.StatusCode == http.StatusTooManyRequests, ce.StatusCode == http.StatusServiceUnavailable:
+		status = http.StatusServiceUnavailable
+	}
+
+	p := NewProblem(status, ce.Msg)
+	if ce.Client != "" {
+		p.With(ClientKey, ce.Client)
+	}
+	if ce.StatusCode != 0 {
+		p.With(UpstreamStatusKey, ce.StatusCode)
+	}
+	return p
+}
+
+// mongoProblem
+// describes a mongo error by its cause, without exposing the collection.
+func mongoProblem(me errs.MongoErr) *Problem {
+	var p *Problem
+	switch {
+	case me.Err == nil && me.Operation == errs.Read,
+		errors.Is(me.Err, mongo.ErrNoDocuments), errors.Is(me.Err, mongodriver.ErrNoDocuments):
+		p = NewProblem(http.StatusNotFound, errs.ErrNoBudgetsFound.Error())
+	case mongodriver.IsDuplicateKeyError(me.Err):
+		p = NewProblem(http.StatusConflict, "document already exists")
+	case errors.Is(me.Err, context.DeadlineExceeded), mongodriver.IsTimeout(me.Err):
+		p = NewProblem(http.StatusGatewayTimeout, "")
+	default:
+		p = NewProblem(http.StatusInternalServerError, operationFailure(me.Operation))
+	}
+
+	if me.Operation != "" {
+		p.With(OperationKey, me.Operation)
+	}
+	return p
+}
+
+// operationFailure
+// returns the failure message of a mongo operation, if any.
+func operationFailure(op string) string {
+	switch op {
+	case errs.Delete:
+		return errs.ErrDeleteFailed.Error()
+	case errs.Insert:
+		return errs.ErrInsertFailed.Error()
+	case errs.Update:
+		return errs.ErrUpdateFailed.Error()
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+
+	errs "github.com/jobaldw/shared/v2/errors"
+	"github.com/jobaldw/shared/v2/mongo"
+)
+
+func TestToProblem(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		resp *Problem
+	}{
+		{
+			name: "problem",
+			err:  fmt.Errorf("finding user: %w", NewProblem(http.StatusForbidden, "not your user").With("user", "42")),
+			resp: &Problem{Type: "about:blank", Title: "Forbidden", Status: 403, Detail: "not your user", Extensions: map[string]interface{}{"user": "42"}},
+		},
+		{
+			name: "bind validation",
+			err:  &BindError{Status: 422, Err: ErrInvalidRequest, Params: []InvalidParam{{Name: "name", Reason: "is required"}}},
+			resp: &Problem{Type: "about:blank", Title: "Your request parameters didn't validate.", Status: 422, Detail: "the request parameters did not validate", Extensions: map[string]interface{}{"invalid-params": []InvalidParam{{Name: "name", Reason: "is required"}}}},
+		},
+		{
+			name: "bind too large",
+			err:  &BindError{Status: 413, Err: ErrBodyTooLarge},
+			resp: &Problem{Type: "about:blank", Title: "Request Entity Too Large", Status: 413, Detail: "request body too large"},
+		},
+		{
+			name: "client not found",
+			err:  errs.ClientErr{Client: "users", StatusCode: 404, Msg: "user not found"},
+			resp: &Problem{Type: "about:blank", Title: "Not Found", Status: 404, Detail: "user not found", Extensions: map[string]interface{}{"client": "users", "upstream_status": 404}},
+		},
+		{
+			name: "client rate limited",
+			err:  &errs.ClientErr{Client: "users", StatusCode: 429},
+			resp: &Problem{Type: "about:blank", Title: "Service Unavailable", Status: 503, Extensions: map[string]interface{}{"client": "users", "upstream_status": 429}},
+		},
+		{
+			name: "client unauthorized",
+			err:  errs.ClientErr{Client: "users", StatusCode: 401},
+			resp: &Problem{Type: "about:blank", Title: "Bad Gateway", Status: 502, Extensions: map[string]interface{}{"client": "users", "upstream_status": 401}},
+		},
+		{
+			name: "client timed out",
+			err:  errs.ClientErr{Client: "users", Err: context.DeadlineExceeded},
+			resp: &Problem{Type: "about:blank", Title: "Gateway Timeout", Status: 504, Extensions: map[string]interface{}{"client": "users"}},
+		},
+		{
+			name: "client request timed out",
+			err:  errs.ClientErr{Client: "users", Err: fmt.Errorf("client: %w, could not make request", context.DeadlineExceeded)},
+			resp: &Problem{Type: "about:blank", Title: "Gateway Timeout", Status: 504, Extensions: map[string]interface{}{"client": "users"}},
+		},
+		{
+			name: "mongo no documents",
+			err:  errs.NewMongoErr(errs.Read, "users", mongodriver.ErrNoDocuments),
+			resp: &Problem{Type: "about:blank", Title: "Not Found", Status: 404, Detail: "no documents were found", Extensions: map[string]interface{}{"operation": "read"}},
+		},
+		{
+			name: "shared mongo no documents",
+			err:  errs.NewMongoErr("", "users", mongo.ErrNoDocuments),
+			resp: &Problem{Type: "about:blank", Title: "Not Found", Status: 404, Detail: "no documents were found"},
+		},
+		{
+			name: "mongo duplicate key",
+			err:  errs.NewMongoErr(errs.Insert, "users", mongodriver.WriteException{WriteErrors: []mongodriver.WriteError{{Code: 11000}}}),
+			resp: &Problem{Type: "about:blank", Title: "Conflict", Status: 409, Detail: "document already exists", Extensions: map[string]interface{}{"operation": "insert"}},
+		},
+		{
+			name: "mongo failed",
+			err:  errs.NewMongoErr(errs.Update, "users", errors.New("connection reset")),
+			resp: &Problem{Type: "about:blank", Title: "Internal Server Error", Status: 500, Detail: "could not update document", Extensions: map[string]interface{}{"operation": "update"}},
+		},
+		{
+			name: "deadline",
+			err:  fmt.Errorf("loading: %w", context.DeadlineExceeded),
+			resp: &Problem{Type: "about:blank", Title: "Gateway Timeout", Status: 504},
+		},
+		{
+			name: "unknown",
+			err:  errors.New("secret connection string"),
+			resp: &Problem{Type: "about:blank", Title: "Internal Server Error", Status: 500},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.resp, ToProblem(test.err)); diff != "" {
+				t.Errorf("ToProblem() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRespondProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		resp   reply
+	}{
+		{
+			name: "validation",
+			err:  ValidationProblem(InvalidParam{Name: "age", Reason: "must be a positive integer"}),
+			resp: reply{Code: 400, ContentType: "application/problem+json", Length: "232", Body: `{"detail":"the request parameters did not validate","instance":"/users/42","invalid-params":[{"name":"age","reason":"must be a positive integer"}],"status":400,"title":"Your request parameters didn't validate.","type":"about:blank"}`},
+		},
+		{
+			name: "extensions never replace members",
+			err:  &Problem{Type: "https://example.com/probs/out-of-credit", Title: "You do not have enough credit.", Status: 403, Instance: "/account/12345", Extensions: map[string]interface{}{"status": 200, "balance": 30}},
+			resp: reply{Code: 403, ContentType: "application/problem+json", Length: "145", Body: `{"balance":30,"instance":"/account/12345","status":403,"title":"You do not have enough credit.","type":"https://example.com/probs/out-of-credit"}`},
+		},
+		{
+			name: "error",
+			err:  errors.New("secret connection string"),
+			resp: reply{Code: 500, ContentType: "application/problem+json", Length: "90", Body: `{"instance":"/users/42","status":500,"title":"Internal Server Error","type":"about:blank"}`},
+		},
+		{
+			name:   "head",
+			method: http.MethodHead,
+			err:    NewProblem(http.StatusNotFound, ""),
+			resp:   reply{Code: 404, ContentType: "application/problem+json", Length: "78"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			rec := httptest.NewRecorder()
+			RespondProblem(rec, httptest.NewRequest(method, "/users/42", nil), test.err)
+
+			if diff := cmp.Diff(test.resp, recordReply(rec)); diff != "" {
+				t.Errorf("RespondProblem() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProblem_UnmarshalJSON(t *testing.T) {
+	body := `{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit.","status":403,"detail":"Your balance is 30.","instance":"/account/12345","balance":30}`
+
+	var got Problem
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %s", err)
+	}
+
+	want := Problem{
+		Type:       "https://example.com/probs/out-of-credit",
+		Title:      "You do not have enough credit.",
+		Status:     403,
+		Detail:     "Your balance is 30.",
+		Instance:   "/account/12345",
+		Extensions: map[string]interface{}{"balance": float64(30)},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Problem.UnmarshalJSON() mismatch (-want +got):\n%s", diff)
+	}
+	if got.Error() != "You do not have enough credit.: Your balance is 30." {
+		t.Errorf("Problem.Error() = %q", got.Error())
+	}
+
+	if err := json.Unmarshal([]byte(`{"status":"forbidden"}`), &got); err == nil {
+		t.Errorf("json.Unmarshal() error = nil, want an invalid member error")
+	}
+}
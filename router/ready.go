@@ -0,0 +1,92 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jobaldw/shared/v2/client"
+	"github.com/jobaldw/shared/v2/config"
+	"github.com/jobaldw/shared/v2/health"
+)
+
+// how often in seconds New() checks its clients in the background
+const defaultCheckInterval = 10
+
+// Reports whether an application or one of its dependencies is ready.
+type ReadyStatus string
+
+const (
+	StatusUp       = ReadyStatus(health.StatusUp)       // every dependency is up
+	StatusDegraded = ReadyStatus(health.StatusDegraded) // only optional dependencies are down
+	StatusDown     = ReadyStatus(health.StatusDown)     // a critical dependency is down
+)
+
+// The response payload of the readiness endpoint.
+type Readiness struct {
+	// the overall status of the application
+	Status ReadyStatus `json:"status"`
+
+	// why the application is not ready when it is not because of its
+	// dependencies, e.g. when it is shutting down
+	Err string `json:"error,omitempty"`
+
+	// the result of every dependency check, sorted by name
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+// The result of checking a single dependency.
+type Dependency struct {
+	Name     string      `json:"name"`
+	Status   ReadyStatus `json:"status"`
+	Critical bool        `json:"critical"`
+
+	// how long the check took in milliseconds
+	Latency float64 `json:"latency_ms"`
+
+	// why the check failed, if it did
+	Err string `json:"error,omitempty"`
+}
+
+// ready
+// reports the health of every registered dependency. The response is
+// "200 OK" unless a critical dependency is down.
+func ready(registry *health.Registry, draining func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if draining != nil && draining() {
+			Respond(w, json.Marshal, http.StatusServiceUnavailable, Readiness{Status: StatusDown, Err: ErrShuttingDown.Error()})
+			return
+		}
+
+		report := registry.Check(r.Context())
+		readiness := Readiness{Status: ReadyStatus(report.Status), Dependencies: make([]Dependency, 0, len(report.Results))}
+		for _, result := range report.Results {
+			dep := Dependency{
+				Name:     result.Name,
+				Status:   ReadyStatus(result.Status),
+				Critical: result.Critical,
+				Latency:  float64(result.Latency) / float64(time.Millisecond),
+			}
+			if result.Err != nil {
+				dep.Err = result.Err.Error()
+			}
+			readiness.Dependencies = append(readiness.Dependencies, dep)
+		}
+
+		code := http.StatusOK
+		if report.Status == health.StatusDown {
+			code = http.StatusServiceUnavailable
+		}
+		Respond(w, json.Marshal, code, readiness)
+	}
+}
+
+// newRegistry
+// creates a health registry with a check for every client.
+func newRegistry(clients map[string]*client.Client, conf config.Readiness) *health.Registry {
+	registry := health.NewRegistry(conf)
+	for name, c := range clients {
+		registry.Register(name, health.Client(c))
+	}
+	return registry
+}
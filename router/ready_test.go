@@ -0,0 +1,138 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/jobaldw/shared/v2/client"
+	"github.com/jobaldw/shared/v2/config"
+	"github.com/jobaldw/shared/v2/testing/httpmock"
+)
+
+func TestReady(t *testing.T) {
+	svr := httpmock.NewServer()
+	defer svr.Close()
+	svr.On(http.MethodGet, "/up").Respond(http.StatusOK)
+	svr.On(http.MethodGet, "/down").Respond(http.StatusServiceUnavailable)
+	svr.On(http.MethodGet, "/slow").Respond(http.StatusOK).Delay(3 * time.Second)
+
+	dependency := func(path string) *client.Client {
+		c, err := client.New(config.Client{URL: svr.URL, Health: path})
+		if err != nil {
+			t.Fatalf("client.New() error = %s", err)
+		}
+		return c
+	}
+
+	opts := cmp.Options{
+		cmpopts.IgnoreFields(Dependency{}, "Latency", "Err"),
+	}
+
+	type resp struct {
+		Code   int
+		Report Readiness
+	}
+	tests := []struct {
+		name    string
+		clients map[string]*client.Client
+		conf    config.Readiness
+		resp    resp
+	}{
+		{
+			name:    "ready",
+			clients: map[string]*client.Client{"users": dependency("/up")},
+			resp: resp{Code: 200, Report: Readiness{Status: StatusUp, Dependencies: []Dependency{
+				{Name: "users", Status: StatusUp, Critical: true},
+			}}},
+		},
+		{
+			name:    "no dependencies",
+			clients: nil,
+			resp:    resp{Code: 200, Report: Readiness{Status: StatusUp}},
+		},
+		{
+			name:    "critical down",
+			clients: map[string]*client.Client{"users": dependency("/up"), "billing": dependency("/down")},
+			resp: resp{Code: 503, Report: Readiness{Status: StatusDown, Dependencies: []Dependency{
+				{Name: "billing", Status: StatusDown, Critical: true},
+				{Name: "users", Status: StatusUp, Critical: true},
+			}}},
+		},
+		{
+			name:    "optional down",
+			clients: map[string]*client.Client{"users": dependency("/up"), "search": dependency("/down")},
+			conf:    config.Readiness{Optional: []string{"search"}},
+			resp: resp{Code: 200, Report: Readiness{Status: StatusDegraded, Dependencies: []Dependency{
+				{Name: "search", Status: StatusDown},
+				{Name: "users", Status: StatusUp, Critical: true},
+			}}},
+		},
+		{
+			name:    "timeouts",
+			clients: map[string]*client.Client{"users": dependency("/slow"), "search": dependency("/slow")},
+			conf:    config.Readiness{Timeout: 1},
+			resp: resp{Code: 503, Report: Readiness{Status: StatusDown, Dependencies: []Dependency{
+				{Name: "search", Status: StatusDown, Critical: true},
+				{Name: "users", Status: StatusDown, Critical: true},
+			}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			start := time.Now()
+			ready(newRegistry(test.clients, test.conf), nil)(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			elapsed := time.Since(start)
+
+			got := resp{Code: rec.Code}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got.Report); err != nil {
+				t.Fatalf("json.Unmarshal() error = %s", err)
+			}
+			if diff := cmp.Diff(test.resp, got, opts); diff != "" {
+				t.Errorf("ready() mismatch (-want +got):\n%s", diff)
+			}
+
+			for _, dep := range got.Report.Dependencies {
+				if dep.Status == StatusDown && dep.Err == "" {
+					t.Errorf("dependency %s is down without an error", dep.Name)
+				}
+			}
+			if elapsed > 2*time.Second {
+				t.Errorf("ready() took %s, want the checks to run concurrently", elapsed)
+			}
+		})
+	}
+}
+
+func TestNew_ReadyCached(t *testing.T) {
+	svr := httpmock.NewServer()
+	defer svr.Close()
+	svr.On(http.MethodGet, "/up").Respond(http.StatusOK).Delay(200 * time.Millisecond)
+
+	c, err := client.New(config.Client{URL: svr.URL, Health: "/up"})
+	if err != nil {
+		t.Fatalf("client.New() error = %s", err)
+	}
+	_, r := New(8080, map[string]client.Client{"users": *c})
+	time.Sleep(400 * time.Millisecond)
+
+	// the clients are checked in the background, not on every probe
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("GET /ready took %s, want the cached results", elapsed)
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET /ready code = %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+}
@@ -10,73 +10,86 @@ package router
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
 
 	"github.com/jobaldw/shared/v2/client"
+	"github.com/jobaldw/shared/v2/config"
+	"github.com/jobaldw/shared/v2/health"
+	"github.com/jobaldw/shared/v2/metrics"
+)
+
+// the names of the built-in routes, which rate limiting and load shedding
+// leave alone, e.g. r.Get(router.ReadyRoute)
+const (
+	LiveRoute    = "live"
+	ReadyRoute   = "ready"
+	MetricsRoute = "metrics"
 )
 
 // The response payload in the form of an error.
 type Error struct {
-	Err string `json:"error"`
+	Err    string         `json:"error" xml:"error"`
+	Fields []InvalidParam `json:"fields,omitempty" xml:"field,omitempty"`
 }
 
+// String
+// returns the error message, used for plain text replies.
+func (e Error) String() string { return e.Err }
+
 // Basic response fields.
 type Message struct {
-	ID  interface{} `json:"id,omitempty"`
-	MSG string      `json:"msg,omitempty"`
+	ID  interface{} `json:"id,omitempty" xml:"id,omitempty"`
+	MSG string      `json:"msg,omitempty" xml:"msg,omitempty"`
 }
 
+// String
+// returns the message, used for plain text replies.
+func (m Message) String() string { return m.MSG }
+
 // New
 // creates a new http server and mux router with two endpoints for
-// readiness and liveliness.
+// readiness and liveliness, and a "/metrics" endpoint serving the default
+// metrics registry in the Prometheus text format.
 //
 // By default a client's readiness and liveliness endpoints are
 // "/health" and "/ready", but this can be overwritten using the paths
 // variadic parameter.
+//
+// The clients are checked through copies of the values in the map every
+// 10 seconds in the background, for as long as the process runs; use
+// NewServer() to check the clients an application actually uses.
 func New(port int, clients map[string]client.Client, paths ...string) (http.Server, *mux.Router) {
-	r := mux.NewRouter()
-	livePath, readyPath := "", ""
-
-	switch len(paths) {
-	case 1:
-		livePath = "/" + paths[0]
-	case 2:
-		livePath, readyPath = "/"+paths[0], "/"+paths[1]
-	default:
-		livePath, readyPath = "/health", "/ready"
+	pointers := make(map[string]*client.Client, len(clients))
+	for name, c := range clients {
+		c := c
+		pointers[name] = &c
 	}
-
-	r.HandleFunc(livePath, health()).Methods(http.MethodGet)
-	r.HandleFunc(readyPath, ready(clients)).Methods(http.MethodGet)
-
-	return http.Server{Addr: fmt.Sprintf(":%d", port)}, r
+	registry := newRegistry(pointers, config.Readiness{Interval: defaultCheckInterval})
+	registry.Start(context.Background())
+	return http.Server{Addr: fmt.Sprintf(":%d", port)}, newRouter(registry, nil, paths...)
 }
 
 // RespondError
 // writes a client error reply based on its passed in encoding func.
-// (e.g. "json.Marshal()" or "xml.Marshal()")
+// (e.g. "json.Marshal()" or "xml.Marshal()"). A *BindError is replied
+// with its own status and the fields that are invalid.
 func RespondError(w http.ResponseWriter, encoding func(v any) ([]byte, error), code int, err error) {
-	e := Error{Err: err.Error()}
+	code, e := errorReply(code, err)
 	Respond(w, encoding, code, e)
 }
 
 // Respond
 // writes a client message reply based on its passed in encoding func
-// (e.g. "json.Marshal()" or "xml.Marshal()").
+// (e.g. "json.Marshal()" or "xml.Marshal()"). The Content-Type is set to
+// match the encoding func, and a "500 Internal Server Error" is replied
+// with when the payload cannot be encoded. Use RespondTo() to reply in the
+// content type the request accepts instead.
 func Respond(w http.ResponseWriter, encoding func(v any) ([]byte, error), code int, payload interface{}) {
-	response, _ := encoding(payload)
-
-	// If the Header does not contain a "Content-Type" key, Write adds a "Content-Type" set
-	// to the result of passing the initial 512 bytes of written data to DetectContentType().
-	w.Header().Set("Content-Type", "application/json")
-
-	// If WriteHeader is not called, Write() calls WriteHeader(http.StatusOK).
-	w.WriteHeader(code)
-
-	w.Write(response) // nolint:errcheck
+	write(w, false, lookupEncoder(encoding), code, payload)
 }
 
 // Vars
@@ -87,33 +100,60 @@ func Vars(r *http.Request) map[string]string {
 
 /********** helper functions **********/
 
-// health
+// errorReply
+// returns the status and payload of an error reply.
+func errorReply(code int, err error) (int, Error) {
+	e := Error{Err: err.Error()}
+
+	var be *BindError
+	if errors.As(err, &be) {
+		code, e.Fields = be.Status, be.Params
+	}
+	return code, e
+}
+
+// live
 // responses with "200 OK" status whenever called.
-func health() http.HandlerFunc {
+func live() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		code := http.StatusOK
 		Respond(w, json.Marshal, code, Message{MSG: http.StatusText(code)})
 	}
 }
 
-// ready
-// calls every dependent clients ready check endpoint.
-func ready(clients map[string]client.Client) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.Background()
-		for _, client := range clients {
-			isReady, err := client.IsReady(ctx)
-			if err != nil {
-				RespondError(w, json.Marshal, http.StatusBadGateway, err)
-				return
-			}
-
-			if !isReady {
-				Respond(w, json.Marshal, http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
-				return
-			}
-		}
-
-		Respond(w, json.Marshal, http.StatusOK, Message{MSG: "Ready"})
+// newRouter
+// creates a mux router with the liveliness, readiness and metrics
+// endpoints, documented for NewOpenAPI(). The readiness endpoint fails
+// while draining returns true.
+func newRouter(registry *health.Registry, draining func() bool, paths ...string) *mux.Router {
+	r := mux.NewRouter()
+	livePath, readyPath := "", ""
+
+	switch len(paths) {
+	case 1:
+		livePath = "/" + paths[0]
+	case 2:
+		livePath, readyPath = "/"+paths[0], "/"+paths[1]
+	default:
+		livePath, readyPath = "/health", "/ready"
 	}
+
+	liveness := Document(live(),
+		WithSummary("Liveliness check"),
+		WithResponse(http.StatusOK, "the application is alive", Message{}),
+	)
+	readiness := Document(ready(registry, draining),
+		WithSummary("Readiness check"),
+		WithResponse(http.StatusOK, "every critical dependency is up", Readiness{}),
+		WithResponse(http.StatusServiceUnavailable, "a critical dependency is down or the application is shutting down", Readiness{}),
+	)
+	metricsHandler := Document(metrics.Default.Handler(),
+		WithSummary("Metrics"),
+		WithResponse(http.StatusOK, "the metrics in the Prometheus text format", nil),
+	)
+
+	r.Handle(livePath, liveness).Methods(http.MethodGet).Name(LiveRoute)
+	r.Handle(readyPath, readiness).Methods(http.MethodGet).Name(ReadyRoute)
+	r.Handle(MetricsPath, metricsHandler).Methods(http.MethodGet).Name(MetricsRoute)
+	return r
 }
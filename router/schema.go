@@ -0,0 +1,407 @@
+package router
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the struct tag describing fields in OpenAPI documents
+const docTag = "doc"
+
+// where components are referenced from
+const schemaRefPrefix = "#/components/schemas/"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	problemType    = reflect.TypeOf(Problem{})
+	jsonMarshaler  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	// package qualifiers and characters not allowed in component names
+	qualifier   = regexp.MustCompile(`[\w./-]+\.`)
+	invalidName = regexp.MustCompile(`[^\w.-]+`)
+)
+
+// A Schema describes a JSON value in an OpenAPI document.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+}
+
+/********** helper functions **********/
+
+// Reflects schemas from Go types, collecting named structs as components.
+type schemaGen struct {
+	schemas map[string]*Schema      // components by name
+	names   map[reflect.Type]string // the component name of each type
+}
+
+// newSchemaGen
+// creates a generator without any components.
+func newSchemaGen() *schemaGen {
+	return &schemaGen{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// schema
+// describes a type as encoding/json encodes it. Named structs are
+// referenced as components.
+func (g *schemaGen) schema(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t == problemType:
+		return g.ref(t)
+	case t == rawMessageType, implements(t, jsonMarshaler):
+		return &Schema{}, nil
+	case implements(t, textMarshaler):
+		return &Schema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}, nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}, nil
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}, nil
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.ref(t)
+	}
+	return nil, fmt.Errorf("cannot describe a %s", t)
+}
+
+// ref
+// references a named struct, adding it to the components the first time.
+func (g *schemaGen) ref(t reflect.Type) (*Schema, error) {
+	if name, ok := g.names[t]; ok {
+		return &Schema{Ref: schemaRefPrefix + name}, nil
+	}
+
+	name := componentName(t)
+	if _, taken := g.schemas[name]; taken {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	for i, base := 2, name; ; i++ {
+		if _, taken := g.schemas[name]; !taken {
+			break
+		}
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	// named before it is described, so recursive types reference themselves
+	g.names[t] = name
+	g.schemas[name] = nil
+
+	var s *Schema
+	var err error
+	if t == problemType {
+		s, err = g.problem()
+	} else {
+		s, err = g.object(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.schemas[name] = s
+	return &Schema{Ref: schemaRefPrefix + name}, nil
+}
+
+// object
+// describes a struct's JSON fields.
+func (g *schemaGen) object(t reflect.Type) (*Schema, error) {
+	s := &Schema{Type: "object"}
+	if err := g.fields(t, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// fields
+// adds a struct's JSON fields, including those of embedded structs, to an
+// object's properties. Fields only read from the path or query are left
+// out, and fields with "-" as their "json" tag are never encoded.
+func (g *schemaGen) fields(t reflect.Type, s *Schema) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		ft := sf.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			if err := g.fields(ft, s); err != nil {
+				return err
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			if sf.Tag.Get(pathTag) != "" || sf.Tag.Get(queryTag) != "" {
+				continue
+			}
+			name = sf.Name
+		}
+
+		fs, err := g.schema(ft)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		required, err := constrain(fs, sf.Tag.Get(validateTag))
+		if err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		if fs.Ref == "" {
+			fs.Description = sf.Tag.Get(docTag)
+		}
+		// the "string" option quotes numbers and booleans
+		if strings.Contains(","+opts+",", ",string,") && (fs.Type == "integer" || fs.Type == "number" || fs.Type == "boolean") {
+			fs.Type, fs.Format = "string", ""
+		}
+
+		if s.Properties == nil {
+			s.Properties = make(map[string]*Schema)
+		}
+		s.Properties[name] = fs
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return nil
+}
+
+// params
+// describes the fields of a request struct with "path" and "query" tags
+// as params.
+func (g *schemaGen) params(t reflect.Type) ([]Parameter, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	var params []Parameter
+	v := reflect.New(t).Elem()
+	for _, in := range []string{pathTag, queryTag} {
+		for _, f := range taggedFields(v, in) {
+			s, err := g.paramSchema(f.value.Type())
+			if err != nil {
+				return nil, fmt.Errorf("%s param %s: %w", in, f.name, err)
+			}
+			required, err := constrain(s, f.tag.Get(validateTag))
+			if err != nil {
+				return nil, fmt.Errorf("%s param %s: %w", in, f.name, err)
+			}
+			params = append(params, Parameter{
+				Name:        f.name,
+				In:          in,
+				Description: f.tag.Get(docTag),
+				Required:    required || in == pathTag,
+				Schema:      s,
+			})
+		}
+	}
+	return params, nil
+}
+
+// paramSchema
+// describes a field as Bind() parses params into it: slices get every
+// value of a param, and durations and text unmarshalers are strings.
+func (g *schemaGen) paramSchema(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == durationType:
+		return &Schema{Type: "string", Format: "duration"}, nil
+	case reflect.PointerTo(t).Implements(textUnmarshaler):
+		return &Schema{Type: "string"}, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		items, err := g.paramSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	}
+	return g.schema(t)
+}
+
+// problem
+// describes the members of problem details, see Problem.MarshalJSON().
+func (g *schemaGen) problem() (*Schema, error) {
+	params, err := g.schema(reflect.TypeOf([]InvalidParam{}))
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{
+		Type:        "object",
+		Description: "RFC 7807 problem details, with any extension members alongside the standard ones",
+		Properties: map[string]*Schema{
+			"type":           {Type: "string", Format: "uri-reference", Description: "identifies the kind of problem"},
+			"title":          {Type: "string", Description: "a short summary of the kind of problem"},
+			"status":         {Type: "integer", Format: "int32", Description: "the HTTP status code"},
+			"detail":         {Type: "string", Description: "an explanation of this occurrence of the problem"},
+			"instance":       {Type: "string", Format: "uri-reference", Description: "identifies this occurrence of the problem"},
+			InvalidParamsKey: params,
+		},
+	}, nil
+}
+
+// constrain
+// adds the rules of a "validate" tag, see Validate(), to a schema,
+// returning whether the field is required.
+func constrain(s *Schema, rules string) (bool, error) {
+	if rules == "" {
+		return false, nil
+	}
+
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "required":
+			required = true
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return false, fmt.Errorf("invalid validation param %q", param)
+			}
+			if name != "max" {
+				setBound(s, n, true)
+			}
+			if name != "min" {
+				setBound(s, n, false)
+			}
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(s.Type, value))
+			}
+		case "email":
+			s.Format = "email"
+		}
+	}
+	return required, nil
+}
+
+// setBound
+// sets a schema's lower or upper bound, on its value for numbers and on
+// its size otherwise.
+func setBound(s *Schema, n float64, lower bool) {
+	size := int(n)
+	var bound **int
+	switch s.Type {
+	case "integer", "number":
+		if lower {
+			s.Minimum = &n
+		} else {
+			s.Maximum = &n
+		}
+		return
+	case "string":
+		bound = &s.MaxLength
+		if lower {
+			bound = &s.MinLength
+		}
+	case "array":
+		bound = &s.MaxItems
+		if lower {
+			bound = &s.MinItems
+		}
+	case "object":
+		bound = &s.MaxProperties
+		if lower {
+			bound = &s.MinProperties
+		}
+	default:
+		return
+	}
+	*bound = &size
+}
+
+// enumValue
+// parses one of the values of a "oneof" rule as the schema's type.
+func enumValue(typ, value string) interface{} {
+	switch typ {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// componentName
+// names a struct's component after its type, without the package
+// qualifiers of any type arguments, e.g. "Page_User" for Page[users.User].
+func componentName(t reflect.Type) string {
+	name := qualifier.ReplaceAllString(t.Name(), "")
+	return strings.Trim(invalidName.ReplaceAllString(name, "_"), "_")
+}
+
+// implements
+// checks if a type, or a pointer to it, implements an interface.
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
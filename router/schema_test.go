@@ -0,0 +1,205 @@
+package router
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type node struct {
+	Name     string `json:"name" doc:"the node's name"`
+	Children []node `json:"children,omitempty"`
+}
+
+type page[T any] struct {
+	Items []T `json:"items" validate:"max=100"`
+	Next  string
+}
+
+type audited struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type account struct {
+	audited
+	ID      int             `json:"id,string"`
+	Balance float64         `json:"balance" validate:"min=0"`
+	Flags   map[string]bool `json:"flags" validate:"max=5"`
+	Extra   json.RawMessage `json:"extra"`
+	Secret  string          `json:"-"`
+	private string
+}
+
+func Test_schemaGen_schema(t *testing.T) {
+	tests := []struct {
+		name       string
+		typ        reflect.Type
+		want       *Schema
+		components map[string]*Schema
+		wantErr    bool
+	}{
+		{
+			name: "time",
+			typ:  reflect.TypeOf(&time.Time{}),
+			want: &Schema{Type: "string", Format: "date-time"},
+		},
+		{
+			name: "bytes",
+			typ:  reflect.TypeOf([]byte{}),
+			want: &Schema{Type: "string", Format: "byte"},
+		},
+		{
+			name: "map",
+			typ:  reflect.TypeOf(map[string][]int32{}),
+			want: &Schema{Type: "object", AdditionalProperties: &Schema{Type: "array", Items: &Schema{Type: "integer", Format: "int32"}}},
+		},
+		{
+			name: "any",
+			typ:  reflect.TypeOf([]interface{}{}),
+			want: &Schema{Type: "array", Items: &Schema{}},
+		},
+		{
+			name: "request struct",
+			typ:  reflect.TypeOf(user{}),
+			want: &Schema{Ref: "#/components/schemas/user"},
+			components: map[string]*Schema{
+				"user": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"name":    {Type: "string", MaxLength: ptr(8)},
+						"email":   {Type: "string", Format: "email"},
+						"role":    {Type: "string", Enum: []interface{}{"admin", "member"}},
+						"address": {Ref: "#/components/schemas/address"},
+					},
+					Required: []string{"name"},
+				},
+				"address": {
+					Type:       "object",
+					Properties: map[string]*Schema{"city": {Type: "string"}},
+					Required:   []string{"city"},
+				},
+			},
+		},
+		{
+			name: "embedded struct",
+			typ:  reflect.TypeOf(account{}),
+			want: &Schema{Ref: "#/components/schemas/account"},
+			components: map[string]*Schema{
+				"account": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"created_at": {Type: "string", Format: "date-time"},
+						"id":         {Type: "string"},
+						"balance":    {Type: "number", Format: "double", Minimum: ptr(0.0)},
+						"flags":      {Type: "object", AdditionalProperties: &Schema{Type: "boolean"}, MaxProperties: ptr(5)},
+						"extra":      {},
+					},
+				},
+			},
+		},
+		{
+			name: "recursive struct",
+			typ:  reflect.TypeOf(node{}),
+			want: &Schema{Ref: "#/components/schemas/node"},
+			components: map[string]*Schema{
+				"node": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"name":     {Type: "string", Description: "the node's name"},
+						"children": {Type: "array", Items: &Schema{Ref: "#/components/schemas/node"}},
+					},
+				},
+			},
+		},
+		{
+			name: "generic struct",
+			typ:  reflect.TypeOf(page[address]{}),
+			want: &Schema{Ref: "#/components/schemas/page_address"},
+			components: map[string]*Schema{
+				"page_address": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"items": {Type: "array", Items: &Schema{Ref: "#/components/schemas/address"}, MaxItems: ptr(100)},
+						"Next":  {Type: "string"},
+					},
+				},
+				"address": {
+					Type:       "object",
+					Properties: map[string]*Schema{"city": {Type: "string"}},
+					Required:   []string{"city"},
+				},
+			},
+		},
+		{
+			name: "anonymous struct",
+			typ:  reflect.TypeOf(struct{ Count uint8 }{}),
+			want: &Schema{Type: "object", Properties: map[string]*Schema{"Count": {Type: "integer", Format: "int32"}}},
+		},
+		{
+			name:    "channel",
+			typ:     reflect.TypeOf(struct{ C chan int }{}),
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := newSchemaGen()
+			got, err := g.schema(test.typ)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("schema() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("schema() mismatch (-want +got):\n%s", diff)
+			}
+			if test.components == nil {
+				test.components = map[string]*Schema{}
+			}
+			if diff := cmp.Diff(test.components, g.schemas); diff != "" {
+				t.Errorf("schema() components mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_schemaGen_params(t *testing.T) {
+	want := []Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer", Format: "int64", Minimum: ptr(1.0)}},
+		{Name: "page", In: "query", Schema: &Schema{Type: "integer", Format: "int64", Minimum: ptr(1.0)}},
+		{Name: "tag", In: "query", Schema: &Schema{Type: "array", Items: &Schema{Type: "string"}, MaxItems: ptr(2)}},
+		{Name: "timeout", In: "query", Schema: &Schema{Type: "string", Format: "duration"}},
+	}
+
+	got, err := newSchemaGen().params(reflect.TypeOf(&user{}))
+	if err != nil {
+		t.Fatalf("params() error = %s", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("params() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_schemaGen_ref_NameTaken(t *testing.T) {
+	g := newSchemaGen()
+	g.schemas["user"] = &Schema{Type: "string"}
+
+	got, err := g.schema(reflect.TypeOf(user{}))
+	if err != nil {
+		t.Fatalf("schema() error = %s", err)
+	}
+	if diff := cmp.Diff(&Schema{Ref: "#/components/schemas/router.user"}, got); diff != "" {
+		t.Errorf("schema() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// ptr
+// returns a pointer to the value.
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,133 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// the security headers set by default, suited to APIs that never serve
+// pages
+const (
+	DefaultHSTSMaxAge            = 2 * 365 * 24 * time.Hour
+	DefaultFrameOptions          = "DENY"
+	DefaultContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+	DefaultReferrerPolicy        = "no-referrer"
+)
+
+// SecurityOption
+// changes a header set by SecurityHeaders().
+type SecurityOption func(*securityHeaders)
+
+type securityHeaders struct {
+	hsts           time.Duration
+	hstsSubdomains bool
+	frameOptions   string
+	csp            string
+	referrerPolicy string
+}
+
+// WithHSTS
+// sets how long browsers only connect over HTTPS, and whether that holds
+// for subdomains too. A max age of 0 leaves the Strict-Transport-Security
+// header out.
+func WithHSTS(maxAge time.Duration, includeSubdomains bool) SecurityOption {
+	return func(s *securityHeaders) {
+		s.hsts, s.hstsSubdomains = maxAge, includeSubdomains
+	}
+}
+
+// WithFrameOptions
+// sets the X-Frame-Options header, e.g. "SAMEORIGIN". An empty value
+// leaves the header out.
+func WithFrameOptions(value string) SecurityOption {
+	return func(s *securityHeaders) {
+		s.frameOptions = value
+	}
+}
+
+// WithContentSecurityPolicy
+// sets the Content-Security-Policy header. An empty policy leaves the
+// header out.
+func WithContentSecurityPolicy(policy string) SecurityOption {
+	return func(s *securityHeaders) {
+		s.csp = policy
+	}
+}
+
+// WithReferrerPolicy
+// sets the Referrer-Policy header. An empty policy leaves the header out.
+func WithReferrerPolicy(policy string) SecurityOption {
+	return func(s *securityHeaders) {
+		s.referrerPolicy = policy
+	}
+}
+
+// SecurityHeaders
+// is middleware that sets the standard security headers on every reply:
+//   - Strict-Transport-Security for two years, including subdomains, on
+//     requests made over HTTPS, see WithHSTS()
+//   - X-Content-Type-Options "nosniff"
+//   - X-Frame-Options "DENY", see WithFrameOptions()
+//   - Content-Security-Policy "default-src 'none'; frame-ancestors 'none'",
+//     see WithContentSecurityPolicy()
+//   - Referrer-Policy "no-referrer", see WithReferrerPolicy()
+//
+// Handlers can still replace any of them.
+func SecurityHeaders(opts ...SecurityOption) mux.MiddlewareFunc {
+	s := securityHeaders{
+		hsts:           DefaultHSTSMaxAge,
+		hstsSubdomains: true,
+		frameOptions:   DefaultFrameOptions,
+		csp:            DefaultContentSecurityPolicy,
+		referrerPolicy: DefaultReferrerPolicy,
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	headers := http.Header{"X-Content-Type-Options": {"nosniff"}}
+	if s.frameOptions != "" {
+		headers.Set("X-Frame-Options", s.frameOptions)
+	}
+	if s.csp != "" {
+		headers.Set("Content-Security-Policy", s.csp)
+	}
+	if s.referrerPolicy != "" {
+		headers.Set("Referrer-Policy", s.referrerPolicy)
+	}
+
+	var hsts string
+	if s.hsts > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int64(s.hsts.Seconds()))
+		if s.hstsSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			for k := range headers {
+				h.Set(k, headers.Get(k))
+			}
+			// browsers ignore the header on plain http
+			if hsts != "" && isHTTPS(r) {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+/********** helper functions **********/
+
+// isHTTPS
+// reports whether the request was made over HTTPS, directly or to a proxy
+// in front of the server.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,87 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	headers := []string{"Strict-Transport-Security", "X-Content-Type-Options", "X-Frame-Options", "Content-Security-Policy", "Referrer-Policy"}
+
+	tests := []struct {
+		name  string
+		opts  []SecurityOption
+		https bool
+		want  map[string]string
+	}{
+		{
+			name:  "defaults",
+			https: true,
+			want: map[string]string{
+				"Strict-Transport-Security": "max-age=63072000; includeSubDomains",
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "DENY",
+				"Content-Security-Policy":   "default-src 'none'; frame-ancestors 'none'",
+				"Referrer-Policy":           "no-referrer",
+			},
+		},
+		{
+			name: "no hsts over http",
+			want: map[string]string{
+				"X-Content-Type-Options":  "nosniff",
+				"X-Frame-Options":         "DENY",
+				"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+				"Referrer-Policy":         "no-referrer",
+			},
+		},
+		{
+			name:  "options",
+			https: true,
+			opts: []SecurityOption{
+				WithHSTS(24*time.Hour, false),
+				WithFrameOptions("SAMEORIGIN"),
+				WithContentSecurityPolicy(""),
+				WithReferrerPolicy("strict-origin-when-cross-origin"),
+			},
+			want: map[string]string{
+				"Strict-Transport-Security": "max-age=86400",
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "SAMEORIGIN",
+				"Referrer-Policy":           "strict-origin-when-cross-origin",
+			},
+		},
+		{
+			name:  "no hsts",
+			https: true,
+			opts:  []SecurityOption{WithHSTS(0, true), WithFrameOptions(""), WithReferrerPolicy("")},
+			want: map[string]string{
+				"X-Content-Type-Options":  "nosniff",
+				"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.https {
+				req.Header.Set("X-Forwarded-Proto", "https")
+			}
+			rec := httptest.NewRecorder()
+			SecurityHeaders(test.opts...)(http.NotFoundHandler()).ServeHTTP(rec, req)
+
+			got := make(map[string]string)
+			for _, h := range headers {
+				if v := rec.Header().Get(h); v != "" {
+					got[h] = v
+				}
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("SecurityHeaders() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
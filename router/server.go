@@ -0,0 +1,214 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/jobaldw/shared/v2/client"
+	"github.com/jobaldw/shared/v2/config"
+	"github.com/jobaldw/shared/v2/health"
+	"github.com/jobaldw/shared/v2/logging"
+)
+
+// package logging key
+const packageKey = "router"
+
+const (
+	defaultReadHeaderTimeout = 10 * time.Second  // time allowed to read request headers
+	defaultIdleTimeout       = 120 * time.Second // time idle keep-alive connections are kept open
+	defaultShutdownTimeout   = 30 * time.Second  // time in-flight requests and shutdown hooks are given
+)
+
+// the server is shutting down and no longer takes new requests
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// A Server is an http server with a router that shuts down gracefully.
+// The embedded http.Server can be customized, e.g. by wrapping the
+// router in middleware, before Run() is called.
+type Server struct {
+	*http.Server
+
+	// The router with the liveliness and readiness endpoints. Used as the
+	// server's handler when no other handler is set.
+	Router *mux.Router
+
+	// The dependency checks reported by the readiness endpoint. Every
+	// client passed to NewServer() is registered by name.
+	Health *health.Registry
+
+	cors            config.CORS
+	shutdownDelay   time.Duration
+	shutdownTimeout time.Duration
+	signals         []os.Signal
+	draining        int32
+
+	mu    sync.Mutex
+	hooks []hook
+}
+
+// A named function run when the server shuts down.
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// NewServer
+// creates a server listening on the application's port with a router
+// that has liveliness and readiness endpoints, like New(). Timeouts are
+// set from the application's configs, and the router is wrapped in the
+// CORS policy of the configs when it allows any origins.
+func NewServer(conf config.Application, clients map[string]*client.Client, paths ...string) *Server {
+	s := &Server{
+		cors:            conf.CORS,
+		shutdownDelay:   time.Duration(conf.Timeouts.ShutdownDelay) * time.Second,
+		shutdownTimeout: seconds(conf.Timeouts.Shutdown, defaultShutdownTimeout),
+		signals:         []os.Signal{os.Interrupt, syscall.SIGTERM},
+	}
+	s.Health = newRegistry(clients, conf.Readiness)
+	s.Router = newRouter(s.Health, s.IsShuttingDown, paths...)
+	s.Server = &http.Server{
+		Addr:              fmt.Sprintf(":%d", conf.Port),
+		ReadTimeout:       time.Duration(conf.Timeouts.Read) * time.Second,
+		ReadHeaderTimeout: seconds(conf.Timeouts.ReadHeader, defaultReadHeaderTimeout),
+		WriteTimeout:      time.Duration(conf.Timeouts.Write) * time.Second,
+		IdleTimeout:       seconds(conf.Timeouts.Idle, defaultIdleTimeout),
+	}
+	return s
+}
+
+// OnShutdown
+// registers a function to run once the server has stopped taking
+// requests, e.g. to disconnect from mongo or flush logs. Hooks run in the
+// order they were registered, even when an earlier one fails.
+func (s *Server) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook{name: name, fn: fn})
+}
+
+// IsShuttingDown
+// checks if the server has started shutting down.
+func (s *Server) IsShuttingDown() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// Run
+// listens on the server's address and serves requests until the context
+// is done or the process gets a SIGINT or SIGTERM, then shuts the server
+// down gracefully.
+func (s *Server) Run(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("%s: %w, could not listen on %s", packageKey, err, s.Addr)
+	}
+	return s.RunListener(ctx, l)
+}
+
+// RunListener
+// is Run() on an already open listener. Background dependency checks are
+// started when configured.
+//
+// Shutting down fails the readiness endpoint, waits for the shutdown
+// delay, stops taking new connections and waits for in-flight requests
+// to finish, then runs the shutdown hooks.
+func (s *Server) RunListener(ctx context.Context, l net.Listener) error {
+	ctx, stop := signal.NotifyContext(ctx, s.signals...)
+	defer stop()
+
+	if s.Handler == nil {
+		s.Handler = s.Router
+		if len(s.cors.AllowedOrigins) > 0 {
+			s.Handler = CORS(s.cors)(s.Router)
+		}
+	}
+	s.Health.Start(ctx)
+
+	log := logging.For(ctx, packageKey)
+	log.Info().Str(logging.AddrKey, l.Addr().String()).Msg("server listening")
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve(l)
+	}()
+
+	var serveErr error
+	select {
+	case err := <-errc:
+		if !errors.Is(err, http.ErrServerClosed) {
+			serveErr = fmt.Errorf("%s: %w, server stopped", packageKey, err)
+		}
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Info().Msg("server shutting down")
+	err := s.shutdown()
+	if err != nil {
+		log.Error().Err(err).Msg("server did not shut down cleanly")
+	}
+	if serveErr != nil {
+		log.Error().Err(serveErr).Msg("server failed")
+		return serveErr
+	}
+	return err
+}
+
+/********** helper functions **********/
+
+// shutdown
+// drains the server and runs the shutdown hooks with the time that is
+// left.
+func (s *Server) shutdown() error {
+	atomic.StoreInt32(&s.draining, 1)
+	if s.shutdownDelay > 0 {
+		time.Sleep(s.shutdownDelay)
+	}
+
+	var errs []string
+
+	// draining and the hooks share one deadline so shutting down never
+	// takes longer than the shutdown timeout
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Sprintf("%s, could not drain in-flight requests", err))
+		s.Close() // nolint:errcheck
+	}
+
+	s.mu.Lock()
+	hooks := append([]hook(nil), s.hooks...)
+	s.mu.Unlock()
+
+	for _, h := range hooks {
+		if err := h.fn(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("%s, shutdown hook %q failed", err, h.name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %s", packageKey, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// seconds
+// converts a config value in seconds to a duration, using the default
+// when it is not set.
+func seconds(n int, def time.Duration) time.Duration {
+	if n <= 0 {
+		return def
+	}
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,184 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// start
+// runs the server on a local port until the returned cancel func is
+// called. The error Run() returned is sent on the channel.
+func start(t *testing.T, s *Server) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.RunListener(ctx, l)
+	}()
+	return "http://" + l.Addr().String(), cancel, errc
+}
+
+func TestServer_Run(t *testing.T) {
+	s := NewServer(config.Application{Timeouts: config.Timeouts{ShutdownDelay: 1}}, nil)
+	s.Router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "done") // nolint:errcheck
+	})
+
+	var order []string
+	s.OnShutdown("mongo", func(ctx context.Context) error {
+		order = append(order, "mongo")
+		return nil
+	})
+	s.OnShutdown("logs", func(ctx context.Context) error {
+		order = append(order, "logs")
+		return nil
+	})
+
+	url, cancel, errc := start(t, s)
+	waitReady(t, url)
+
+	// a request in flight when the shutdown starts still gets its response
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close() // nolint:errcheck
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// readiness fails during the shutdown delay while liveliness still passes
+	time.Sleep(100 * time.Millisecond)
+	if got := status(t, url+"/ready"); got != http.StatusServiceUnavailable {
+		t.Errorf("GET /ready status = %d during shutdown, want 503", got)
+	}
+	if got := status(t, url+"/health"); got != http.StatusOK {
+		t.Errorf("GET /health status = %d during shutdown, want 200", got)
+	}
+
+	if got := <-slow; got != "done" {
+		t.Errorf("GET /slow = %q, want the in-flight request to finish", got)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Server.Run() error = %s", err)
+	}
+	if diff := cmp.Diff([]string{"mongo", "logs"}, order); diff != "" {
+		t.Errorf("shutdown hooks mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestServer_RunHookError(t *testing.T) {
+	s := NewServer(config.Application{}, nil)
+
+	ran := false
+	s.OnShutdown("mongo", func(ctx context.Context) error {
+		return errors.New("disconnect failed")
+	})
+	s.OnShutdown("logs", func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+
+	url, cancel, errc := start(t, s)
+	waitReady(t, url)
+	cancel()
+
+	err := <-errc
+	if err == nil || !strings.Contains(err.Error(), `disconnect failed, shutdown hook "mongo" failed`) {
+		t.Errorf("Server.Run() error = %v, want the mongo hook error", err)
+	}
+	if !ran {
+		t.Error("the logs hook did not run after the mongo hook failed")
+	}
+}
+
+func TestServer_RunShutdownTimeout(t *testing.T) {
+	s := NewServer(config.Application{Timeouts: config.Timeouts{Shutdown: 1}}, nil)
+	s.Router.HandleFunc("/hang", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(3 * time.Second)
+	})
+
+	var deadline time.Time
+	s.OnShutdown("mongo", func(ctx context.Context) error {
+		deadline, _ = ctx.Deadline()
+		return nil
+	})
+
+	url, cancel, errc := start(t, s)
+	waitReady(t, url)
+
+	go http.Get(url + "/hang") // nolint:errcheck
+	time.Sleep(50 * time.Millisecond)
+	stopped := time.Now()
+	cancel()
+
+	// the hooks get what is left of the shutdown timeout, not a new one
+	if err := <-errc; err == nil {
+		t.Error("Server.Run() error = nil, want the request that did not finish")
+	}
+	if elapsed := time.Since(stopped); elapsed > 1500*time.Millisecond {
+		t.Errorf("Server.Run() took %s to shut down, want at most the 1s shutdown timeout", elapsed)
+	}
+	if deadline.IsZero() || deadline.After(stopped.Add(time.Second+100*time.Millisecond)) {
+		t.Errorf("shutdown hook deadline = %s, want it within 1s of the shutdown at %s", deadline, stopped)
+	}
+}
+
+func TestNewServer_Timeouts(t *testing.T) {
+	s := NewServer(config.Application{Port: 8080, Timeouts: config.Timeouts{Read: 5, Write: 10}}, nil)
+
+	type timeouts struct {
+		Addr                                    string
+		Read, ReadHeader, Write, Idle, Shutdown time.Duration
+	}
+	want := timeouts{Addr: ":8080", Read: 5 * time.Second, ReadHeader: 10 * time.Second, Write: 10 * time.Second, Idle: 120 * time.Second, Shutdown: 30 * time.Second}
+	got := timeouts{s.Addr, s.ReadTimeout, s.ReadHeaderTimeout, s.WriteTimeout, s.IdleTimeout, s.shutdownTimeout}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("NewServer() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func waitReady(t *testing.T, url string) {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		if resp, err := http.Get(url + "/ready"); err == nil {
+			resp.Body.Close() // nolint:errcheck
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("server never became ready")
+}
+
+func status(t *testing.T, url string) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s error = %s", url, err)
+	}
+	resp.Body.Close() // nolint:errcheck
+	return resp.StatusCode
+}
@@ -0,0 +1,67 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jobaldw/shared/v2/tracing"
+)
+
+// Tracing
+// is middleware that starts a server span for every request with a tracer
+// of the provider, the global one when nil, continuing the trace of the
+// request's "traceparent" header when it has one. The span is named after
+// the method and route (e.g. "GET /users/{id}") and is carried by the
+// request's context, so client requests and mongo operations made with it
+// become its children.
+func Tracing(provider trace.TracerProvider) mux.MiddlewareFunc {
+	tracer := tracing.Tracer(provider)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if tmpl, err := current.GetPathTemplate(); err == nil {
+					route = tmpl
+				}
+			}
+
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := tracer.Start(ctx, r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPMethodKey.String(r.Method),
+					semconv.HTTPRouteKey.String(route),
+					semconv.HTTPTargetKey.String(r.URL.Path),
+				),
+			)
+
+			sw := wrap(w)
+			defer func() {
+				// a panic is recorded as a server error before it is passed on
+				status := sw.status
+				v := recover()
+				if v != nil && !sw.wroteHeader {
+					status = http.StatusInternalServerError
+				}
+
+				span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+				if status >= http.StatusInternalServerError {
+					span.SetStatus(codes.Error, http.StatusText(status))
+				}
+				if v != nil {
+					span.SetStatus(codes.Error, fmt.Sprint(v))
+				}
+				span.End()
+				if v != nil {
+					panic(v)
+				}
+			}()
+			next.ServeHTTP(sw, r.WithContext(ctx))
+		})
+	}
+}
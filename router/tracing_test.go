@@ -0,0 +1,90 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jobaldw/shared/v2/tracing"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+
+	r := mux.NewRouter()
+	r.Use(Tracing(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))))
+	r.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if Vars(r)["id"] == "0" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	type span struct {
+		Name       string
+		Kind       trace.SpanKind
+		TraceID    string
+		ParentID   string
+		Attributes map[string]interface{}
+		Status     codes.Code
+	}
+	tests := []struct {
+		name        string
+		path        string
+		traceparent string
+		resp        span
+	}{
+		{
+			name:        "continues the trace",
+			path:        "/users/1",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			resp: span{
+				Name: "GET /users/{id}", Kind: trace.SpanKindServer, TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7",
+				Attributes: map[string]interface{}{"http.method": "GET", "http.route": "/users/{id}", "http.target": "/users/1", "http.status_code": int64(200)},
+			},
+		},
+		{
+			name: "server error",
+			path: "/users/0",
+			resp: span{
+				Name: "GET /users/{id}", Kind: trace.SpanKindServer, ParentID: "0000000000000000",
+				Attributes: map[string]interface{}{"http.method": "GET", "http.route": "/users/{id}", "http.target": "/users/0", "http.status_code": int64(503)},
+				Status:     codes.Error,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter.Reset()
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.traceparent != "" {
+				req.Header.Set(tracing.TraceparentHeader, test.traceparent)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("exported %d spans, want 1", len(spans))
+			}
+			s := spans[0]
+			got := span{
+				Name: s.Name, Kind: s.SpanKind, ParentID: s.Parent.SpanID().String(), Attributes: make(map[string]interface{}), Status: s.Status.Code,
+			}
+			for _, kv := range s.Attributes {
+				got.Attributes[string(kv.Key)] = kv.Value.AsInterface()
+			}
+			if test.resp.TraceID != "" {
+				got.TraceID = s.SpanContext.TraceID().String()
+			}
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Tracing() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validate
+// checks the fields of the struct v points to against their "validate"
+// tags, a comma separated list of rules:
+//   - required: the field is not its zero value
+//   - min=n, max=n: a number is at least or at most n, and a string, slice
+//     or map has at least or at most n characters or items
+//   - len=n: a string, slice or map has exactly n characters or items
+//   - oneof=a b c: the field is one of the space separated values
+//   - email: a string is an email address
+//
+// Fields that are not required and hold their zero value are not checked.
+// Nested structs are checked too, and fields are named by their "json",
+// "form", "query", "path" or "xml" tag. A *BindError with a "422
+// Unprocessable Entity" status lists the fields that did not validate.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("%s: can only validate structs, got %T", packageKey, v)
+	}
+
+	params, err := validateStruct(rv, "")
+	if err != nil {
+		return err
+	}
+	if len(params) > 0 {
+		return &BindError{Status: http.StatusUnprocessableEntity, Params: params, Err: ErrInvalidRequest}
+	}
+	return nil
+}
+
+/********** helper functions **********/
+
+// validateStruct
+// checks the fields of a struct, naming them under the prefix.
+func validateStruct(v reflect.Value, prefix string) ([]InvalidParam, error) {
+	var params []InvalidParam
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		field := v.Field(i)
+
+		name := prefix + fieldName(sf)
+		if rules := sf.Tag.Get(validateTag); rules != "" && rules != "-" {
+			reason, err := validateField(field, rules)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w, field %s", packageKey, err, sf.Name)
+			}
+			if reason != "" {
+				params = append(params, InvalidParam{Name: name, Reason: reason})
+				continue
+			}
+		}
+
+		// check the fields of nested structs
+		for field.Kind() == reflect.Pointer && !field.IsNil() {
+			field = field.Elem()
+		}
+		if field.Kind() == reflect.Struct {
+			nestedPrefix := name + "."
+			if sf.Anonymous {
+				nestedPrefix = prefix
+			}
+			nested, err := validateStruct(field, nestedPrefix)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, nested...)
+		}
+	}
+	return params, nil
+}
+
+// validateField
+// checks a field against its rules, returning why it did not validate.
+func validateField(v reflect.Value, rules string) (string, error) {
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "required" {
+			if v.IsZero() {
+				return "is required", nil
+			}
+			continue
+		}
+		if v.IsZero() {
+			return "", nil
+		}
+		for v.Kind() == reflect.Pointer {
+			v = v.Elem()
+		}
+
+		var reason string
+		var err error
+		switch name {
+		case "min":
+			reason, err = checkSize(v, param, func(size, n float64) bool { return size >= n }, "at least")
+		case "max":
+			reason, err = checkSize(v, param, func(size, n float64) bool { return size <= n }, "at most")
+		case "len":
+			reason, err = checkSize(v, param, func(size, n float64) bool { return size == n }, "exactly")
+		case "oneof":
+			reason = checkOneOf(v, strings.Fields(param))
+		case "email":
+			if addr, err := mail.ParseAddress(v.String()); v.Kind() != reflect.String || err != nil || addr.Address != v.String() {
+				reason = "must be an email address"
+			}
+		default:
+			err = fmt.Errorf("unknown validation rule %q", name)
+		}
+		if err != nil || reason != "" {
+			return reason, err
+		}
+	}
+	return "", nil
+}
+
+// checkSize
+// compares a number, or the length of a string, slice or map, with the
+// rule's param.
+func checkSize(v reflect.Value, param string, ok func(size, n float64) bool, bound string) (string, error) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid validation param %q", param)
+	}
+
+	var size float64
+	unit := ""
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = v.Float()
+	case reflect.String:
+		size, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size, unit = float64(v.Len()), " items"
+	default:
+		return "", fmt.Errorf("cannot compare the size of a %s", v.Type())
+	}
+
+	if ok(size, n) {
+		return "", nil
+	}
+	if unit == "" {
+		return fmt.Sprintf("must be %s %s", bound, param), nil
+	}
+	return fmt.Sprintf("must have %s %s%s", bound, param, unit), nil
+}
+
+// checkOneOf
+// compares the field's value with the allowed values.
+func checkOneOf(v reflect.Value, values []string) string {
+	s := fmt.Sprint(v.Interface())
+	for _, value := range values {
+		if s == value {
+			return ""
+		}
+	}
+	return "must be one of " + strings.Join(values, ", ")
+}
+
+// fieldName
+// names a field by the first of its "json", "form", "query", "path" and
+// "xml" tags, or by its Go name.
+func fieldName(sf reflect.StructField) string {
+	for _, tag := range []string{"json", formTag, queryTag, pathTag, "xml"} {
+		if name, _, _ := strings.Cut(sf.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// sortParams
+// sorts invalid params by name.
+func sortParams(params []InvalidParam) {
+	sort.Slice(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+}
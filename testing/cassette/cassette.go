@@ -0,0 +1,392 @@
+/*
+Package cassette implements an http.RoundTripper that records HTTP
+interactions to a JSON file on disk and replays them in later test runs.
+
+A recorder is plugged into a client with client.WithTransport(). Record
+real interactions once, commit the cassette file, and replay it
+deterministically afterwards without the dependency running.
+*/
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+// package logging key
+const packageKey = "cassette"
+
+// a strict replay got a request it has no recording for
+var ErrNoMatch = errors.New("no recorded interaction matches the request")
+
+// Mode decides whether a recorder talks to the real dependency.
+type Mode int
+
+const (
+	// Replay answers requests from the cassette. Unmatched requests are
+	// sent to the real dependency and recorded, unless the recorder is
+	// strict.
+	Replay Mode = iota
+
+	// Record sends every request to the real dependency and records it,
+	// replacing the cassette's interactions.
+	Record
+
+	// Auto records when the cassette file does not exist yet and replays
+	// it when it does.
+	Auto
+)
+
+// The headers that are never written to a cassette.
+var defaultRedacted = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+// A Cassette is the JSON file of recorded interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// A recorded request and the response it got.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// A recorded request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// A recorded response.
+type Response struct {
+	Status     string      `json:"status"`
+	StatusCode int         `json:"code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// A recorded body. Text bodies are written to the cassette as is and any
+// other body is written base64 encoded.
+type Body []byte
+
+// MarshalJSON
+// implements the json.Marshaler interface.
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON
+// implements the json.Unmarshaler interface.
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = Body(text)
+		return nil
+	}
+
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	*b = decoded
+	return err
+}
+
+// A Recorder records and replays HTTP interactions. It is safe for
+// concurrent use.
+type Recorder struct {
+	path      string
+	mode      Mode
+	strict    bool
+	matchers  []Matcher
+	redacted  []string
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+	changed  bool
+}
+
+// Option
+// customizes a recorder when it is created with New().
+type Option func(*Recorder)
+
+// Strict
+// makes a replaying recorder fail requests that match no recorded
+// interaction instead of sending them to the real dependency.
+func Strict() Option {
+	return func(r *Recorder) {
+		r.strict = true
+	}
+}
+
+// WithMatchers
+// replaces the rules used to match a request to a recorded interaction.
+// Requests are matched on method, path and query by default.
+func WithMatchers(matchers ...Matcher) Option {
+	return func(r *Recorder) {
+		r.matchers = matchers
+	}
+}
+
+// WithRedactedHeaders
+// adds headers that are never written to the cassette. Authorization,
+// Cookie, Proxy-Authorization and Set-Cookie are always redacted.
+func WithRedactedHeaders(headers ...string) Option {
+	return func(r *Recorder) {
+		r.redacted = append(r.redacted, headers...)
+	}
+}
+
+// WithTransport
+// sets the round tripper used to reach the real dependency. Defaults to
+// http.DefaultTransport.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.transport = transport
+	}
+}
+
+// New
+// creates a recorder for the cassette file at path. Replaying requires the
+// file to exist.
+func New(path string, mode Mode, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		matchers:  []Matcher{MatchMethod, MatchPath, MatchQuery},
+		redacted:  defaultRedacted,
+		transport: http.DefaultTransport,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.mode == Auto {
+		r.mode = Replay
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			r.mode = Record
+		}
+	}
+
+	if r.mode == Replay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w, could not read cassette", packageKey, err)
+		}
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("%s: %w, could not parse cassette %s", packageKey, err, path)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+// RoundTrip
+// implements the http.RoundTripper interface.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w, could not read request body", packageKey, err)
+	}
+
+	if r.mode == Replay {
+		if interaction, ok := r.match(req, body); ok {
+			return interaction.Response.toHTTP(req), nil
+		}
+		if r.strict {
+			return nil, fmt.Errorf("%s: %w, %s %s", packageKey, ErrNoMatch, req.Method, req.URL)
+		}
+	}
+
+	return r.record(req, body)
+}
+
+// Save
+// writes the recorded interactions to the cassette file, creating its
+// directory when needed. Nothing is written when no new interactions were
+// recorded.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.changed {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.cassette, "", "    ")
+	if err != nil {
+		return fmt.Errorf("%s: %w", packageKey, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("%s: %w, could not create cassette directory", packageKey, err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0o644); err != nil { // nolint:gosec
+		return fmt.Errorf("%s: %w, could not write cassette", packageKey, err)
+	}
+	return nil
+}
+
+// Unused
+// returns the recorded interactions that have not been replayed yet.
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Interaction
+	for i, used := range r.used {
+		if !used {
+			unused = append(unused, r.cassette.Interactions[i])
+		}
+	}
+	return unused
+}
+
+/********** helper functions **********/
+
+// match
+// finds the first unused interaction matching the request. When every
+// matching interaction has been used, the last one is replayed again.
+func (r *Recorder) match(req *http.Request, body []byte) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := -1
+	for i, interaction := range r.cassette.Interactions {
+		if !r.matches(req, body, interaction.Request) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return interaction, true
+		}
+		last = i
+	}
+
+	if last < 0 {
+		return Interaction{}, false
+	}
+	return r.cassette.Interactions[last], true
+}
+
+func (r *Recorder) matches(req *http.Request, body []byte, recorded Request) bool {
+	for _, matcher := range r.matchers {
+		if !matcher(req, body, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+// record
+// sends the request to the real dependency and records the interaction.
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := r.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close() // nolint:errcheck
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w, could not read response body", packageKey, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: r.redact(req.Header),
+			Body:   body,
+		},
+		Response: Response{
+			Status:     resp.Status,
+			StatusCode: resp.StatusCode,
+			Header:     r.redact(resp.Header),
+			Body:       respBody,
+		},
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.used = append(r.used, true)
+	r.changed = true
+	return resp, nil
+}
+
+// redact
+// copies the headers without the ones that should never be recorded.
+func (r *Recorder) redact(header http.Header) http.Header {
+	h := header.Clone()
+	for _, key := range r.redacted {
+		h.Del(key)
+	}
+	if len(h) == 0 {
+		return nil
+	}
+	return h
+}
+
+// toHTTP
+// builds the http response replayed for the request.
+func (resp Response) toHTTP(req *http.Request) *http.Response {
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	status := resp.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	return &http.Response{
+		Status:        status,
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}
+}
+
+// readBody
+// reads and closes the request body.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close() // nolint:errcheck
+	return body, err
+}
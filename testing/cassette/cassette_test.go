@@ -0,0 +1,161 @@
+package cassette
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/client"
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// echoServer
+// responds with the request's method, path, query and body.
+func echoServer(calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s?%s %s #%d", r.Method, r.URL.Path, r.URL.RawQuery, body, atomic.LoadInt32(calls))
+	}))
+}
+
+func TestRecorder(t *testing.T) {
+	var calls int32
+	svr := echoServer(&calls)
+	defer svr.Close()
+	path := filepath.Join(t.TempDir(), "fixtures", "echo.json")
+
+	// record the interactions against the real server
+	rec, err := New(path, Auto)
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+	c, err := client.New(config.Client{URL: svr.URL, Headers: map[string][]string{"Authorization": {"Bearer token"}}}, client.WithTransport(rec))
+	if err != nil {
+		t.Fatalf("client.New() error = %s", err)
+	}
+
+	var recorded []string
+	for _, body := range []string{"one", "two"} {
+		resp, err := c.Post("/items", map[string][]string{"a": {"1"}}, body)
+		if err != nil {
+			t.Fatalf("Client.Post() error = %s", err)
+		}
+		recorded = append(recorded, resp.GetBodyString())
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatalf("Recorder.Save() error = %s", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cassette not written, %s", err)
+	}
+	if strings.Contains(string(data), "Bearer token") || strings.Contains(string(data), "session=secret") {
+		t.Errorf("cassette contains redacted headers:\n%s", data)
+	}
+
+	// replay them with the server gone
+	svr.Close()
+	rec, err = New(path, Auto, Strict())
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+	c, err = client.New(config.Client{URL: svr.URL}, client.WithTransport(rec))
+	if err != nil {
+		t.Fatalf("client.New() error = %s", err)
+	}
+
+	var replayed []string
+	for i := 0; i < 2; i++ {
+		resp, err := c.Post("/items", map[string][]string{"a": {"1"}}, nil)
+		if err != nil {
+			t.Fatalf("Client.Post() error = %s", err)
+		}
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("Client.Post() status = %d, want %d", resp.StatusCode, http.StatusCreated)
+		}
+		replayed = append(replayed, resp.GetBodyString())
+	}
+
+	if diff := cmp.Diff(recorded, replayed); diff != "" {
+		t.Errorf("replayed responses mismatch (-want +got):\n%s", diff)
+	}
+	if len(rec.Unused()) != 0 {
+		t.Errorf("Recorder.Unused() = %d interactions, want 0", len(rec.Unused()))
+	}
+	if calls != 2 {
+		t.Errorf("server calls = %d, want 2", calls)
+	}
+}
+
+func TestRecorder_Matchers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := `{"interactions": [
+		{"request": {"method": "POST", "url": "http://api.test/items?b=2&a=1", "body": "{\"name\": \"one\"}"}, "response": {"code": 200, "body": "first"}},
+		{"request": {"method": "POST", "url": "http://api.test/items?a=1&b=2", "body": "{\"name\": \"two\"}"}, "response": {"code": 200, "body": "second"}},
+		{"request": {"method": "GET", "url": "http://api.test/binary"}, "response": {"code": 200, "body": {"base64": "AP8="}}}
+	]}`
+	if err := os.WriteFile(path, []byte(cassette), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		method string
+		path   string
+		body   string
+	}
+	type resp struct {
+		Body   string
+		HasErr bool
+	}
+	tests := []struct {
+		name string
+		args args
+		resp resp
+	}{
+		{name: "body matched as json", args: args{method: http.MethodPost, path: "/items?a=1&b=2", body: `{"name":"two"}`}, resp: resp{Body: "second"}},
+		{name: "query in any order", args: args{method: http.MethodPost, path: "/items?a=1&b=2", body: `{ "name" : "one" }`}, resp: resp{Body: "first"}},
+		{name: "binary body", args: args{method: http.MethodGet, path: "/binary"}, resp: resp{Body: "\x00\xff"}},
+		{name: "unmatched body", args: args{method: http.MethodPost, path: "/items?a=1&b=2", body: `{"name":"three"}`}, resp: resp{HasErr: true}},
+		{name: "unmatched method", args: args{method: http.MethodDelete, path: "/items?a=1&b=2"}, resp: resp{HasErr: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec, err := New(path, Replay, Strict(), WithMatchers(MatchMethod, MatchPath, MatchQuery, MatchBody))
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+
+			req, _ := http.NewRequestWithContext(context.Background(), test.args.method, "http://api.test"+test.args.path, strings.NewReader(test.args.body))
+			var got resp
+			r, err := rec.RoundTrip(req)
+			if err == nil {
+				b, _ := io.ReadAll(r.Body)
+				got.Body = string(b)
+			}
+			got.HasErr = err != nil
+
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Recorder.RoundTrip() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNew_MissingCassette(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing.json"), Replay); err == nil {
+		t.Error("New() error = nil, want missing cassette error")
+	}
+}
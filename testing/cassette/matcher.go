@@ -0,0 +1,53 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// A Matcher decides if a request matches a recorded request. The request
+// body is passed separately as it has already been read.
+type Matcher func(req *http.Request, body []byte, recorded Request) bool
+
+// MatchMethod
+// matches requests with the same method.
+func MatchMethod(req *http.Request, _ []byte, recorded Request) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchPath
+// matches requests with the same url path.
+func MatchPath(req *http.Request, _ []byte, recorded Request) bool {
+	u, err := url.Parse(recorded.URL)
+	return err == nil && req.URL.Path == u.Path
+}
+
+// MatchQuery
+// matches requests with the same query params, in any order.
+func MatchQuery(req *http.Request, _ []byte, recorded Request) bool {
+	u, err := url.Parse(recorded.URL)
+	return err == nil && reflect.DeepEqual(req.URL.Query(), u.Query())
+}
+
+// MatchBody
+// matches requests with the same body. JSON bodies match when they hold
+// the same values, regardless of formatting and key order.
+func MatchBody(_ *http.Request, body []byte, recorded Request) bool {
+	var got, want interface{}
+	if json.Unmarshal(body, &got) == nil && json.Unmarshal(recorded.Body, &want) == nil {
+		return reflect.DeepEqual(got, want)
+	}
+	return bytes.Equal(bytes.TrimSpace(body), bytes.TrimSpace(recorded.Body))
+}
+
+// MatchHeader
+// creates a matcher for requests with the same values for the header.
+// Redacted headers are never recorded, so they cannot be matched.
+func MatchHeader(key string) Matcher {
+	return func(req *http.Request, _ []byte, recorded Request) bool {
+		return reflect.DeepEqual(req.Header.Values(key), recorded.Header.Values(key))
+	}
+}
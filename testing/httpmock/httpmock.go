@@ -0,0 +1,261 @@
+/*
+Package httpmock implements a programmable HTTP mock server for testing
+code that uses client.Client or router handlers.
+
+Requests are answered by stubs that match on method, path, query params,
+headers and JSON bodies. Every request is recorded so tests can assert on
+how the server was called.
+
+	srv := httpmock.NewServer()
+	defer srv.Close()
+
+	srv.On(http.MethodGet, "/users/{id}").
+		Respond(http.StatusOK).JSON(User{Name: "jb"})
+
+	c, _ := client.New(config.Client{URL: srv.URL})
+	resp, _ := c.Get("/users/1", nil)
+
+	srv.AssertCalled(t, http.MethodGet, "/users/1")
+*/
+package httpmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// A request received by the mock server.
+type Call struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+
+	// the stub that answered the call, nil when no stub matched
+	Stub *Stub
+}
+
+// A mock HTTP server. Stubs are matched in the order they were added and
+// requests no stub matches get a "404 Not Found" response.
+//
+// A Server is also an http.Handler, so it can be mounted on a router
+// without starting a listener by using New() instead of NewServer(). Such
+// a handler has no URL, Client() or Certificate(), as the embedded
+// httptest.Server is nil.
+type Server struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	stubs []*Stub
+	calls []Call
+}
+
+// New
+// creates a mock handler that is not listening on a port, to be called
+// through its ServeHTTP() only. Use NewServer() for a mock with a URL.
+func New() *Server {
+	return &Server{}
+}
+
+// NewServer
+// creates and starts a mock server listening on a local port. It should
+// be closed with Close() once the test is done.
+func NewServer() *Server {
+	s := New()
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer
+// creates and starts a mock server using TLS. Use the server's Client()
+// or its Certificate() to trust it.
+func NewTLSServer() *Server {
+	s := New()
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+// Close
+// shuts the server down, blocking until every request has finished. It
+// does nothing for a handler made with New().
+func (s *Server) Close() {
+	if s.Server != nil {
+		s.Server.Close()
+	}
+}
+
+// On
+// adds a stub for requests with the method and path. The path may hold
+// gorilla/mux style variables, e.g. "/users/{id}" or "/users/{id:[0-9]+}".
+func (s *Server) On(method, path string) *Stub {
+	stub := newStub(method, path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stubs = append(s.stubs, stub)
+	return stub
+}
+
+// Reset
+// removes every stub and recorded call.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stubs, s.calls = nil, nil
+}
+
+// ServeHTTP
+// implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	call := Call{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+
+	s.mu.Lock()
+	var reply *Reply
+	for _, stub := range s.stubs {
+		if stub.matches(r, body) {
+			call.Stub = stub
+			reply = stub.next()
+			break
+		}
+	}
+	s.calls = append(s.calls, call)
+	s.mu.Unlock()
+
+	if reply == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{ // nolint:errcheck
+			"error": fmt.Sprintf("httpmock: no stub for %s %s", r.Method, r.URL),
+		})
+		return
+	}
+	reply.write(w, r)
+}
+
+// Calls
+// returns every request the server has received.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Unmatched
+// returns the requests no stub matched.
+func (s *Server) Unmatched() []Call {
+	var unmatched []Call
+	for _, call := range s.Calls() {
+		if call.Stub == nil {
+			unmatched = append(unmatched, call)
+		}
+	}
+	return unmatched
+}
+
+// CallCount
+// returns the number of requests received with the method and path. The
+// path may hold gorilla/mux style variables.
+func (s *Server) CallCount(method, path string) int {
+	route := newRoute(method, path)
+
+	count := 0
+	for _, call := range s.Calls() {
+		if route.matchCall(call) {
+			count++
+		}
+	}
+	return count
+}
+
+// AssertCalled
+// fails the test when no request was received with the method and path.
+func (s *Server) AssertCalled(t testing.TB, method, path string) bool {
+	t.Helper()
+	if s.CallCount(method, path) == 0 {
+		t.Errorf("httpmock: expected a call to %s %s, got calls %s", method, path, s.describe())
+		return false
+	}
+	return true
+}
+
+// AssertNotCalled
+// fails the test when a request was received with the method and path.
+func (s *Server) AssertNotCalled(t testing.TB, method, path string) bool {
+	t.Helper()
+	if n := s.CallCount(method, path); n != 0 {
+		t.Errorf("httpmock: expected no calls to %s %s, got %d", method, path, n)
+		return false
+	}
+	return true
+}
+
+// AssertCallCount
+// fails the test when the number of requests received with the method and
+// path is not n.
+func (s *Server) AssertCallCount(t testing.TB, method, path string, n int) bool {
+	t.Helper()
+	if got := s.CallCount(method, path); got != n {
+		t.Errorf("httpmock: expected %d calls to %s %s, got %d", n, method, path, got)
+		return false
+	}
+	return true
+}
+
+// AssertExpectations
+// fails the test when any stub was never called or any request matched
+// no stub.
+func (s *Server) AssertExpectations(t testing.TB) bool {
+	t.Helper()
+
+	s.mu.Lock()
+	stubs := append([]*Stub(nil), s.stubs...)
+	s.mu.Unlock()
+
+	ok := true
+	for _, stub := range stubs {
+		if stub.CallCount() == 0 {
+			t.Errorf("httpmock: stub %s was never called", stub)
+			ok = false
+		}
+	}
+	for _, call := range s.Unmatched() {
+		t.Errorf("httpmock: no stub matched %s %s", call.Method, call.Path)
+		ok = false
+	}
+	return ok
+}
+
+// describe
+// lists the received calls for failure messages.
+func (s *Server) describe() string {
+	calls := s.Calls()
+	if len(calls) == 0 {
+		return "none"
+	}
+
+	var buf bytes.Buffer
+	for i, call := range calls {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "%s %s", call.Method, call.Path)
+	}
+	return buf.String()
+}
@@ -0,0 +1,248 @@
+package httpmock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/jobaldw/shared/v2/client"
+	"github.com/jobaldw/shared/v2/config"
+)
+
+// fakeT
+// records the failures of assertions that are expected to fail.
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestServer_Match(t *testing.T) {
+	svr := NewServer()
+	defer svr.Close()
+
+	svr.On(http.MethodGet, "/users/{id:[0-9]+}").WithQuery("expand", "groups").Respond(http.StatusOK).Body("expanded")
+	svr.On(http.MethodGet, "/users/{id:[0-9]+}").Respond(http.StatusOK).JSON(map[string]string{"name": "jb"})
+	svr.On(http.MethodPost, "/users").WithHeader("X-Tenant", "a").WithJSONBody(map[string]interface{}{"name": "jb", "age": 30}).Respond(http.StatusCreated)
+	svr.On(http.MethodPost, "/users").Respond(http.StatusUnprocessableEntity)
+
+	c, err := client.New(config.Client{URL: svr.URL})
+	if err != nil {
+		t.Fatalf("client.New() error = %s", err)
+	}
+
+	type call struct {
+		method string
+		path   string
+		params map[string][]string
+		body   interface{}
+		opts   []client.RequestOption
+	}
+	type resp struct {
+		StatusCode int
+		Body       string
+	}
+	tests := []struct {
+		name string
+		call call
+		resp resp
+	}{
+		{
+			name: "query",
+			call: call{method: http.MethodGet, path: "/users/1", params: map[string][]string{"expand": {"groups"}}},
+			resp: resp{StatusCode: 200, Body: "expanded"},
+		},
+		{
+			name: "path variable",
+			call: call{method: http.MethodGet, path: "/users/2"},
+			resp: resp{StatusCode: 200, Body: `{"name":"jb"}`},
+		},
+		{
+			name: "missing header",
+			call: call{method: http.MethodPost, path: "/users", body: map[string]interface{}{"name": "jb", "age": 30}},
+			resp: resp{StatusCode: 422},
+		},
+		{
+			name: "json body in another order",
+			call: call{
+				method: http.MethodPost,
+				path:   "/users",
+				body:   strings.NewReader(`{"age": 30, "name": "jb"}`),
+				opts:   []client.RequestOption{client.WithHeader("X-Tenant", "a")},
+			},
+			resp: resp{StatusCode: 201},
+		},
+		{
+			name: "no stub",
+			call: call{method: http.MethodGet, path: "/users/jb"},
+			resp: resp{StatusCode: 404, Body: `{"error":"httpmock: no stub for GET /users/jb"}` + "\n"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var r *client.Response
+			switch test.call.method {
+			case http.MethodGet:
+				r, err = c.Get(test.call.path, test.call.params, test.call.opts...)
+			case http.MethodPost:
+				r, err = c.Post(test.call.path, test.call.params, test.call.body, test.call.opts...)
+			}
+			if err != nil {
+				t.Fatalf("Client.%s() error = %s", test.call.method, err)
+			}
+
+			got := resp{StatusCode: r.StatusCode, Body: r.GetBodyString()}
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Server.ServeHTTP() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	svr.AssertCallCount(t, http.MethodGet, "/users/{id}", 3)
+	svr.AssertCalled(t, http.MethodPost, "/users")
+	svr.AssertNotCalled(t, http.MethodDelete, "/users/{id}")
+	if n := len(svr.Unmatched()); n != 1 {
+		t.Errorf("Server.Unmatched() = %d calls, want 1", n)
+	}
+}
+
+func TestServer_Sequence(t *testing.T) {
+	svr := NewServer()
+	defer svr.Close()
+
+	stub := svr.On(http.MethodGet, "/flaky")
+	stub.Respond(http.StatusServiceUnavailable).Times(2).
+		Then(http.StatusOK).Header("X-Attempt", "third").
+		Then(http.StatusNoContent)
+
+	var got []int
+	for i := 0; i < 5; i++ {
+		resp, err := http.Get(svr.URL + "/flaky")
+		if err != nil {
+			t.Fatalf("http.Get() error = %s", err)
+		}
+		resp.Body.Close() // nolint:errcheck
+		got = append(got, resp.StatusCode)
+
+		if i == 2 && resp.Header.Get("X-Attempt") != "third" {
+			t.Errorf("reply header = %q, want third", resp.Header.Get("X-Attempt"))
+		}
+	}
+
+	if diff := cmp.Diff([]int{503, 503, 200, 204, 204}, got); diff != "" {
+		t.Errorf("reply sequence mismatch (-want +got):\n%s", diff)
+	}
+	if stub.CallCount() != 5 {
+		t.Errorf("Stub.CallCount() = %d, want 5", stub.CallCount())
+	}
+}
+
+func TestServer_Delay(t *testing.T) {
+	svr := NewServer()
+	defer svr.Close()
+	svr.On(http.MethodGet, "/slow").Respond(http.StatusOK).Delay(2 * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, svr.URL+"/slow", nil)
+
+	start := time.Now()
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Error("http.Do() error = nil, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("http.Do() took %s, want the delay to stop with the request", elapsed)
+	}
+}
+
+func TestServer_Fault(t *testing.T) {
+	svr := NewServer()
+	defer svr.Close()
+	svr.On(http.MethodGet, "/reset").Respond(http.StatusOK).Fault(ConnectionReset)
+	svr.On(http.MethodGet, "/empty").Respond(http.StatusOK).Fault(EmptyResponse)
+	svr.On(http.MethodGet, "/truncated").Respond(http.StatusOK).Body("0123456789").Fault(TruncatedBody)
+
+	for _, path := range []string{"/reset", "/empty"} {
+		t.Run(path, func(t *testing.T) {
+			if _, err := http.Get(svr.URL + path); err == nil {
+				t.Errorf("http.Get() error = nil, want a connection error")
+			}
+		})
+	}
+
+	t.Run("/truncated", func(t *testing.T) {
+		c, err := client.New(config.Client{URL: svr.URL})
+		if err != nil {
+			t.Fatalf("client.New() error = %s", err)
+		}
+		resp, err := c.Get("/truncated", nil)
+		if err != nil {
+			t.Fatalf("Client.Get() error = %s", err)
+		}
+		defer resp.Close() // nolint:errcheck
+		if _, err := io.ReadAll(resp.GetBody()); err == nil {
+			t.Error("io.ReadAll() error = nil, want an unexpected EOF")
+		}
+	})
+}
+
+func TestServer_Handler(t *testing.T) {
+	mock := New()
+	mock.On(http.MethodDelete, "/users/{id}").Respond(http.StatusNoContent)
+
+	rec := httptest.NewRecorder()
+	mock.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/users/1", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Server.ServeHTTP() status = %d, want 204", rec.Code)
+	}
+	if diff := cmp.Diff("/users/1", mock.Calls()[0].Path); diff != "" {
+		t.Errorf("Server.Calls() mismatch (-want +got):\n%s", diff)
+	}
+
+	// a handler has nothing to close
+	mock.Close()
+}
+
+func TestServer_AssertExpectations(t *testing.T) {
+	svr := New()
+	svr.On(http.MethodGet, "/called").Respond(http.StatusOK)
+	svr.On(http.MethodGet, "/never").Respond(http.StatusOK)
+
+	svr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/called", nil))
+	svr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	ft := &fakeT{TB: t}
+	if svr.AssertExpectations(ft) {
+		t.Error("Server.AssertExpectations() = true, want false")
+	}
+	want := []string{
+		"httpmock: stub GET /never was never called",
+		"httpmock: no stub matched GET /unknown",
+	}
+	if diff := cmp.Diff(want, ft.errors); diff != "" {
+		t.Errorf("Server.AssertExpectations() mismatch (-want +got):\n%s", diff)
+	}
+
+	ft = &fakeT{TB: t}
+	if svr.AssertCalled(ft, http.MethodPost, "/called") || len(ft.errors) != 1 {
+		t.Errorf("Server.AssertCalled() errors = %v, want 1", ft.errors)
+	}
+
+	svr.Reset()
+	if len(svr.Calls()) != 0 {
+		t.Errorf("Server.Calls() = %d calls after Reset(), want 0", len(svr.Calls()))
+	}
+}
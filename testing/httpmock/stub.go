@@ -0,0 +1,335 @@
+package httpmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// A Fault breaks a response instead of writing it normally.
+type Fault int
+
+const (
+	NoFault         Fault = iota // the response is written normally
+	ConnectionReset              // the connection is reset without a response
+	EmptyResponse                // the connection is closed without a response
+	TruncatedBody                // the headers promise the whole body but only half of it is sent
+)
+
+// A Stub answers the requests that match it with its replies.
+type Stub struct {
+	route *route
+
+	query   url.Values
+	header  http.Header
+	body    []byte
+	json    interface{}
+	hasJSON bool
+
+	mu      sync.Mutex
+	replies []*Reply
+	calls   int
+}
+
+// A Reply is a response sent by a stub. A stub with more than one reply
+// sends them in order and repeats the last one.
+type Reply struct {
+	stub *Stub
+
+	status int
+	header http.Header
+	body   []byte
+	delay  time.Duration
+	fault  Fault
+	times  int
+}
+
+// newStub
+// creates a stub for the method and path template.
+func newStub(method, path string) *Stub {
+	return &Stub{route: newRoute(method, path), header: make(http.Header)}
+}
+
+// WithQuery
+// only matches requests with exactly these values for the query param.
+func (s *Stub) WithQuery(key string, values ...string) *Stub {
+	if s.query == nil {
+		s.query = make(url.Values)
+	}
+	s.query[key] = values
+	return s
+}
+
+// WithHeader
+// only matches requests that have the header value.
+func (s *Stub) WithHeader(key, value string) *Stub {
+	s.header.Add(key, value)
+	return s
+}
+
+// WithBody
+// only matches requests with exactly this body.
+func (s *Stub) WithBody(body string) *Stub {
+	s.body = []byte(body)
+	return s
+}
+
+// WithJSONBody
+// only matches requests whose JSON body holds the same values as v,
+// regardless of formatting and key order.
+func (s *Stub) WithJSONBody(v interface{}) *Stub {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("httpmock: %s, could not encode expected body", err))
+	}
+	s.json, s.hasJSON = nil, true
+	json.Unmarshal(b, &s.json) // nolint:errcheck
+	return s
+}
+
+// Respond
+// adds a reply with the status code to the stub's sequence of replies.
+func (s *Stub) Respond(status int) *Reply {
+	reply := &Reply{stub: s, status: status, header: make(http.Header)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, reply)
+	return reply
+}
+
+// CallCount
+// returns the number of requests the stub has answered.
+func (s *Stub) CallCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// String
+// implements the fmt.Stringer interface.
+func (s *Stub) String() string {
+	return s.route.String()
+}
+
+// Header
+// sets a header on the reply.
+func (r *Reply) Header(key, value string) *Reply {
+	r.header.Set(key, value)
+	return r
+}
+
+// Body
+// sets the reply's body.
+func (r *Reply) Body(body string) *Reply {
+	r.body = []byte(body)
+	return r
+}
+
+// JSON
+// sets the reply's body to v encoded as JSON.
+func (r *Reply) JSON(v interface{}) *Reply {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("httpmock: %s, could not encode reply body", err))
+	}
+	r.body = b
+	if r.header.Get("Content-Type") == "" {
+		r.header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+// Delay
+// waits before sending the reply, or until the request is canceled.
+func (r *Reply) Delay(d time.Duration) *Reply {
+	r.delay = d
+	return r
+}
+
+// Fault
+// breaks the reply with a fault.
+func (r *Reply) Fault(f Fault) *Reply {
+	r.fault = f
+	return r
+}
+
+// Times
+// sends the reply n times before moving on to the next reply in the
+// sequence. Defaults to once.
+func (r *Reply) Times(n int) *Reply {
+	r.times = n
+	return r
+}
+
+// Then
+// adds another reply to the stub's sequence, sent after this one.
+func (r *Reply) Then(status int) *Reply {
+	return r.stub.Respond(status)
+}
+
+/********** helper functions **********/
+
+// matches
+// checks the request against the stub's route, query, headers and body.
+func (s *Stub) matches(r *http.Request, body []byte) bool {
+	if !s.route.match(r) {
+		return false
+	}
+
+	query := r.URL.Query()
+	for key, values := range s.query {
+		if !reflect.DeepEqual(query[key], values) {
+			return false
+		}
+	}
+
+	for key, values := range s.header {
+		for _, value := range values {
+			if !contains(r.Header.Values(key), value) {
+				return false
+			}
+		}
+	}
+
+	if s.body != nil && !bytes.Equal(s.body, body) {
+		return false
+	}
+
+	if s.hasJSON {
+		var got interface{}
+		if err := json.Unmarshal(body, &got); err != nil || !reflect.DeepEqual(got, s.json) {
+			return false
+		}
+	}
+	return true
+}
+
+// next
+// counts the call and returns the reply it gets.
+func (s *Stub) next() *Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.calls
+	s.calls++
+
+	if len(s.replies) == 0 {
+		return &Reply{status: http.StatusOK, header: make(http.Header)}
+	}
+
+	for i, reply := range s.replies {
+		times := reply.times
+		if times <= 0 {
+			times = 1
+		}
+		if i == len(s.replies)-1 || n < times {
+			return reply
+		}
+		n -= times
+	}
+	return nil
+}
+
+// write
+// sends the reply, breaking it when it has a fault.
+func (r *Reply) write(w http.ResponseWriter, req *http.Request) {
+	if r.delay > 0 {
+		timer := time.NewTimer(r.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	switch r.fault {
+	case ConnectionReset, EmptyResponse:
+		conn := hijack(w)
+		if tcp, ok := conn.(*net.TCPConn); ok && r.fault == ConnectionReset {
+			tcp.SetLinger(0) // nolint:errcheck
+		}
+		conn.Close() // nolint:errcheck
+		return
+	case TruncatedBody:
+		for k, v := range r.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(r.body)))
+		w.WriteHeader(r.status)
+		w.Write(r.body[:len(r.body)/2]) // nolint:errcheck
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		hijack(w).Close() // nolint:errcheck
+		return
+	}
+
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(r.status)
+	w.Write(r.body) // nolint:errcheck
+}
+
+func hijack(w http.ResponseWriter) net.Conn {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic("httpmock: faults need a response writer that can be hijacked")
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(fmt.Sprintf("httpmock: %s, could not hijack connection", err))
+	}
+	return conn
+}
+
+// A method and gorilla/mux path template.
+type route struct {
+	method string
+	path   string
+	route  *mux.Route
+}
+
+func newRoute(method, path string) *route {
+	r := mux.NewRouter().NewRoute().Path(path)
+	if method != "" {
+		r = r.Methods(method)
+	}
+	if err := r.GetError(); err != nil {
+		panic(fmt.Sprintf("httpmock: %s, invalid path %q", err, path))
+	}
+	return &route{method: method, path: path, route: r}
+}
+
+func (r *route) match(req *http.Request) bool {
+	return r.route.Match(req, &mux.RouteMatch{})
+}
+
+func (r *route) matchCall(call Call) bool {
+	req := &http.Request{Method: call.Method, URL: &url.URL{Path: call.Path}, Header: call.Header}
+	return r.match(req)
+}
+
+func (r *route) String() string {
+	return r.method + " " + r.path
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
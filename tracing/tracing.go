@@ -0,0 +1,69 @@
+/*
+Package tracing follows a request across services with OpenTelemetry
+(https://opentelemetry.io) spans that carry W3C trace context
+(https://www.w3.org/TR/trace-context/).
+
+A span is started for every request served by the router, every request
+made by a client and every mongo operation, with a tracer of the global
+TracerProvider unless another one is passed. Spans started with a context
+that carries another span become its children, and the trace context is
+passed on to other services in the "traceparent" and "tracestate" headers
+with Propagator. Spans are only recorded and exported once a provider such
+as the OpenTelemetry SDK's is set with otel.SetTracerProvider(); until
+then the trace context of incoming requests is still passed on.
+*/
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// the instrumentation name of the tracers the shared packages start their
+// spans with
+const InstrumentationName = "github.com/jobaldw/shared/v2"
+
+// W3C trace context headers
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// Propagator reads and writes the trace context of the requests served by
+// the router and made by clients, W3C trace context by default. It can be
+// replaced, e.g. with otel.GetTextMapPropagator(), before serving or
+// making requests.
+var Propagator propagation.TextMapPropagator = propagation.TraceContext{}
+
+// Tracer
+// returns the tracer the shared packages start their spans with from the
+// provider, or from the global provider when it is nil. Tracers of the
+// global provider use the provider set with otel.SetTracerProvider() even
+// when it is set after they are created.
+func Tracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(InstrumentationName)
+}
+
+// Inject
+// writes the trace context of the context's span to the headers, so the
+// service they are sent to continues the trace. Nothing is written when
+// the context has no span.
+func Inject(ctx context.Context, header http.Header) {
+	Propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract
+// reads the trace context from the headers of an incoming request,
+// returning a copy of the context that spans started with become children
+// of. The context is returned as is when the "traceparent" header is
+// missing or invalid.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return Propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,149 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestExtract(t *testing.T) {
+	type resp struct {
+		Valid      bool
+		TraceID    string
+		SpanID     string
+		Sampled    bool
+		Remote     bool
+		TraceState string
+	}
+	tests := []struct {
+		name        string
+		traceparent string
+		tracestate  string
+		resp        resp
+	}{
+		{
+			name:        "sampled",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			tracestate:  "congo=t61rcWkgMzE",
+			resp:        resp{Valid: true, TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true, Remote: true, TraceState: "congo=t61rcWkgMzE"},
+		},
+		{
+			name:        "not sampled",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			resp:        resp{Valid: true, TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Remote: true},
+		},
+		{
+			name:        "future version with more fields",
+			traceparent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			resp:        resp{Valid: true, TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true, Remote: true},
+		},
+		{name: "missing"},
+		{name: "invalid version", traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "uppercase", traceparent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01"},
+		{name: "zero trace id", traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "zero span id", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "short trace id", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01"},
+		{name: "not hex", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			if test.traceparent != "" {
+				header.Set(TraceparentHeader, test.traceparent)
+			}
+			if test.tracestate != "" {
+				header.Set(TracestateHeader, test.tracestate)
+			}
+
+			sc := trace.SpanContextFromContext(Extract(context.Background(), header))
+
+			got := resp{Valid: sc.IsValid()}
+			if sc.IsValid() {
+				got = resp{
+					Valid:      true,
+					TraceID:    sc.TraceID().String(),
+					SpanID:     sc.SpanID().String(),
+					Sampled:    sc.IsSampled(),
+					Remote:     sc.IsRemote(),
+					TraceState: sc.TraceState().String(),
+				}
+			}
+			if diff := cmp.Diff(test.resp, got); diff != "" {
+				t.Errorf("Extract() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInject(t *testing.T) {
+	incoming := http.Header{}
+	incoming.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	incoming.Set(TracestateHeader, "congo=t61rcWkgMzE")
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, span := Tracer(provider).Start(Extract(context.Background(), incoming), "GET /users")
+
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	span.End()
+
+	want := http.Header{
+		"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanContext().SpanID().String() + "-01"},
+		"Tracestate":  {"congo=t61rcWkgMzE"},
+	}
+	if diff := cmp.Diff(want, outgoing); diff != "" {
+		t.Errorf("Inject() mismatch (-want +got):\n%s", diff)
+	}
+
+	// the server span is a child of the remote span
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	if got := spans[0].Parent; got.SpanID().String() != "00f067aa0ba902b7" || !got.IsRemote() {
+		t.Errorf("span parent = %s, want the remote span 00f067aa0ba902b7", got.SpanID())
+	}
+	if got := spans[0].InstrumentationLibrary.Name; got != InstrumentationName {
+		t.Errorf("span instrumentation name = %s, want %s", got, InstrumentationName)
+	}
+
+	// nothing is injected without a span
+	empty := http.Header{}
+	Inject(context.Background(), empty)
+	if len(empty) != 0 {
+		t.Errorf("Inject() without a span wrote %v", empty)
+	}
+}
+
+func TestTracer_Global(t *testing.T) {
+	incoming := http.Header{}
+	incoming.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// without a provider set, spans are not recorded but the trace is passed on
+	tracer := Tracer(nil)
+	ctx, span := tracer.Start(Extract(context.Background(), incoming), "GET /users")
+	span.End()
+
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	if got, want := outgoing.Get(TraceparentHeader), incoming.Get(TraceparentHeader); got != want {
+		t.Errorf("Inject() traceparent = %q, want %q", got, want)
+	}
+
+	// a provider set afterwards is used by tracers created before
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	_, span = tracer.Start(context.Background(), "nightly-import")
+	span.End()
+	if got := exporter.GetSpans(); len(got) != 1 || got[0].Name != "nightly-import" {
+		t.Errorf("exported %v, want the nightly-import span", got)
+	}
+}